@@ -3,16 +3,21 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"mtgo/cli"
 	"mtgo/mods"
 	"mtgo/server"
+	"os"
 	"time"
 
 	"mtgo/data"
 )
 
 func main() {
+	headless := flag.Bool("headless", false, "run the server without the interactive menu until SIGINT or SIGTERM")
+	flag.Parse()
+
 	startTime := time.Now()
 	data.SetPrimaryDatabase()
 
@@ -22,9 +27,25 @@ func main() {
 
 	data.SetCache()
 	data.SetFlea()
+	data.SetFenceAssort()
+	data.SetBotItemPools()
 	endTime := time.Now()
 	fmt.Printf("Database initialized in %s\n\n", endTime.Sub(startTime))
 
+	if flag.NArg() > 0 {
+		os.Exit(cli.Run(flag.Args()))
+	}
+
 	server.Start()
-	cli.Start()
+	if *headless {
+		cli.WaitForShutdown(nil)
+	} else {
+		menu := make(chan struct{})
+		go func() {
+			cli.Start()
+			close(menu)
+		}()
+		cli.WaitForShutdown(menu)
+	}
+	server.Shutdown()
 }
@@ -10,8 +10,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-
-	"github.com/alphadose/haxmap"
 )
 
 func Start() {
@@ -33,7 +31,7 @@ func Start() {
 			login()
 		case "69":
 			fmt.Println("Adios fella")
-			os.Exit(1)
+			return
 		default:
 			fmt.Println("Invalid input, intellectually less able fella")
 		}
@@ -42,7 +40,6 @@ func Start() {
 
 func registerAccount() {
 	account := new(data.Account)
-	profiles := data.GetProfiles()
 	var input string
 	fmt.Println("What is your username?")
 	for {
@@ -51,7 +48,7 @@ func registerAccount() {
 		scanner.Scan()
 		input = scanner.Text()
 
-		if !validateUsername(profiles, input) {
+		if data.IsUsernameTaken(input) {
 			fmt.Println("Username taken, try again")
 			continue
 		}
@@ -63,7 +60,7 @@ func registerAccount() {
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Print("> ")
 	scanner.Scan()
-	account.Password = scanner.Text()
+	password := scanner.Text()
 
 	fmt.Println("Account Type? 1 - Dev, 2 - Edge of Darkness")
 	for account.Edition == "" {
@@ -95,50 +92,16 @@ func registerAccount() {
 		account.Lang = input
 	}
 
-	UID := tools.GenerateMongoID()
-	account.UID = UID
-	account.AID = int(profiles.Len())
-
-	newProfile := &data.Profile{
-		Account: account,
-		Character: &data.Character[map[string]data.PlayerTradersInfo]{
-			ID: UID,
-		},
-		Friends:  new(data.Friends),
-		Storage:  new(data.Storage),
-		Dialogue: new(data.Dialogue),
-		Cache:    nil,
+	profile, err := data.RegisterAccount(account.Username, password, account.Edition, account.Lang)
+	if err != nil {
+		log.Fatalln(err)
 	}
 
-	newProfile.Friends.CreateFriends()
-	newProfile.Storage.CreateStorage()
-
-	profiles.Set(UID, newProfile)
-
-	profile, ok := profiles.Get(UID)
-	if !ok {
-		log.Fatalln("profile does not exist")
-	}
-	//save profile
-	profile.SaveProfile()
-
 	//login
 	fmt.Println("\nAccount created, logging in...")
 	loggedIn(profile.Account)
 }
 
-func validateUsername(profiles *haxmap.Map[string, *data.Profile], username string) bool {
-	output := true
-	profiles.ForEach(func(key string, value *data.Profile) bool {
-		if value.Account.Username == username {
-			output = false
-			return false
-		}
-		return true
-	})
-	return output
-}
-
 func login() {
 	fmt.Println()
 	var input string
@@ -175,7 +138,7 @@ func login() {
 		scanner.Scan()
 		input = scanner.Text()
 
-		if account.Password != input {
+		if !account.CheckPassword(input) {
 			fmt.Println("Invalid password, try again moron")
 			continue
 		}
@@ -260,28 +223,11 @@ func editAccountInfo(account *data.Account) {
 }
 
 func wipeYoAss(account *data.Account) {
-	account.Wipe = true
-	profiles := data.GetProfiles()
-
-	profile, ok := profiles.Get(account.UID)
-	if !ok {
-		log.Println("profile does not exist")
+	if err := account.WipeProfile(); err != nil {
+		log.Println(err)
 		return
 	}
-
-	profile.Character = &data.Character[map[string]data.PlayerTradersInfo]{}
-	profile.Storage = &data.Storage{
-		Suites: []string{},
-		Builds: &data.Builds{
-			EquipmentBuilds: []*data.EquipmentBuild{},
-			WeaponBuilds:    []*data.WeaponBuild{},
-		},
-		Insurance: []any{},
-		Mailbox:   []*data.Notification{},
-	}
-	profile.Dialogue = &data.Dialogue{}
 	fmt.Println("Yo ass is clean")
-	profile.SaveProfile()
 	loggedIn(account)
 }
 
@@ -341,10 +287,16 @@ func launchTarkov(account *data.Account) {
 		}
 	}
 
+	sessionToken, err := data.CreateSession(account.UID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
 	cmdArgs := []string{
 		"force-gfx-jobs native",
-		fmt.Sprintf(email, account.Username, account.Password),
-		fmt.Sprintf(token, account.UID),
+		fmt.Sprintf(email, account.Username, ""),
+		fmt.Sprintf(token, sessionToken),
 		fmt.Sprintf(config, data.GetMainAddress()),
 	}
 
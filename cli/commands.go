@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"mtgo/data"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+// Exit codes of the subcommands
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+const usage = `Usage: mtgo [-headless] [command]
+
+Without a command the server starts with the interactive menu, or with -headless until SIGINT or SIGTERM.
+
+Commands:
+  account create -username NAME -password PASSWORD [-edition EDITION] [-lang LANG]
+  account list
+  account delete -username NAME
+  account wipe -username NAME
+  profile backup -username NAME -out FILE
+  profile restore -in FILE
+  validate
+`
+
+// WaitForShutdown blocks until the process receives SIGINT or SIGTERM, or done is closed
+func WaitForShutdown(done <-chan struct{}) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+		fmt.Println("Shutdown signal received")
+	case <-done:
+	}
+}
+
+// Run executes the non-interactive command of args, returning the process' exit code
+func Run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "account":
+		if len(args) < 2 {
+			break
+		}
+		switch args[1] {
+		case "create":
+			return createAccountCommand(args[2:])
+		case "list":
+			return listAccountsCommand()
+		case "delete":
+			return deleteAccountCommand(args[2:])
+		case "wipe":
+			return wipeAccountCommand(args[2:])
+		}
+	case "profile":
+		if len(args) < 2 {
+			break
+		}
+		switch args[1] {
+		case "backup":
+			return backupProfileCommand(args[2:])
+		case "restore":
+			return restoreProfileCommand(args[2:])
+		}
+	case "validate":
+		return validateCommand()
+	}
+
+	fmt.Fprint(os.Stderr, usage)
+	return ExitUsage
+}
+
+func createAccountCommand(args []string) int {
+	flags := flag.NewFlagSet("account create", flag.ContinueOnError)
+	username := flags.String("username", "", "account username")
+	password := flags.String("password", "", "account password")
+	edition := flags.String("edition", "edge of darkness", "game edition")
+	lang := flags.String("lang", "en", "account language")
+	if err := flags.Parse(args); err != nil || *username == "" || *password == "" {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	profile, err := data.RegisterAccount(*username, *password, *edition, *lang)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+
+	fmt.Println("Account created:", profile.Account.UID)
+	return ExitOK
+}
+
+func listAccountsCommand() int {
+	accounts := make([]*data.Account, 0)
+	data.GetProfiles().ForEach(func(_ string, profile *data.Profile) bool {
+		if profile.Account != nil {
+			accounts = append(accounts, profile.Account)
+		}
+		return true
+	})
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].AID < accounts[j].AID })
+
+	for _, account := range accounts {
+		nickname := ""
+		if character, err := data.GetCharacterByID(account.UID); err == nil && character != nil {
+			nickname = character.Info.Nickname
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", account.AID, account.UID, account.Username, account.Edition, nickname)
+	}
+	return ExitOK
+}
+
+func deleteAccountCommand(args []string) int {
+	account, code := getAccountFromFlags("account delete", args)
+	if account == nil {
+		return code
+	}
+
+	if err := data.DeleteAccount(account.UID); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+
+	fmt.Println("Account deleted:", account.UID)
+	return ExitOK
+}
+
+func wipeAccountCommand(args []string) int {
+	account, code := getAccountFromFlags("account wipe", args)
+	if account == nil {
+		return code
+	}
+
+	if err := account.WipeProfile(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+
+	fmt.Println("Account wiped:", account.UID)
+	return ExitOK
+}
+
+func backupProfileCommand(args []string) int {
+	flags := flag.NewFlagSet("profile backup", flag.ContinueOnError)
+	username := flags.String("username", "", "account username")
+	out := flags.String("out", "", "zip file to write the backup to")
+	if err := flags.Parse(args); err != nil || *username == "" || *out == "" {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	account, err := data.GetAccountByUsername(*username)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := data.BackupProfile(account.UID, buffer); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+	if err := os.WriteFile(*out, buffer.Bytes(), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+
+	fmt.Println("Profile backed up to", *out)
+	return ExitOK
+}
+
+func restoreProfileCommand(args []string) int {
+	flags := flag.NewFlagSet("profile restore", flag.ContinueOnError)
+	in := flags.String("in", "", "zip file created by profile backup")
+	if err := flags.Parse(args); err != nil || *in == "" {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+
+	account, err := data.RestoreProfile(file, info.Size())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitFailure
+	}
+
+	fmt.Println("Profile restored:", account.UID, account.Username)
+	return ExitOK
+}
+
+func validateCommand() int {
+	problems := data.ValidateProfiles()
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	if len(problems) != 0 {
+		return ExitFailure
+	}
+
+	fmt.Println("Database and profiles are valid")
+	return ExitOK
+}
+
+func getAccountFromFlags(name string, args []string) (*data.Account, int) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	username := flags.String("username", "", "account username")
+	if err := flags.Parse(args); err != nil || *username == "" {
+		flags.Usage()
+		return nil, ExitUsage
+	}
+
+	account, err := data.GetAccountByUsername(*username)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, ExitFailure
+	}
+	return account, ExitOK
+}
@@ -1,10 +1,13 @@
 package data
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"mtgo/tools"
+	"os"
 	"path/filepath"
+	"sync"
 )
 
 func GetAccountByID(uid string) (*Account, error) {
@@ -20,6 +23,142 @@ func GetAccountByID(uid string) (*Account, error) {
 	return nil, fmt.Errorf(accountNotExist, uid)
 }
 
+// GetAccountByUsername returns the account registered with the username
+func GetAccountByUsername(username string) (*Account, error) {
+	var account *Account
+	db.profile.ForEach(func(_ string, profile *Profile) bool {
+		if profile.Account == nil || profile.Account.Username != username {
+			return true
+		}
+		account = profile.Account
+		return false
+	})
+
+	if account == nil {
+		return nil, fmt.Errorf(usernameNotExist, username)
+	}
+	return account, nil
+}
+
+// IsUsernameTaken returns whether an account is already registered with the username
+func IsUsernameTaken(username string) bool {
+	_, err := GetAccountByUsername(username)
+	return err == nil
+}
+
+// registerMu keeps concurrent registrations from taking the same username or AID
+var registerMu sync.Mutex
+
+// RegisterAccount creates and saves the account along with an empty profile
+func RegisterAccount(username string, password string, edition string, lang string) (*Profile, error) {
+	if username == "" {
+		return nil, errors.New("username can not be empty")
+	}
+
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	if IsUsernameTaken(username) {
+		return nil, fmt.Errorf(usernameTaken, username)
+	}
+	if _, err := GetEditionByName(edition); err != nil {
+		return nil, err
+	}
+	if _, err := GetLocaleByName(lang); err != nil {
+		return nil, err
+	}
+
+	uid := tools.GenerateMongoID()
+	account := &Account{
+		AID:      getNextAID(),
+		UID:      uid,
+		Username: username,
+		Edition:  edition,
+		Lang:     lang,
+	}
+	if err := account.SetPassword(password); err != nil {
+		return nil, err
+	}
+
+	profile := &Profile{
+		Account: account,
+		Character: &Character[map[string]PlayerTradersInfo]{
+			ID: uid,
+		},
+		Friends:  new(Friends).CreateFriends(),
+		Storage:  new(Storage).CreateStorage(),
+		Dialogue: new(Dialogue),
+		Mailbox:  new(Mailbox),
+	}
+
+	db.profile.Set(uid, profile)
+	profile.SaveProfile()
+	return profile, nil
+}
+
+// getNextAID returns the AID after the highest one registered, so AIDs are not reused after an account is deleted
+func getNextAID() int {
+	next := 0
+	db.profile.ForEach(func(_ string, profile *Profile) bool {
+		if profile.Account != nil {
+			next = max(next, profile.Account.AID+1)
+		}
+		return true
+	})
+	return next
+}
+
+// WipeProfile resets the account's character, storage and dialogue, so a new character is created on next login
+func (a *Account) WipeProfile() error {
+	profile, err := GetProfileByUID(a.UID)
+	if err != nil {
+		return err
+	}
+
+	profile.Lock()
+	defer profile.Unlock()
+	a.wipeProfile(profile)
+	return nil
+}
+
+// ChangeEdition switches the account to the edition and wipes its profile, so the next character starts with the
+// edition's stash
+func (a *Account) ChangeEdition(edition string) error {
+	profile, err := GetProfileByUID(a.UID)
+	if err != nil {
+		return err
+	}
+
+	profile.Lock()
+	defer profile.Unlock()
+	a.Edition = edition
+	a.wipeProfile(profile)
+	return nil
+}
+
+func (a *Account) wipeProfile(profile *Profile) {
+	a.Wipe = true
+	profile.Character = &Character[map[string]PlayerTradersInfo]{}
+	profile.Storage = new(Storage).CreateStorage()
+	profile.Dialogue = &Dialogue{}
+	if err := profile.Mailbox.ClearMailbox(a.UID); err != nil {
+		log.Println(err)
+	}
+	profile.SaveProfile()
+}
+
+// DeleteAccount removes the account and every file of its profile
+func DeleteAccount(uid string) error {
+	if _, err := GetProfileByUID(uid); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(filepath.Join(profilesPath, uid)); err != nil {
+		return err
+	}
+	db.profile.Del(uid)
+	return nil
+}
+
 func (a *Account) SaveAccount() error {
 	accountFilePath := filepath.Join(profilesPath, a.UID, "account.json")
 
@@ -30,9 +169,39 @@ func (a *Account) SaveAccount() error {
 	return nil
 }
 
+// SetPassword stores the salted hash of the password
+func (a *Account) SetPassword(password string) error {
+	hash, err := tools.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	a.Password = hash
+	return nil
+}
+
+// CheckPassword returns whether the password matches the account's
+func (a *Account) CheckPassword(password string) bool {
+	return tools.VerifyPassword(password, a.Password)
+}
+
+// migratePassword hashes the password of accounts saved before passwords were hashed, returning whether it did
+func (a *Account) migratePassword() bool {
+	if tools.IsPasswordHash(a.Password) {
+		return false
+	}
+
+	if err := a.SetPassword(a.Password); err != nil {
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
 const (
-	accountNotSaved string = "Account for %s was not saved: %s"
-	accountNotExist string = "Account for %s does not exist"
+	accountNotSaved  string = "Account for %s was not saved: %s"
+	accountNotExist  string = "Account for %s does not exist"
+	usernameTaken    string = "Username %s is taken"
+	usernameNotExist string = "Account with username %s does not exist"
 )
 
 // #region Account structs
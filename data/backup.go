@@ -0,0 +1,261 @@
+package data
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"mtgo/tools"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	backupEntryInvalid string = "Backup entry %s is not a profile file"
+	backupUIDInvalid   string = "Backup account has an invalid UID %q"
+	backupUsernameUsed string = "Username %s of the backup belongs to another account"
+	backupNotFound     string = "Profile %s has no valid backup to restore"
+)
+
+const (
+	profileCorrupt  string = "Profile %s has corrupt files %v\n"
+	profileSkipped  string = "Profile %s is not loaded, restoring backups is disabled\n"
+	profileRestored string = "Profile %s restored from backup %s\n"
+)
+
+const (
+	profileBackupsDir string = "backups"
+	backupTimeFormat  string = "20060102-150405"
+)
+
+// profileFiles are the files of a profile directory, with the type each one decodes into
+var profileFiles = map[string]func() any{
+	"account.json":   func() any { return new(Account) },
+	"character.json": func() any { return new(Character[map[string]PlayerTradersInfo]) },
+	"storage.json":   func() any { return new(Storage) },
+	"dialogue.json":  func() any { return new(Dialogue) },
+	"friends.json":   func() any { return new(Friends) },
+	"mailbox.json":   func() any { return new(Mailbox) },
+}
+
+// BackupProfile writes every file of the profile into a zip archive
+func BackupProfile(uid string, w io.Writer) error {
+	files, err := tools.GetFilesFrom(filepath.Join(profilesPath, uid))
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	for name := range files {
+		if filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		raw, err := tools.ReadFile(filepath.Join(profilesPath, uid, name))
+		if err != nil {
+			return err
+		}
+
+		entry, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(raw); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// RestoreProfile writes the profile files of a zip archive created by BackupProfile into the directory of the
+// profile it was taken from, returning the restored account
+func RestoreProfile(r io.ReaderAt, size int64) (*Account, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(archive.File))
+	for _, file := range archive.File {
+		if file.Name != filepath.Base(file.Name) || strings.ContainsAny(file.Name, `/\`) || filepath.Ext(file.Name) != ".json" {
+			return nil, fmt.Errorf(backupEntryInvalid, file.Name)
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		raw, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[file.Name] = raw
+	}
+
+	account := new(Account)
+	if err := json.UnmarshalNoEscape(files["account.json"], account); err != nil {
+		return nil, err
+	}
+	if account.UID == "" || account.UID != filepath.Base(account.UID) {
+		return nil, fmt.Errorf(backupUIDInvalid, account.UID)
+	}
+	if existing, err := GetAccountByUsername(account.Username); err == nil && existing.UID != account.UID {
+		return nil, fmt.Errorf(backupUsernameUsed, account.Username)
+	}
+
+	profileDirPath := filepath.Join(profilesPath, account.UID)
+	if err := os.MkdirAll(profileDirPath, 0755); err != nil {
+		return nil, err
+	}
+	for name, raw := range files {
+		if err := tools.WriteBytesToFile(filepath.Join(profileDirPath, name), raw); err != nil {
+			return nil, err
+		}
+	}
+	return account, nil
+}
+
+// isProfileFileValid returns whether the profile file exists and decodes into its type
+func isProfileFileValid(path string) bool {
+	raw, err := tools.ReadFile(path)
+	if err != nil || len(raw) == 0 {
+		return false
+	}
+
+	output, ok := profileFiles[filepath.Base(path)]
+	if !ok {
+		return json.Valid(raw)
+	}
+	return json.UnmarshalNoEscape(raw, output()) == nil
+}
+
+// getCorruptProfileFiles returns the names of the files in the profile directory that exist but can not be decoded
+func getCorruptProfileFiles(profileDirPath string) []string {
+	corrupt := make([]string, 0)
+	for name := range profileFiles {
+		path := filepath.Join(profileDirPath, name)
+		if tools.FileExist(path) && !isProfileFileValid(path) {
+			corrupt = append(corrupt, name)
+		}
+	}
+	slices.Sort(corrupt)
+	return corrupt
+}
+
+// getProfileBackups returns the paths of the profile's backups, newest first
+func getProfileBackups(uid string) []string {
+	backupsPath := filepath.Join(profilesPath, uid, profileBackupsDir)
+	if !tools.FileExist(backupsPath) {
+		return nil
+	}
+
+	directories, err := tools.GetDirectoriesFrom(backupsPath)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	backups := make([]string, 0, len(directories))
+	for name := range directories {
+		backups = append(backups, name)
+	}
+	slices.Sort(backups)
+	slices.Reverse(backups)
+
+	for i, name := range backups {
+		backups[i] = filepath.Join(backupsPath, name)
+	}
+	return backups
+}
+
+// rotateProfileBackups copies the profile's files into a new timestamped backup, then removes the oldest backups
+// beyond the configured number to keep
+func rotateProfileBackups(uid string) error {
+	keep := db.core.ServerConfig.ProfileBackups
+	if keep <= 0 {
+		return nil
+	}
+
+	profileDirPath := filepath.Join(profilesPath, uid)
+	backupPath := filepath.Join(profileDirPath, profileBackupsDir, time.Now().Format(backupTimeFormat))
+	for name := range profileFiles {
+		path := filepath.Join(profileDirPath, name)
+		if !tools.FileExist(path) {
+			continue
+		}
+
+		raw, err := tools.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := tools.WriteBytesToFile(filepath.Join(backupPath, name), raw); err != nil {
+			return err
+		}
+	}
+
+	backups := getProfileBackups(uid)
+	for _, backup := range backups[min(keep, len(backups)):] {
+		if err := os.RemoveAll(backup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreProfileBackup writes the files of the profile's newest backup whose files are all valid over the profile's
+// files, returning the path of the backup restored
+func restoreProfileBackup(uid string) (string, error) {
+	profileDirPath := filepath.Join(profilesPath, uid)
+	for _, backup := range getProfileBackups(uid) {
+		if !isProfileFileValid(filepath.Join(backup, "account.json")) || len(getCorruptProfileFiles(backup)) != 0 {
+			continue
+		}
+
+		for name := range profileFiles {
+			path := filepath.Join(backup, name)
+			if !tools.FileExist(path) {
+				continue
+			}
+
+			raw, err := tools.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			if err := tools.WriteBytesToFile(filepath.Join(profileDirPath, name), raw); err != nil {
+				return "", err
+			}
+		}
+		return backup, nil
+	}
+	return "", fmt.Errorf(backupNotFound, uid)
+}
+
+// recoverProfile restores the profile from its newest valid backup when any of its files are corrupt, returning
+// whether the profile can be loaded
+func recoverProfile(uid string) bool {
+	corrupt := getCorruptProfileFiles(filepath.Join(profilesPath, uid))
+	if len(corrupt) == 0 {
+		return true
+	}
+
+	log.Printf(profileCorrupt, uid, corrupt)
+	if !db.core.ServerConfig.RestoreBackups {
+		log.Printf(profileSkipped, uid)
+		return false
+	}
+
+	backup, err := restoreProfileBackup(uid)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	log.Printf(profileRestored, uid, backup)
+	return true
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"mtgo/tools"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/goccy/go-json"
 )
@@ -107,9 +109,263 @@ func setBotType(dirPath string) *BotType {
 		}
 	}
 
+	inventoryPath := filepath.Join(dirPath, "inventory.json")
+	if (botType.Loadout == nil || botType.Loadout.IsEmpty()) && tools.FileExist(inventoryPath) {
+		inventory := new(BotInventory)
+		raw := tools.GetJSONRawMessage(inventoryPath)
+		if err = json.Unmarshal(raw, inventory); err != nil {
+			log.Println(err)
+			return nil
+		}
+		botType.Loadout = inventory.getBotLoadout()
+	}
+
+	chancesPath := filepath.Join(dirPath, "chances.json")
+	if tools.FileExist(chancesPath) {
+		raw := tools.GetJSONRawMessage(chancesPath)
+		if err = json.Unmarshal(raw, &botType.Chances); err != nil {
+			log.Println(err)
+			return nil
+		}
+	}
+
 	return botType
 }
 
+const (
+	// botInventoryTPL is the equipment template whose slots the derived loadout is drawn from
+	botInventoryTPL string = "55d7217a4bdc2d86028b456d"
+	// botLootMaxCells is the most grid cells a piece of derived loot takes
+	botLootMaxCells int = 2
+)
+
+// botLootCategories are the item nodes the derived loot is drawn from: barter items, meds, food and drinks,
+// info items and keys
+var botLootCategories = []string{
+	"5448eb774bdc2d0a728b4567",
+	"543be5664bdc2dd4348b4569",
+	"543be6674bdc2df1348b4569",
+	"5448ecbe4bdc2d60728b4568",
+	"543be5e94bdc2df1348b4568",
+}
+
+// defaultBotChances are the chances of bot types that ship without a chances.json
+var defaultBotChances = &BotChances{
+	Equipment: map[string]int{
+		"Earpiece":            15,
+		"Headwear":            60,
+		"FaceCover":           25,
+		"ArmorVest":           40,
+		"TacticalVest":        95,
+		"Backpack":            35,
+		"FirstPrimaryWeapon":  100,
+		"SecondPrimaryWeapon": 0,
+		"Holster":             10,
+		"Scabbard":            100,
+		"Pockets":             100,
+	},
+}
+
+// botItemPools holds what bots are generated from, built once by SetBotItemPools: the items every slot, chamber
+// and cartridge filter of the database expands to, and the loadout of bot types that ship none
+var botItemPools struct {
+	filters map[string][]string
+	loadout *BotLoadout
+}
+
+// SetBotItemPools expands every slot, chamber and cartridge filter of the item database and derives the shared
+// bot loadout; it runs once custom items are loaded and the handbook is indexed
+func SetBotItemPools() {
+	children := make(map[string][]string)
+	db.item.ForEach(func(id string, item *DatabaseItem) bool {
+		children[item.Parent] = append(children[item.Parent], id)
+		return true
+	})
+
+	nodes := make(map[string][]string)
+	var expand func(id string) []string
+	expand = func(id string) []string {
+		if output, ok := nodes[id]; ok {
+			return output
+		}
+		nodes[id] = nil
+
+		output := make([]string, 0)
+		if item, ok := db.item.Get(id); ok && item.Type == "Item" {
+			output = append(output, id)
+		}
+		for _, child := range children[id] {
+			output = append(output, expand(child)...)
+		}
+		nodes[id] = output
+		return output
+	}
+
+	filters := make(map[string][]string)
+	addFilter := func(filter []string) {
+		key := getBotFilterKey(filter)
+		if _, ok := filters[key]; ok || len(filter) == 0 {
+			return
+		}
+
+		output := make([]string, 0)
+		for _, id := range filter {
+			output = append(output, expand(id)...)
+		}
+		slices.Sort(output)
+		filters[key] = slices.Compact(output)
+	}
+
+	db.item.ForEach(func(_ string, item *DatabaseItem) bool {
+		if slots, ok := item.Props["Slots"].([]any); ok && len(slots) != 0 {
+			for _, slot := range item.GetItemSlots() {
+				addFilter(slot.GetFilter())
+			}
+		}
+		for _, chamber := range item.GetItemChambers() {
+			addFilter(chamber.GetFilter())
+		}
+		if cartridges := item.GetItemCartridges(); cartridges != nil {
+			addFilter(cartridges.GetFilter())
+		}
+		return true
+	})
+	addFilter(botLootCategories)
+
+	botItemPools.filters = filters
+	botItemPools.loadout = deriveBotLoadout()
+}
+
+func getBotFilterKey(filter []string) string {
+	return strings.Join(filter, ",")
+}
+
+// GetBotFilterItems returns the sorted IDs of the items the filter expands to, from the pools built at startup
+func GetBotFilterItems(filter []string) []string {
+	if len(filter) == 0 {
+		return nil
+	}
+	if output, ok := botItemPools.filters[getBotFilterKey(filter)]; ok {
+		return output
+	}
+	return GetItemsByFilter(filter, nil)
+}
+
+// GetBotLoadout returns the loadout of the bot type, from its loadout.json or inventory.json, or the one derived
+// from the item database if it ships neither
+func (b *BotType) GetBotLoadout() *BotLoadout {
+	if b.Loadout != nil && !b.Loadout.IsEmpty() {
+		return b.Loadout
+	}
+	return botItemPools.loadout
+}
+
+// GetBotChances returns the chances of the bot type, from its chances.json or the default ones
+func (b *BotType) GetBotChances() *BotChances {
+	if b.Chances != nil {
+		return b.Chances
+	}
+	return defaultBotChances
+}
+
+// IsEmpty returns whether the loadout has no equipment
+func (l *BotLoadout) IsEmpty() bool {
+	return len(l.Headerwear) == 0 && len(l.BodyArmor) == 0 && len(l.Vest) == 0 && len(l.Backpack) == 0 &&
+		len(l.PrimaryWeapon) == 0 && len(l.Holster) == 0 && len(l.Melee) == 0
+}
+
+// getSlots returns the loadout's equipment lists by the equipment slot they go in
+func (l *BotLoadout) getSlots() map[string]*[]string {
+	return map[string]*[]string{
+		"Earpiece":            &l.Earpiece,
+		"Headwear":            &l.Headerwear,
+		"FaceCover":           &l.Facecover,
+		"ArmorVest":           &l.BodyArmor,
+		"TacticalVest":        &l.Vest,
+		"Backpack":            &l.Backpack,
+		"FirstPrimaryWeapon":  &l.PrimaryWeapon,
+		"SecondPrimaryWeapon": &l.SecondaryWeapon,
+		"Holster":             &l.Holster,
+		"Scabbard":            &l.Melee,
+		"Pockets":             &l.Pocket,
+	}
+}
+
+// getBotLoadout turns the inventory into a loadout; the templates of the containers become the loot
+func (i *BotInventory) getBotLoadout() *BotLoadout {
+	loadout := new(BotLoadout)
+	for name, field := range loadout.getSlots() {
+		*field = sortedBotTemplates(i.Equipment[name])
+	}
+
+	for _, container := range []string{"TacticalVest", "Pockets", "Backpack"} {
+		loadout.Loot = append(loadout.Loot, sortedBotTemplates(i.Items[container])...)
+	}
+	slices.Sort(loadout.Loot)
+	loadout.Loot = slices.Compact(loadout.Loot)
+	return loadout
+}
+
+func sortedBotTemplates(weights map[string]float64) []string {
+	output := make([]string, 0, len(weights))
+	for tpl := range weights {
+		output = append(output, tpl)
+	}
+	slices.Sort(output)
+	return output
+}
+
+// deriveBotLoadout fills every equipment slot with the priced, non-quest items the bot inventory slot accepts, and
+// the loot with small items of the bot loot categories
+func deriveBotLoadout() *BotLoadout {
+	loadout := new(BotLoadout)
+	inventory, ok := db.item.Get(botInventoryTPL)
+	if !ok {
+		return loadout
+	}
+
+	fields := loadout.getSlots()
+	delete(fields, "Pockets")
+	for name, slot := range inventory.GetItemSlots() {
+		field, ok := fields[name]
+		if !ok {
+			continue
+		}
+		*field = slices.DeleteFunc(slices.Clone(GetBotFilterItems(slot.GetFilter())), func(id string) bool {
+			return !isBotLoadoutItem(id)
+		})
+	}
+
+	for _, id := range GetBotFilterItems(botLootCategories) {
+		if !isBotLoadoutItem(id) {
+			continue
+		}
+		item, _ := db.item.Get(id)
+		if height, width := item.GetItemSize(); int(height)*int(width) <= botLootMaxCells {
+			loadout.Loot = append(loadout.Loot, id)
+		}
+	}
+	return loadout
+}
+
+// isBotLoadoutItem returns whether a bot can be given the item: it has a handbook price and is neither blacklisted
+// nor a quest item
+func isBotLoadoutItem(id string) bool {
+	if _, blacklisted := IsItemBlacklist(id); blacklisted {
+		return false
+	}
+
+	item, ok := db.item.Get(id)
+	if !ok {
+		return false
+	}
+	if questItem, _ := item.Props["QuestItem"].(bool); questItem {
+		return false
+	}
+	_, err := item.GetItemPrice()
+	return err == nil
+}
+
 type DummyBot map[string]any
 
 func GetSacrificialBot() DummyBot {
@@ -153,6 +409,80 @@ func GetBotTypeDifficultyByName(name string, diff string) (any, error) {
 	return difficulty, nil
 }
 
+// GetBotAppearanceByRole returns the appearance for the role, falling back to the scav or random appearance
+func GetBotAppearanceByRole(role string) *BotAppearance {
+	for name, appearance := range db.bot.BotAppearance {
+		if strings.EqualFold(name, role) {
+			return appearance
+		}
+	}
+
+	if isScavRole(role) {
+		if appearance, ok := db.bot.BotAppearance["scav"]; ok {
+			return appearance
+		}
+	}
+	return db.bot.BotAppearance["random"]
+}
+
+// GetBotNamesByRole returns the list of names the role can spawn with
+func GetBotNamesByRole(role string) []string {
+	names := db.bot.BotNames
+	switch strings.ToLower(role) {
+	case "bossgluhar":
+		return names.BossGluhar
+	case "bosszryachiy":
+		return names.BossZryachiy
+	case "followerzryachiy":
+		return names.FollowerZryachiy
+	case "bosskilla":
+		return names.BossKilla
+	case "bossbully":
+		return names.BossBully
+	case "followerbully":
+		return names.FollowerBully
+	case "bosskojaniy":
+		return names.BossKojaniy
+	case "followerkojaniy":
+		return names.FollowerKojaniy
+	case "bosssanitar":
+		return names.BossSanitar
+	case "followersanitar":
+		return names.FollowerSanitar
+	case "bosstagilla":
+		return names.BossTagilla
+	case "followertagilla":
+		return names.FollowerTagilla
+	case "followerbigpipe":
+		return names.FollowerBigPipe
+	case "followerbirdeye":
+		return names.FollowerBirdEye
+	case "bossknight":
+		return names.BossKnight
+	case "gifter":
+		return names.Gifter
+	case "sectantpriest":
+		return names.Sectantpriest
+	case "sectantwarrior":
+		return names.Sectantwarrior
+	case "followergluharassault", "followergluharscout", "followergluharsecurity", "followergluharsnipe":
+		return names.GeneralFollower
+	}
+
+	if isScavRole(role) {
+		return names.Scav
+	}
+	return names.Normal
+}
+
+func isScavRole(role string) bool {
+	switch strings.ToLower(role) {
+	case "assault", "assaultgroup", "marksman", "cursedassault", "crazyassaultevent":
+		return true
+	}
+	return false
+}
+
 type Bots struct {
 	BotTypes      map[string]*BotType
 	BotAppearance map[string]*BotAppearance
@@ -195,6 +525,21 @@ type BotType struct {
 	Difficulties map[string]map[string]any `json:"difficulties,omitempty"`
 	Health       map[string]any            `json:"health,omitempty"`
 	Loadout      *BotLoadout               `json:"loadout,omitempty"`
+	Chances      *BotChances               `json:"chances,omitempty"`
+}
+
+// BotInventory is a bot type's inventory.json: the weighted templates of each equipment slot and container
+type BotInventory struct {
+	Equipment map[string]map[string]float64 `json:"equipment"`
+	Items     map[string]map[string]float64 `json:"items"`
+}
+
+// BotChances is a bot type's chances.json: the percent chance of each equipment, weapon mod and equipment mod slot
+// being filled
+type BotChances struct {
+	Equipment     map[string]int `json:"equipment"`
+	WeaponMods    map[string]int `json:"weaponMods"`
+	EquipmentMods map[string]int `json:"equipmentMods"`
 }
 
 type BotLoadout struct {
@@ -209,6 +554,7 @@ type BotLoadout struct {
 	Holster         []string `json:"holster,omitempty"`
 	Melee           []string `json:"melee,omitempty"`
 	Pocket          []string `json:"pocket,omitempty"`
+	Loot            []string `json:"loot,omitempty"`
 }
 
 // #endregion
@@ -50,14 +50,34 @@ func GetTraderCacheByID(uid string) (*TraderCache, error) {
 
 	//log.Printf("trader cache for %s does not exist, creating...\n", uid)
 	cache.Traders = &TraderCache{
-		Index:      make(map[string]*AssortIndex),
-		Assorts:    make(map[string]*Assort),
-		Insurances: make(map[string]*Insurances),
+		Index:       make(map[string]*AssortIndex),
+		Assorts:     make(map[string]*Assort),
+		Insurances:  make(map[string]*Insurances),
+		Generations: make(map[string]int),
 	}
 
 	return cache.Traders, nil
 }
 
+// ResetAssort drops the cached assort of the trader, so it is stripped again on the next request
+func (tc *TraderCache) ResetAssort(traderID string) {
+	delete(tc.Index, traderID)
+	delete(tc.Assorts, traderID)
+}
+
+// ResetTraderAssorts drops every cached assort of the player, after a change that can lock or unlock entries
+func ResetTraderAssorts(uid string) {
+	cache, err := GetTraderCacheByID(uid)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for traderID := range cache.Assorts {
+		cache.ResetAssort(traderID)
+	}
+}
+
 func GetInventoryCacheByID(uid string) (*InventoryContainer, error) {
 	cache, err := GetCacheByID(uid)
 	if err != nil {
@@ -639,6 +659,11 @@ func GetProfileChangesEvent(id string) *ProfileChangesEvent {
 	emptiedProfileChange.Skills = character.Skills
 	emptiedProfileChange.Health = character.Health
 
+	if cache, err := GetQuestCacheByID(id); err == nil && len(cache.Pending) != 0 {
+		emptiedProfileChange.QuestsStatus = append(emptiedProfileChange.QuestsStatus, cache.Pending...)
+		cache.Pending = nil
+	}
+
 	db.cache.profileChanges.ProfileChanges.Set(id, emptiedProfileChange)
 
 	return db.cache.profileChanges
@@ -671,7 +696,7 @@ type ProfileChanges struct {
 	WeaponBuilds          []any                        `json:"weaponBuilds"`
 	EquipmentBuilds       []any                        `json:"equipmentBuilds"`
 	Items                 ItemChanges                  `json:"items"`
-	Production            *map[string]any              `json:"production"`
+	Production            *map[string]*Production      `json:"production"`
 	Improvements          map[string]any               `json:"improvements"`
 	Skills                PlayerSkills                 `json:"skills"`
 	Health                HealthInfo                   `json:"health"`
@@ -682,10 +707,12 @@ type ProfileChanges struct {
 }
 
 type ServerData struct {
-	websocket     *haxmap.Map[string, *Connect]
-	core          *serverData
-	servers       []ServerListing
-	playerRaidMap *haxmap.Map[string, string]
+	websocket       *haxmap.Map[string, *Connect]
+	notifierWaiters *haxmap.Map[string, chan struct{}]
+	sessions        *haxmap.Map[string, *Session]
+	core            *serverData
+	servers         []ServerListing
+	playerRaidMap   *haxmap.Map[string, string]
 }
 
 func GetPlayerMap(session string) string {
@@ -911,9 +938,11 @@ type HideoutCache struct {
 }
 
 type TraderCache struct {
-	Index      map[string]*AssortIndex
-	Assorts    map[string]*Assort
-	Insurances map[string]*Insurances
+	Index       map[string]*AssortIndex
+	Assorts     map[string]*Assort
+	Insurances  map[string]*Insurances
+	FenceLevel  int
+	Generations map[string]int
 }
 
 type Insurances struct {
@@ -924,6 +953,8 @@ type Insurances struct {
 
 type QuestCache struct {
 	Index map[string]int8
+	// Pending are quest statuses changed outside of a profile changes event, sent with the next one
+	Pending []CharacterQuest
 }
 
 type InventoryContainer struct {
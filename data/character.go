@@ -139,6 +139,17 @@ func (c Character[T]) IsPreviousQuestComplete(quests map[string]*QuestCondition,
 	return previousQuestCompleted
 }
 
+// ArePreviousQuestsComplete checks if every quest the conditions depend on has the required status
+func (c Character[T]) ArePreviousQuestsComplete(quests map[string]*QuestCondition, cachedQuests *QuestCache) bool {
+	for _, v := range quests {
+		index, ok := cachedQuests.Index[v.PreviousQuestID]
+		if !ok || v.Status != c.Quests[index].Status {
+			return false
+		}
+	}
+	return len(quests) != 0
+}
+
 func (inv *Inventory) CleanInventoryOfDeletedItemMods() bool {
 	allItems := GetItems()
 
@@ -178,27 +189,28 @@ const (
 )
 
 type Character[T TradersInfo] struct {
-	ID                    string              `json:"_id"`
-	AID                   int                 `json:"aid"`
-	Savage                *string             `json:"savage"`
-	Info                  PlayerInfo          `json:"Info"`
-	Customization         PlayerCustomization `json:"Customization"`
-	Health                HealthInfo          `json:"Health"`
-	Inventory             Inventory           `json:"Inventory"`
-	Skills                PlayerSkills        `json:"Skills"`
-	Stats                 PlayerStats         `json:"Stats"`
-	Encyclopedia          map[string]bool     `json:"Encyclopedia"`
-	ConditionCounters     ConditionCounters   `json:"ConditionCounters"`
-	TaskConditionCounters map[string]any      `json:"TaskConditionCounters"`
-	InsuredItems          []InsuredItem       `json:"InsuredItems"`
-	Hideout               *PlayerHideout      `json:"Hideout"`
-	Bonuses               []Bonus             `json:"Bonuses"`
-	Notes                 Notes               `json:"Notes"`
-	Quests                []CharacterQuest    `json:"Quests"`
-	RagfairInfo           PlayerRagfairInfo   `json:"RagfairInfo"`
-	WishList              []string            `json:"WishList"`
-	TradersInfo           T                   `json:"TradersInfo"`
-	UnlockedInfo          Unlocked            `json:"UnlockedInfo"`
+	ID                    string               `json:"_id"`
+	AID                   int                  `json:"aid"`
+	Savage                *string              `json:"savage"`
+	Info                  PlayerInfo           `json:"Info"`
+	Customization         PlayerCustomization  `json:"Customization"`
+	Health                HealthInfo           `json:"Health"`
+	Inventory             Inventory            `json:"Inventory"`
+	Skills                PlayerSkills         `json:"Skills"`
+	Stats                 PlayerStats          `json:"Stats"`
+	Encyclopedia          map[string]bool      `json:"Encyclopedia"`
+	ConditionCounters     ConditionCounters    `json:"ConditionCounters"`
+	TaskConditionCounters map[string]any       `json:"TaskConditionCounters"`
+	InsuredItems          []InsuredItem        `json:"InsuredItems"`
+	Hideout               *PlayerHideout       `json:"Hideout"`
+	Bonuses               []Bonus              `json:"Bonuses"`
+	Notes                 Notes                `json:"Notes"`
+	Quests                []CharacterQuest     `json:"Quests"`
+	RepeatableQuests      []RepeatableQuestSet `json:"RepeatableQuests,omitempty"`
+	RagfairInfo           PlayerRagfairInfo    `json:"RagfairInfo"`
+	WishList              []string             `json:"WishList"`
+	TradersInfo           T                    `json:"TradersInfo"`
+	UnlockedInfo          Unlocked             `json:"UnlockedInfo"`
 }
 
 type TradersInfo interface {
@@ -231,7 +243,7 @@ type PlayerTradersInfo struct {
 type PlayerRagfairInfo struct {
 	Rating          float32 `json:"rating"`
 	IsRatingGrowing bool    `json:"isRatingGrowing"`
-	Offers          []any   `json:"offers"`
+	Offers          []Offer `json:"offers"`
 }
 
 type PlayerHideoutArea struct {
@@ -246,10 +258,25 @@ type PlayerHideoutArea struct {
 	LastRecipe            string `json:"lastRecipe"`
 }
 type PlayerHideout struct {
-	Production  map[string]any      `json:"Production"`
-	Areas       []PlayerHideoutArea `json:"Areas"`
-	Improvement map[string]any      `json:"Improvement"`
-	Seed        int                 `json:"Seed,omitempty"`
+	Production  map[string]*Production `json:"Production"`
+	Areas       []PlayerHideoutArea    `json:"Areas"`
+	Improvement map[string]any         `json:"Improvement"`
+	Seed        int                    `json:"Seed,omitempty"`
+}
+
+type Production struct {
+	Progress                     float64         `json:"Progress"`
+	InProgress                   bool            `json:"inProgress"`
+	RecipeID                     string          `json:"RecipeId"`
+	Products                     []InventoryItem `json:"Products"`
+	SkipTime                     float64         `json:"SkipTime"`
+	ProductionTime               float64         `json:"ProductionTime"`
+	StartTimestamp               string          `json:"StartTimestamp"`
+	GivenItemsInStart            []InventoryItem `json:"GivenItemsInStart"`
+	Interrupted                  bool            `json:"Interrupted"`
+	NeedFuelForAllProductionTime bool            `json:"needFuelForAllProductionTime"`
+	// UpdateTimestamp is when Progress was last brought up to date
+	UpdateTimestamp int64 `json:"UpdateTimestamp,omitempty"`
 }
 
 type ConditionCounters struct {
@@ -83,6 +83,10 @@ func setCore() {
 		setGlobals()
 		done <- struct{}{}
 	}()
+	go func() {
+		setRepeatableQuestConfigs()
+		done <- struct{}{}
+	}()
 	go func() {
 		raw := tools.GetJSONRawMessage(matchMetricsPath)
 		if err := json.UnmarshalNoEscape(raw, &db.core.MatchMetrics); err != nil {
@@ -118,7 +122,7 @@ func setCore() {
 		done <- struct{}{}
 	}()
 
-	for i := 0; i < 8; i++ {
+	for i := 0; i < 9; i++ {
 		<-done
 	}
 }
@@ -135,6 +139,7 @@ type Core struct {
 	Globals           *Globals
 	MatchMetrics      *MatchMetrics
 	AirdropParameters *AirdropParameters
+	RepeatableQuests  []*RepeatableQuestConfig
 	Core              any
 }
 
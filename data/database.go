@@ -19,6 +19,7 @@ const (
 	locationsPath     = databaseLibPath + "/locations"
 	matchMetricsPath  = coreFilePath + "/matchMetrics.json"
 	serverConfigPath  = coreFilePath + "/server.json"
+	repeatablePath    = coreFilePath + "/repeatableQuests.json"
 	editionsDirPath   = databaseLibPath + "/editions/"
 	itemsPath         = databaseLibPath + "/items.json"
 	localesPath       = databaseLibPath + "/locales"
@@ -51,7 +52,7 @@ type database struct {
 	weather       *Weather
 }
 
-var workers = tools.CalculateWorkers() / 3
+var workers = max(tools.CalculateWorkers()/3, 1)
 
 func SetPrimaryDatabase() {
 	db = &database{
@@ -72,8 +73,10 @@ func SetPrimaryDatabase() {
 				channels: haxmap.New[string, Channel](),
 			},
 			server: &ServerData{
-				websocket:     haxmap.New[string, *Connect](),
-				playerRaidMap: haxmap.New[string, string](),
+				websocket:       haxmap.New[string, *Connect](),
+				notifierWaiters: haxmap.New[string, chan struct{}](),
+				sessions:        haxmap.New[string, *Session](),
+				playerRaidMap:   haxmap.New[string, string](),
 			},
 			nicknames: haxmap.New[string, struct{}](),
 			profileChanges: &ProfileChangesEvent{
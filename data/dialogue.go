@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
+	"slices"
 
 	"github.com/goccy/go-json"
 
@@ -35,6 +36,8 @@ type Dialog struct {
 	New            int8            `json:"new"`
 	AttachmentsNew int8            `json:"attachmentsNew"`
 	Users          []DialogUser    `json:"users,omitempty"`
+	Name           string          `json:"name,omitempty"`
+	Owner          string          `json:"owner,omitempty"`
 }
 
 type DialogUser struct {
@@ -58,18 +61,25 @@ type DialogMessage struct {
 	Member              map[string]any `json:"Member,omitempty"`
 	Text                string         `json:"text"`
 	TemplateID          string         `json:"templateId,omitempty"`
-	Items               []any          `json:"items,omitempty"`
+	Items               *MessageItems  `json:"items,omitempty"`
 	HasRewards          bool           `json:"hasRewards"`
 	RewardCollected     bool           `json:"rewardCollected"`
+	IsRead              bool           `json:"isRead"`
 	MaxStorageTime      int32          `json:"maxStorageTime,omitempty"`
 	SystemData          string         `json:"systemData,omitempty"`
 	ProfileChangeEvents []any          `json:"profileChangeEvents"`
 }
 
+type MessageItems struct {
+	Stash string          `json:"stash"`
+	Data  []InventoryItem `json:"data"`
+}
+
 type DialogMessageView struct {
-	Messages              []DialogMessage  `json:"messages"`
-	Profiles              []DialogUserInfo `json:"profile"`
-	HasMessageWithRewards bool             `json:"hasMessageWithRewards"`
+	Messages              []DialogMessage `json:"messages"`
+	Profiles              []DialogUser    `json:"profile"`
+	HasMessageWithRewards bool            `json:"hasMessageWithRewards"`
+	Changes               *ProfileChanges `json:"profileChanges,omitempty"`
 }
 
 type DialogueDetails struct {
@@ -89,7 +99,7 @@ type DialogueInfo struct {
 	AttachmentsNew int8                 `json:"attachmentsNew"`
 	New            int8                 `json:"new"`
 	Pinned         bool                 `json:"pinned"`
-	Users          []DialogUserInfo     `json:"Users,omitempty"`
+	Users          []DialogUser         `json:"Users,omitempty"`
 }
 
 type DialogueInfoMessage struct {
@@ -109,11 +119,7 @@ func (d *Dialog) CreateQuestDialogueInfo() *DialogueInfo {
 		New:            d.New,
 		AttachmentsNew: d.AttachmentsNew,
 		Pinned:         d.Pinned,
-	}
-
-	if d.Users != nil && len(d.Users) > 0 {
-		//TODO: DEAL WITH USERS
-		log.Println("No users were created")
+		Users:          d.CreateDialogueUsers(),
 	}
 
 	return info
@@ -126,18 +132,17 @@ func (d *Dialog) CreateDialogListEntry() *DialogueInfo {
 		AttachmentsNew: d.AttachmentsNew,
 		New:            d.New,
 		Pinned:         d.Pinned,
-	}
-
-	if d.Type == 1 {
-		log.Println("NOT DONE YET")
-	} else {
-		info.Message = d.CreateDialogueInfoMessage()
+		Message:        d.CreateDialogueInfoMessage(),
+		Users:          d.CreateDialogueUsers(),
 	}
 
 	return info
 }
 
 func (d *Dialog) CreateDialogueInfoMessage() *DialogueInfoMessage {
+	if len(d.Messages) == 0 {
+		return nil
+	}
 	message := d.Messages[len(d.Messages)-1]
 
 	return &DialogueInfoMessage{
@@ -149,14 +154,24 @@ func (d *Dialog) CreateDialogueInfoMessage() *DialogueInfoMessage {
 	}
 }
 
-func (d *Dialog) CreateDialogueUsers() []DialogUserInfo {
-	//users := make([]DialogueUsers, 0)
-	return nil
+// CreateDialogueUsers returns the players taking part in a user or group dialog
+func (d *Dialog) CreateDialogueUsers() []DialogUser {
+	if len(d.Users) == 0 {
+		return nil
+	}
+	return slices.Clone(d.Users)
+}
+
+// HasUser returns whether the player of id takes part in the dialog
+func (d *Dialog) HasUser(id string) bool {
+	return slices.ContainsFunc(d.Users, func(user DialogUser) bool {
+		return user.ID == id
+	})
 }
 
 func (d *Dialog) HasMessagesWithRewards() bool {
 	for _, message := range d.Messages {
-		if len(message.Items) > 0 {
+		if message.Items != nil && len(message.Items.Data) > 0 {
 			return true
 		}
 	}
@@ -176,6 +191,51 @@ func (d *Dialog) GetUnreadMessagesWithAttachments() int8 {
 	return attachmentCount
 }
 
+// MarkAsRead marks every message of the dialog as read
+func (d *Dialog) MarkAsRead() {
+	for idx := range d.Messages {
+		d.Messages[idx].IsRead = true
+	}
+	d.New = 0
+	d.AttachmentsNew = d.GetUnreadMessagesWithAttachments()
+}
+
+// RemoveExpired drops the attachments that can no longer be redeemed and the messages older than storageTime
+// seconds, returning whether anything was removed
+func (d *Dialog) RemoveExpired(storageTime int32) bool {
+	now := int32(tools.GetCurrentTimeInSeconds())
+	removed := false
+
+	messages := make([]DialogMessage, 0, len(d.Messages))
+	for _, message := range d.Messages {
+		if message.DT+storageTime <= now {
+			removed = true
+			continue
+		}
+
+		if message.HasRewards && !message.RewardCollected && message.DT+message.MaxStorageTime <= now {
+			message.Items = nil
+			message.HasRewards = false
+			removed = true
+		}
+		messages = append(messages, message)
+	}
+	if !removed {
+		return false
+	}
+
+	d.Messages = messages
+	var unread int8
+	for _, message := range d.Messages {
+		if !message.IsRead {
+			unread++
+		}
+	}
+	d.New = unread
+	d.AttachmentsNew = d.GetUnreadMessagesWithAttachments()
+	return true
+}
+
 func (d *Dialog) GetActiveMessages() []DialogMessage {
 	messages := make([]DialogMessage, 0, len(d.Messages))
 
@@ -193,7 +253,9 @@ func setDialogue(path string) *Dialogue {
 
 	data := tools.GetJSONRawMessage(path)
 	if err := json.UnmarshalNoEscape(data, &output); err != nil {
-		log.Fatalln(err)
+		msg := tools.CheckParsingError(data, err)
+		log.Println(msg)
+		return &Dialogue{}
 	}
 
 	return &output
@@ -223,12 +285,101 @@ func (d Dialogue) SaveDialogue(sessionID string) error {
 	return nil
 }
 
+const (
+	defaultMailRedeemTime  int32 = 48
+	defaultMailStorageTime int32 = 720
+)
+
 const (
 	dialogueNotSaved string = "Dialogue for %s was not saved: %s"
 	dialogueNotExist string = "Dialogue for %s does not exist"
-	redeemTime       int32  = 172800 // TODO: remove this and put in config (hours x 3600)
 )
 
+// GetMessageRedeemTime returns how long, in seconds, items attached to a message can be redeemed for
+func GetMessageRedeemTime() int32 {
+	hours := db.core.ServerConfig.Mail.RedeemTime
+	if hours <= 0 {
+		hours = defaultMailRedeemTime
+	}
+	return hours * 3600
+}
+
+// GetMessageStorageTime returns how long, in seconds, messages are kept before they are removed
+func GetMessageStorageTime() int32 {
+	hours := db.core.ServerConfig.Mail.StorageTime
+	if hours <= 0 {
+		hours = defaultMailStorageTime
+	}
+	return hours * 3600
+}
+
+// GetMessageType returns the message type of the given name
+func GetMessageType(name string) int8 {
+	return messageType[name]
+}
+
+// CreateMessageWithItems creates a message from sender, attaching items that can be redeemed for maxStorageTime seconds
+func CreateMessageWithItems(sender string, senderType string, templateID string, items []InventoryItem, maxStorageTime int32) *DialogMessage {
+	message := &DialogMessage{
+		ID:                  tools.GenerateMongoID(),
+		UID:                 sender,
+		Type:                messageType[senderType],
+		DT:                  int32(tools.GetCurrentTimeInSeconds()),
+		TemplateID:          templateID,
+		MaxStorageTime:      maxStorageTime,
+		ProfileChangeEvents: make([]any, 0),
+	}
+
+	message.AttachItems(items, maxStorageTime)
+	return message
+}
+
+// AttachItems attaches items to the message, parenting root items to a new stash, that can be redeemed for
+// maxStorageTime seconds
+func (m *DialogMessage) AttachItems(items []InventoryItem, maxStorageTime int32) {
+	if len(items) == 0 {
+		return
+	}
+
+	stash := tools.GenerateMongoID()
+	for idx, item := range items {
+		if item.SlotID == "hideout" || item.ParentID == "" || item.ParentID == "hideout" {
+			items[idx].ParentID = stash
+			items[idx].SlotID = "main"
+			items[idx].Location = nil
+		}
+	}
+
+	m.Items = &MessageItems{Stash: stash, Data: items}
+	m.MaxStorageTime = maxStorageTime
+	m.HasRewards = true
+}
+
+// CreateDialogUser returns the character as a participant of a user or group dialog
+func CreateDialogUser(character *Character[map[string]PlayerTradersInfo]) DialogUser {
+	return DialogUser{
+		ID: character.ID,
+		Info: DialogUserInfo{
+			Nickname:       character.Info.Nickname,
+			Side:           character.Info.Side,
+			Level:          character.Info.Level,
+			MemberCategory: character.Info.MemberCategory,
+		},
+	}
+}
+
+// CreateUserMessage creates a message written by the player of sender
+func CreateUserMessage(sender string, senderType string, text string) *DialogMessage {
+	return &DialogMessage{
+		ID:                  tools.GenerateMongoID(),
+		UID:                 sender,
+		Type:                messageType[senderType],
+		DT:                  int32(tools.GetCurrentTimeInSeconds()),
+		Text:                text,
+		ProfileChangeEvents: make([]any, 0),
+	}
+}
+
 func CreateQuestDialogue(playerID string, sender string, traderID string, dialogueID string) (*Dialog, *DialogMessage) {
 	contents := &DialogueDetails{
 		RecipientID:                    playerID,
@@ -236,7 +387,7 @@ func CreateQuestDialogue(playerID string, sender string, traderID string, dialog
 		DialogType:                     messageType["Trader"],
 		Trader:                         traderID,
 		TemplateID:                     dialogueID,
-		ItemsMaxStorageLifetimeSeconds: redeemTime,
+		ItemsMaxStorageLifetimeSeconds: GetMessageRedeemTime(),
 	}
 
 	dialog := &Dialog{
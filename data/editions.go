@@ -5,6 +5,7 @@ import (
 	"log"
 	"mtgo/tools"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/alphadose/haxmap"
@@ -22,6 +23,17 @@ func GetEditionByName(version string) (*Edition, error) {
 	return edition, nil
 }
 
+// GetEditionNames returns the names of every edition, sorted
+func GetEditionNames() []string {
+	names := make([]string, 0, db.edition.Len())
+	db.edition.ForEach(func(name string, _ *Edition) bool {
+		names = append(names, name)
+		return true
+	})
+	slices.Sort(names)
+	return names
+}
+
 // #endregion
 
 // #region Edition setters
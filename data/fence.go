@@ -0,0 +1,347 @@
+package data
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+
+	"mtgo/tools"
+
+	"github.com/alphadose/haxmap"
+	"github.com/goccy/go-json"
+)
+
+const (
+	fenceID          string = "579dc571d53a0658a154fbec"
+	fenceLoyalLevel  int8   = 1
+	fenceMinLevel    int    = -7
+	fenceMaxLevel    int    = 6
+	fenceItemCount   int    = 120
+	fencePresetCount int    = 20
+	fenceMaxStack    int32  = 60
+)
+
+const (
+	// fenceOpenShare is the share of the offers every standing sees; the rest unlock evenly up to fenceMaxLevel
+	fenceOpenShare float64 = 0.4
+	// fenceModRemoveChance is the percent chance of each removable mod being stripped off a weapon preset
+	fenceModRemoveChance int = 30
+	// fenceMinDurability is the lowest share of its max durability a Fence item can have
+	fenceMinDurability float64 = 0.6
+)
+
+const (
+	handbookMoneyCategory   string = "5b5f78b786f77447ed5636af"
+	handbookQuestCategory   string = "5b619f1a86f77450a702a6f3"
+	handbookWeaponsCategory string = "5b5f78dc86f77409407a7f8e"
+)
+
+// FenceAssort holds the Fence standing level each offer of the generated assort requires
+type FenceAssort struct {
+	Levels map[string]int
+}
+
+// fenceAssort is a generated Fence assort, waiting to be swapped in
+type fenceAssort struct {
+	assort  *Assort
+	index   *AssortIndex
+	levels  map[string]int
+	initial map[string]int32
+	items   map[string]*AssortItem
+}
+
+type fenceOffer struct {
+	items []*AssortItem
+	price int32
+}
+
+// fenceView is Fence's assort as seen by a player of the given standing level
+type fenceView struct {
+	level         int
+	priceModifier float64
+	levels        map[string]int
+}
+
+// SetFenceAssort generates Fence's first assort, once the items, handbook and prices are loaded
+func SetFenceAssort() {
+	fence, ok := db.trader.Traders.Get(fenceID)
+	if !ok || fence.Stock == nil {
+		log.Println("Fence does not exist, not generating assort")
+		return
+	}
+
+	generated := generateFenceAssort()
+	fence.Stock.mu.Lock()
+	defer fence.Stock.mu.Unlock()
+	fence.setFenceAssort(generated)
+}
+
+// setFenceAssort swaps in the generated assort, invalidating every player's cached Fence assort; the caller holds
+// the stock lock
+func (t *Trader) setFenceAssort(generated *fenceAssort) {
+	generated.assort.NextResupply = t.Assort.NextResupply
+	t.Assort = generated.assort
+	t.Index.Assort = generated.index
+	t.Fence = &FenceAssort{Levels: generated.levels}
+	t.Stock.initial = generated.initial
+	t.Stock.items = generated.items
+	t.generation++
+	log.Println("Fence assort generated with", t.Assort.LoyalLevelItems.Len(), "offers")
+}
+
+// generateFenceAssort creates a Fence assort of random items of the handbook and weapon presets with random mods,
+// all partially worn
+func generateFenceAssort() *fenceAssort {
+	offers := make([]fenceOffer, 0, fenceItemCount+fencePresetCount)
+
+	categories := getFenceItemCategories()
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for i := 0; i < fenceItemCount && len(names) != 0; i++ {
+		items := categories[names[rand.Intn(len(names))]]
+		if offer, ok := createFenceItemOffer(items[rand.Intn(len(items))]); ok {
+			offers = append(offers, offer)
+		}
+	}
+
+	presets := getFenceWeaponPresets()
+	for i := 0; i < fencePresetCount && len(presets) != 0; i++ {
+		if offer, ok := createFencePresetOffer(presets[rand.Intn(len(presets))]); ok {
+			offers = append(offers, offer)
+		}
+	}
+	rand.Shuffle(len(offers), func(i, j int) { offers[i], offers[j] = offers[j], offers[i] })
+
+	rouble := *GetCurrencyByName("RUB")
+	assort := &Assort{
+		BarterScheme:    haxmap.New[string, [][]*Scheme](uintptr(len(offers))),
+		Items:           make([]*AssortItem, 0, len(offers)),
+		LoyalLevelItems: haxmap.New[string, int8](uintptr(len(offers))),
+	}
+	levels := make(map[string]int, len(offers))
+
+	open := int(float64(len(offers)) * fenceOpenShare)
+	for idx, offer := range offers {
+		root := offer.items[0]
+		assort.Items = append(assort.Items, offer.items...)
+		assort.BarterScheme.Set(root.ID, [][]*Scheme{{{Tpl: rouble, Count: float32(offer.price)}}})
+		assort.LoyalLevelItems.Set(root.ID, fenceLoyalLevel)
+
+		levels[root.ID] = fenceMinLevel
+		if idx >= open {
+			levels[root.ID] = fenceMinLevel + 1 + (idx-open)*(fenceMaxLevel-fenceMinLevel)/max(len(offers)-open, 1)
+		}
+	}
+
+	initial, items := getStockItems(assort)
+	return &fenceAssort{
+		assort:  assort,
+		index:   getAssortIndex(assort),
+		levels:  levels,
+		initial: initial,
+		items:   items,
+	}
+}
+
+// getFenceItemCategories returns the items Fence can sell, grouped by their top handbook category; blacklisted,
+// quest and unpriced items, money and bare weapons are left out
+func getFenceItemCategories() map[string][]*DatabaseItem {
+	parents := make(map[string]string, len(db.template.handbook.Categories))
+	for _, category := range db.template.handbook.Categories {
+		parents[category.ID] = category.ParentID
+	}
+
+	categories := make(map[string][]*DatabaseItem)
+	for _, entry := range db.template.handbook.Items {
+		if _, blacklisted := IsItemBlacklist(entry.ID); blacklisted || entry.Price <= 0 {
+			continue
+		}
+
+		category := entry.ParentID
+		for parents[category] != "" {
+			category = parents[category]
+		}
+		if category == handbookMoneyCategory || category == handbookQuestCategory || category == handbookWeaponsCategory {
+			continue
+		}
+
+		item, ok := db.item.Get(entry.ID)
+		if !ok || item.Type != "Item" {
+			continue
+		}
+		if questItem, _ := item.Props["QuestItem"].(bool); questItem {
+			continue
+		}
+
+		categories[category] = append(categories[category], item)
+	}
+	return categories
+}
+
+// getFenceWeaponPresets returns the weapon presets of the globals
+func getFenceWeaponPresets() []*globalItemPreset {
+	presets := make([]*globalItemPreset, 0)
+	for _, value := range db.core.Globals.ItemPresets {
+		raw, err := json.MarshalNoEscape(value)
+		if err != nil {
+			continue
+		}
+
+		preset := new(globalItemPreset)
+		if err := json.UnmarshalNoEscape(raw, preset); err != nil || len(preset.Items) == 0 {
+			continue
+		}
+
+		root, ok := db.item.Get(preset.Items[0].Tpl)
+		if !ok || !root.IsWeapon() {
+			continue
+		}
+		presets = append(presets, preset)
+	}
+	return presets
+}
+
+func createFenceItemOffer(item *DatabaseItem) (fenceOffer, bool) {
+	price, err := item.GetItemPrice()
+	if err != nil {
+		return fenceOffer{}, false
+	}
+
+	upd := createFenceItemUPD(item)
+	upd.StackObjectsCount = 1
+	if stack := min(item.GetStackMaxSize(), fenceMaxStack); stack > 1 {
+		upd.StackObjectsCount = int32(tools.GetRandomInt(1, int(stack)))
+	}
+
+	root := &AssortItem{
+		ID:       tools.GenerateMongoID(),
+		Tpl:      item.ID,
+		ParentID: "hideout",
+		SlotID:   "hideout",
+		Upd:      upd,
+	}
+	return fenceOffer{items: []*AssortItem{root}, price: price}, true
+}
+
+// createFencePresetOffer copies the preset with new IDs, stripping each mod whose slot is not required by chance
+func createFencePresetOffer(preset *globalItemPreset) (fenceOffer, bool) {
+	ids := make(map[string]string, len(preset.Items))
+	templates := make(map[string]string, len(preset.Items))
+	for _, item := range preset.Items {
+		ids[item.ID] = tools.GenerateMongoID()
+		templates[item.ID] = item.Tpl
+	}
+
+	removed := make(map[string]struct{})
+	items := make([]*AssortItem, 0, len(preset.Items))
+	var price int32
+	for idx, item := range preset.Items {
+		dbItem, ok := db.item.Get(item.Tpl)
+		if !ok {
+			return fenceOffer{}, false
+		}
+
+		if idx != 0 {
+			if _, ok := removed[item.ParentID]; ok {
+				removed[item.ID] = struct{}{}
+				continue
+			}
+			if !isSlotRequired(templates[item.ParentID], item.SlotID) && tools.GetRandomInt(0, 99) < fenceModRemoveChance {
+				removed[item.ID] = struct{}{}
+				continue
+			}
+		}
+
+		itemPrice, err := dbItem.GetItemPrice()
+		if err != nil {
+			return fenceOffer{}, false
+		}
+		price += itemPrice
+
+		assortItem := &AssortItem{
+			ID:       ids[item.ID],
+			Tpl:      item.Tpl,
+			ParentID: ids[item.ParentID],
+			SlotID:   item.SlotID,
+		}
+		if idx == 0 {
+			assortItem.ParentID = "hideout"
+			assortItem.SlotID = "hideout"
+			assortItem.Upd = createFenceItemUPD(dbItem)
+			assortItem.Upd.StackObjectsCount = 1
+		}
+		items = append(items, assortItem)
+	}
+	return fenceOffer{items: items, price: price}, true
+}
+
+// createFenceItemUPD creates the item's UPD, wearing its durability down to between fenceMinDurability and its max
+func createFenceItemUPD(item *DatabaseItem) *ItemUpdate {
+	upd, err := item.CreateItemUPD()
+	if err != nil || upd == nil {
+		upd = new(ItemUpdate)
+	}
+
+	if upd.Repairable != nil {
+		wear := fenceMinDurability + rand.Float64()*(1-fenceMinDurability)
+		upd.Repairable.Durability = math.Round(upd.Repairable.MaxDurability*wear*100) / 100
+	}
+	return upd
+}
+
+func isSlotRequired(parentTpl string, slotID string) bool {
+	parent, ok := db.item.Get(parentTpl)
+	if !ok {
+		return true
+	}
+	if slots, _ := parent.Props["Slots"].([]any); len(slots) == 0 {
+		return false
+	}
+
+	slot, ok := parent.GetItemSlots()[slotID]
+	return ok && slot.Required
+}
+
+// getFenceView returns Fence's assort as seen by the character, or nil if the trader is not Fence
+func (t *Trader) getFenceView(character *Character[map[string]PlayerTradersInfo]) *fenceView {
+	if t.Fence == nil {
+		return nil
+	}
+
+	standing := character.TradersInfo[fenceID].Standing
+	level := min(max(int(math.Floor(float64(standing))), fenceMinLevel), fenceMaxLevel)
+
+	view := &fenceView{level: level, priceModifier: 1, levels: t.Fence.Levels}
+	if settings, ok := db.core.Globals.Config.FenceSettings.Levels[strconv.Itoa(level)]; ok && settings.PriceModifier > 0 {
+		view.priceModifier = settings.PriceModifier
+	}
+	return view
+}
+
+func (v *fenceView) isOfferVisible(id string) bool {
+	return v.levels[id] <= v.level
+}
+
+// getBarterScheme returns the barter scheme priced for the player's standing level
+func (v *fenceView) getBarterScheme(scheme [][]*Scheme) [][]*Scheme {
+	if v == nil {
+		return scheme
+	}
+
+	output := make([][]*Scheme, 0, len(scheme))
+	for _, option := range scheme {
+		priced := make([]*Scheme, 0, len(option))
+		for _, requirement := range option {
+			scaled := *requirement
+			scaled.Count = float32(math.Round(float64(requirement.Count) * v.priceModifier))
+			priced = append(priced, &scaled)
+		}
+		output = append(output, priced)
+	}
+	return output
+}
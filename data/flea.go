@@ -4,19 +4,29 @@ import (
 	"fmt"
 	"log"
 	"mtgo/tools"
+	"slices"
+	"sync"
 )
 
+const offerNotExist string = "Offer %s does not exist"
+
 type Ragfair struct {
 	Catalog map[string][]Offer
 	Market  Flea
+	mu      sync.RWMutex
 }
 
+var nextOfferIntID int16
+
 // #region Flea getters
 func GetFlea() *Ragfair {
 	return db.ragfair
 }
 
 func GetFleaCatalog(id string) ([]Offer, error) {
+	db.ragfair.mu.RLock()
+	defer db.ragfair.mu.RUnlock()
+
 	catalog, ok := db.ragfair.Catalog[id]
 	if !ok {
 		return catalog, fmt.Errorf("catalog of %s does not exist", id)
@@ -24,8 +34,128 @@ func GetFleaCatalog(id string) ([]Offer, error) {
 	return catalog, nil
 }
 
+// GetFleaCatalogs returns a copy of every catalog on the flea, keyed by item TPL
+func GetFleaCatalogs() map[string][]Offer {
+	db.ragfair.mu.RLock()
+	defer db.ragfair.mu.RUnlock()
+
+	output := make(map[string][]Offer, len(db.ragfair.Catalog))
+	for tpl, catalog := range db.ragfair.Catalog {
+		output[tpl] = slices.Clone(catalog)
+	}
+	return output
+}
+
+// GetOfferByID returns a copy of the offer with the given ID
+func GetOfferByID(id string) (*Offer, error) {
+	db.ragfair.mu.RLock()
+	defer db.ragfair.mu.RUnlock()
+
+	for _, catalog := range db.ragfair.Catalog {
+		for _, offer := range catalog {
+			if offer.ID == id {
+				return &offer, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf(offerNotExist, id)
+}
+
+// GetOfferByIntID returns a copy of the offer with the given IntID
+func GetOfferByIntID(id int16) (*Offer, error) {
+	db.ragfair.mu.RLock()
+	defer db.ragfair.mu.RUnlock()
+
+	for _, catalog := range db.ragfair.Catalog {
+		for _, offer := range catalog {
+			if offer.IntID == id {
+				return &offer, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf(offerNotExist, fmt.Sprint(id))
+}
+
+// GetMaxActiveOffers returns how many offers a player with the given rating can have listed at once
+func GetMaxActiveOffers(rating float32) int {
+	for _, entry := range db.core.Globals.Config.RagFair.MaxActiveOfferCount {
+		if float64(rating) >= entry.From && float64(rating) < entry.To {
+			return entry.Count
+		}
+	}
+	return 0
+}
+
 // #region Flea setters
 
+// GetNextOfferIntID returns the next free IntID for an offer
+func GetNextOfferIntID() int16 {
+	db.ragfair.mu.Lock()
+	defer db.ragfair.mu.Unlock()
+
+	nextOfferIntID++
+	return nextOfferIntID
+}
+
+// AddOfferToCatalog lists the offer on the flea
+func AddOfferToCatalog(offer Offer) {
+	db.ragfair.mu.Lock()
+	defer db.ragfair.mu.Unlock()
+
+	tpl := offer.GetRootItem().Tpl
+	db.ragfair.Catalog[tpl] = append(db.ragfair.Catalog[tpl], offer)
+	db.ragfair.Market.Categories[tpl]++
+}
+
+// UpdateOfferInCatalog replaces the listed offer sharing the ID of offer; the listing is copied, as slices
+// returned by GetFleaCatalog may still share it
+func UpdateOfferInCatalog(offer Offer) {
+	db.ragfair.mu.Lock()
+	defer db.ragfair.mu.Unlock()
+
+	tpl := offer.GetRootItem().Tpl
+	for idx, listed := range db.ragfair.Catalog[tpl] {
+		if listed.ID == offer.ID {
+			catalog := slices.Clone(db.ragfair.Catalog[tpl])
+			catalog[idx] = offer
+			db.ragfair.Catalog[tpl] = catalog
+			return
+		}
+	}
+}
+
+// RemoveOfferFromCatalog removes the offer with the given ID from the flea; the listing is copied, as slices
+// returned by GetFleaCatalog may still share it
+func RemoveOfferFromCatalog(id string) {
+	db.ragfair.mu.Lock()
+	defer db.ragfair.mu.Unlock()
+
+	for tpl, catalog := range db.ragfair.Catalog {
+		for idx, offer := range catalog {
+			if offer.ID != id {
+				continue
+			}
+
+			db.ragfair.Catalog[tpl] = slices.Delete(slices.Clone(catalog), idx, idx+1)
+			db.ragfair.Market.Categories[tpl]--
+			if db.ragfair.Market.Categories[tpl] <= 0 {
+				delete(db.ragfair.Market.Categories, tpl)
+			}
+			return
+		}
+	}
+}
+
+// GetRootItem returns the item the offer is selling
+func (o *Offer) GetRootItem() *AssortItem {
+	for idx, item := range o.Items {
+		if item.ID == o.Root {
+			return &o.Items[idx]
+		}
+	}
+	return &o.Items[0]
+}
+
 func SetFlea() {
 	db.ragfair = &Ragfair{
 		Catalog: make(map[string][]Offer),
@@ -75,6 +205,7 @@ func SetFlea() {
 				log.Fatal("loyalitem doesn't exist")
 			}
 
+			fleaOffersCount++
 			offer := &Offer{
 				ID:    tools.GenerateMongoID(),
 				IntID: fleaOffersCount,
@@ -108,9 +239,25 @@ func SetFlea() {
 			db.ragfair.Market.Categories[main.Tpl]++
 			return true
 		})
-		fleaOffersCount++
 		return true
 	})
+
+	db.profile.ForEach(func(_ string, profile *Profile) bool {
+		if profile.Character == nil {
+			return true
+		}
+		for idx, offer := range profile.Character.RagfairInfo.Offers {
+			fleaOffersCount++
+			offer.IntID = fleaOffersCount
+			profile.Character.RagfairInfo.Offers[idx] = offer
+
+			tpl := offer.GetRootItem().Tpl
+			db.ragfair.Catalog[tpl] = append(db.ragfair.Catalog[tpl], offer)
+			db.ragfair.Market.Categories[tpl]++
+		}
+		return true
+	})
+	nextOfferIntID = fleaOffersCount
 }
 
 // #endregion
@@ -145,8 +292,8 @@ type Offer struct { //nolint:maligned
 }
 
 type RagfairFind struct {
-	Page              int8           `json:"page"`
-	Limit             int8           `json:"limit"`
+	Page              int16          `json:"page"`
+	Limit             int16          `json:"limit"`
 	SortType          int8           `json:"sortType"`
 	SortDirection     int8           `json:"sortDirection"`
 	Currency          int8           `json:"currency"`
@@ -166,13 +313,17 @@ type RagfairFind struct {
 	NeededSearchID    string         `json:"neededSearchId"`
 	BuildItems        map[string]any `json:"buildItems"`
 	BuildCount        int16          `json:"buildCount"`
-	Tm                int8           `json:"tm"`
-	Reload            int8           `json:"reload"`
+	Tm                int32          `json:"tm"`
+	Reload            int32          `json:"reload"`
 }
 
 type OfferUser struct {
-	ID         string         `json:"id"`
-	MemberType MemberCategory `json:"memberType"`
+	ID              string         `json:"id"`
+	MemberType      MemberCategory `json:"memberType"`
+	Nickname        string         `json:"nickname,omitempty"`
+	Rating          float32        `json:"rating,omitempty"`
+	IsRatingGrowing bool           `json:"isRatingGrowing,omitempty"`
+	Avatar          string         `json:"avatar,omitempty"`
 }
 
 // #endregion
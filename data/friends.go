@@ -5,6 +5,7 @@ import (
 	"log"
 	"mtgo/tools"
 	"path/filepath"
+	"slices"
 
 	"github.com/goccy/go-json"
 )
@@ -26,21 +27,21 @@ func setFriends(path string) *Friends {
 
 func (friends *Friends) CreateFriends() *Friends {
 	return &Friends{
-		Friends:      make([]FriendRequest, 0),
+		Friends:      make([]FriendRequestProfile, 0),
 		Ignore:       make([]string, 0),
 		InIgnoreList: make([]string, 0),
 		Matching: Matching{
 			LookingForGroup: false,
 		},
-		FriendRequestInbox:  make([]any, 0),
-		FriendRequestOutbox: make([]any, 0),
+		FriendRequestInbox:  make([]FriendRequest, 0),
+		FriendRequestOutbox: make([]FriendRequest, 0),
 	}
 }
 
 func GetFriendsByID(uid string) (*Friends, error) {
 	profile, err := GetProfileByUID(uid)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
 
 	if profile.Friends != nil {
@@ -58,3 +59,60 @@ func (friends *Friends) SaveFriends(sessionID string) error {
 	log.Println("Friends saved")
 	return nil
 }
+
+// CreateFriendProfile returns the profile of the character shown to other players in their friend list
+func CreateFriendProfile(character *Character[map[string]PlayerTradersInfo]) FriendRequestProfile {
+	profile := FriendRequestProfile{
+		ID:  character.ID,
+		AID: character.AID,
+	}
+	profile.Info.Nickname = character.Info.Nickname
+	profile.Info.Side = character.Info.Side
+	profile.Info.Level = character.Info.Level
+	profile.Info.MemberCategory = MemberCategory(character.Info.MemberCategory)
+	return profile
+}
+
+// IsFriend returns whether the player of id is in the friend list
+func (friends *Friends) IsFriend(id string) bool {
+	return slices.ContainsFunc(friends.Friends, func(friend FriendRequestProfile) bool {
+		return friend.ID == id
+	})
+}
+
+// RemoveFriend removes the player of id from the friend list, returning whether they were in it
+func (friends *Friends) RemoveFriend(id string) bool {
+	length := len(friends.Friends)
+	friends.Friends = slices.DeleteFunc(friends.Friends, func(friend FriendRequestProfile) bool {
+		return friend.ID == id
+	})
+	return len(friends.Friends) != length
+}
+
+// GetInboxRequest returns the pending request sent by the player of id
+func (friends *Friends) GetInboxRequest(from string) (*FriendRequest, bool) {
+	idx := slices.IndexFunc(friends.FriendRequestInbox, func(request FriendRequest) bool {
+		return request.From == from
+	})
+	if idx == -1 {
+		return nil, false
+	}
+	return &friends.FriendRequestInbox[idx], true
+}
+
+// HasOutboxRequest returns whether a request to the player of id is pending
+func (friends *Friends) HasOutboxRequest(to string) bool {
+	return slices.ContainsFunc(friends.FriendRequestOutbox, func(request FriendRequest) bool {
+		return request.To == to
+	})
+}
+
+// RemoveRequests removes every pending request between the player and the player of id
+func (friends *Friends) RemoveRequests(id string) {
+	friends.FriendRequestInbox = slices.DeleteFunc(friends.FriendRequestInbox, func(request FriendRequest) bool {
+		return request.From == id
+	})
+	friends.FriendRequestOutbox = slices.DeleteFunc(friends.FriendRequestOutbox, func(request FriendRequest) bool {
+		return request.To == id
+	})
+}
@@ -28,6 +28,20 @@ func IndexWeaponMasteries() {
 	}
 }
 
+// GetLevelByExperience returns the player level reached with the given amount of experience
+func GetLevelByExperience(experience int32) int8 {
+	var total int32
+	var level int8
+	for _, entry := range db.core.Globals.Config.Exp.Level.ExpTable {
+		total += int32(entry.Exp)
+		if experience < total {
+			break
+		}
+		level++
+	}
+	return level
+}
+
 func SetNewWeaponMastery(name string) {
 
 }
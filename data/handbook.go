@@ -61,7 +61,10 @@ func GetPrices() *haxmap.Map[string, int32] {
 	return db.template.index.Item.Prices
 }
 
-const priceNotFound string = "price of %s not found"
+const (
+	priceNotFound  string = "price of %s not found"
+	courseNotFound string = "currency course of %s not found"
+)
 
 // GetPriceByID Get item price by ID
 func GetPriceByID(id string) (int32, error) {
@@ -252,19 +255,19 @@ func IsItemBlacklist(id string) (string, bool) {
 }
 
 func ConvertFromRouble(amount int32, currency string) (float64, error) {
-	price, err := GetPriceByID(currency)
-	if err != nil {
-		return -1, err
+	course, ok := GetSupplyData().CurrencyCourses.GetCourse(currency)
+	if !ok || course <= 0 {
+		return -1, fmt.Errorf(courseNotFound, currency)
 	}
-	return math.Round(float64(amount / price)), nil
+	return math.Round(float64(amount) / float64(course)), nil
 }
 
-func ConvertToRouble(amount int32, currency string) float64 {
-	price, err := GetPriceByID(currency)
-	if err != nil {
-		log.Println(err)
+func ConvertToRouble(amount int32, currency string) (float64, error) {
+	course, ok := GetSupplyData().CurrencyCourses.GetCourse(currency)
+	if !ok || course <= 0 {
+		return -1, fmt.Errorf(courseNotFound, currency)
 	}
-	return math.Round(float64(amount * (price)))
+	return math.Round(float64(amount) * float64(course)), nil
 }
 
 func (hbi *TemplateItem) SetHandbookItemEntry() {
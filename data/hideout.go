@@ -3,6 +3,8 @@ package data
 import (
 	"fmt"
 	"log"
+	"slices"
+	"sync"
 
 	"mtgo/tools"
 
@@ -24,6 +26,7 @@ const (
 	recipeNotExist         = "Hideout Recipe %s does not exist"
 	scavCaseNotExist       = "Hideout ScavCase does not exist"
 	scavCaseRecipeNotExist = "ScavCase recipe %s does not exist"
+	areaStageNotExist      = "Hideout Area Type %d does not have stage %d"
 )
 
 // #region Hideout getters
@@ -80,6 +83,35 @@ func GetHideoutAreaByAreaType(_type int8) *map[string]any {
 	return &hideoutArea
 }
 
+// GetHideoutAreaStage retrieves the stage of a hideout area for the given level.
+func GetHideoutAreaStage(_type int8, level int) (*HideoutAreaStage, error) {
+	area := GetHideoutAreaByAreaType(_type)
+	if area == nil {
+		return nil, fmt.Errorf(areaNotExist, fmt.Sprint(_type))
+	}
+
+	stages, ok := (*area)["stages"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf(areaStageNotExist, _type, level)
+	}
+
+	stage, ok := stages[fmt.Sprint(level)]
+	if !ok {
+		return nil, fmt.Errorf(areaStageNotExist, _type, level)
+	}
+
+	input, err := json.MarshalNoEscape(stage)
+	if err != nil {
+		return nil, err
+	}
+
+	output := new(HideoutAreaStage)
+	if err := json.UnmarshalNoEscape(input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
 // GetHideoutAreaByName retrieves a hideout area by its name.
 func GetHideoutAreaByName(name string) *map[string]any {
 	area, ok := db.hideout.Index.Name[name]
@@ -109,6 +141,94 @@ func GetHideoutRecipeByID(rid string) *map[string]any {
 	return nil
 }
 
+// GetHideoutRecipe retrieves a hideout production by its ID as a HideoutRecipe.
+func GetHideoutRecipe(rid string) (*HideoutRecipe, error) {
+	recipe := GetHideoutRecipeByID(rid)
+	if recipe == nil {
+		return nil, fmt.Errorf(recipeNotExist, rid)
+	}
+
+	input, err := json.MarshalNoEscape(recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	output := new(HideoutRecipe)
+	if err := json.UnmarshalNoEscape(input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetScavCaseRecipe retrieves a scavcase production by its ID as a ScavCaseRecipe.
+func GetScavCaseRecipe(rid string) (*ScavCaseRecipe, error) {
+	recipe := GetScavCaseRecipeByID(rid)
+	if recipe == nil {
+		return nil, fmt.Errorf(scavCaseRecipeNotExist, rid)
+	}
+
+	input, err := json.MarshalNoEscape(recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	output := new(ScavCaseRecipe)
+	if err := json.UnmarshalNoEscape(input, output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// GetHideoutRecipeIDByProduct retrieves the ID of the hideout production of the area that makes the item.
+func GetHideoutRecipeIDByProduct(areaType int, tpl string) (string, error) {
+	for _, recipe := range db.hideout.Recipes {
+		area, _ := recipe["areaType"].(float64)
+		product, _ := recipe["endProduct"].(string)
+		if int(area) == areaType && product == tpl {
+			return recipe["_id"].(string), nil
+		}
+	}
+	return "", fmt.Errorf(recipeNotExist, tpl)
+}
+
+// IsScavCaseRecipe checks if the ID belongs to a scavcase production.
+func IsScavCaseRecipe(rid string) bool {
+	_, ok := db.hideout.Index.ScavCase[rid]
+	return ok
+}
+
+// scavCaseItems holds the IDs of the items scav case runs can produce, by rarity
+var scavCaseItems struct {
+	once  sync.Once
+	pools map[string][]string
+}
+
+// GetScavCaseItemPools returns the sorted IDs of the items scav case runs can produce, by rarity; the pools are
+// built on first use, once custom items are loaded
+func GetScavCaseItemPools() map[string][]string {
+	scavCaseItems.once.Do(func() {
+		pools := make(map[string][]string)
+		db.item.ForEach(func(id string, item *DatabaseItem) bool {
+			if item.Type != "Item" {
+				return true
+			}
+			if questItem, _ := item.Props["QuestItem"].(bool); questItem {
+				return true
+			}
+			if rarity, ok := item.Props["Rarity"].(string); ok {
+				pools[rarity] = append(pools[rarity], id)
+			}
+			return true
+		})
+
+		for _, pool := range pools {
+			slices.Sort(pool)
+		}
+		scavCaseItems.pools = pools
+	})
+	return scavCaseItems.pools
+}
+
 // GetScavCaseRecipeByID retrieves a scavcase production by its ID.
 func GetScavCaseRecipeByID(rid string) *map[string]any {
 	index, ok := db.hideout.Index.ScavCase[rid]
@@ -261,6 +381,64 @@ type HideoutIndex struct {
 	Recipes  map[string]int16
 }
 
+type HideoutAreaStage struct {
+	AutoUpgrade      bool                 `json:"autoUpgrade"`
+	ConstructionTime float64              `json:"constructionTime"`
+	Requirements     []HideoutRequirement `json:"requirements"`
+	Bonuses          []HideoutBonus       `json:"bonuses"`
+	Slots            int                  `json:"slots"`
+}
+
+type HideoutRequirement struct {
+	Type          string `json:"type"`
+	AreaType      int8   `json:"areaType,omitempty"`
+	RequiredLevel int    `json:"requiredLevel,omitempty"`
+	TemplateID    string `json:"templateId,omitempty"`
+	Count         int32  `json:"count,omitempty"`
+	IsFunctional  bool   `json:"isFunctional,omitempty"`
+	TraderID      string `json:"traderId,omitempty"`
+	LoyaltyLevel  int8   `json:"loyaltyLevel,omitempty"`
+	SkillName     string `json:"skillName,omitempty"`
+	SkillLevel    int    `json:"skillLevel,omitempty"`
+	QuestID       string `json:"questId,omitempty"`
+	Resource      int32  `json:"resource,omitempty"`
+}
+
+type HideoutRecipe struct {
+	ID                           string               `json:"_id"`
+	AreaType                     int8                 `json:"areaType"`
+	Continuous                   bool                 `json:"continuous"`
+	Count                        int32                `json:"count"`
+	EndProduct                   string               `json:"endProduct"`
+	Locked                       bool                 `json:"locked"`
+	NeedFuelForAllProductionTime bool                 `json:"needFuelForAllProductionTime"`
+	ProductionLimitCount         int                  `json:"productionLimitCount"`
+	ProductionTime               float64              `json:"productionTime"`
+	Requirements                 []HideoutRequirement `json:"requirements"`
+}
+
+type ScavCaseRecipe struct {
+	ID             string                          `json:"_id"`
+	ProductionTime float64                         `json:"ProductionTime"`
+	Requirements   []HideoutRequirement            `json:"Requirements"`
+	EndProducts    map[string]ScavCaseProductRange `json:"EndProducts"`
+}
+
+type ScavCaseProductRange struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+type HideoutBonus struct {
+	ID         string  `json:"id"`
+	Type       string  `json:"type"`
+	TemplateID string  `json:"templateId,omitempty"`
+	Value      float64 `json:"value"`
+	Passive    bool    `json:"passive"`
+	Production bool    `json:"production"`
+	Visible    bool    `json:"visible"`
+}
+
 type HideoutSettings struct {
 	GeneratorSpeedWithoutFuel float64 `json:"generatorSpeedWithoutFuel"`
 	GeneratorFuelFlowRate     float64 `json:"generatorFuelFlowRate"`
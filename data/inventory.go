@@ -137,7 +137,7 @@ func AssignNewIDs(inventoryItems []InventoryItem) []InventoryItem {
 	for _, inventoryItem := range inventoryItems {
 		newID := tools.GenerateMongoID()
 		convertedIDs[inventoryItem.ID] = newID
-		inventoryItem.Clone()
+		inventoryItem = *inventoryItem.Clone()
 		inventoryItem.ID = newID
 
 		input = append(input, inventoryItem)
@@ -284,14 +284,17 @@ type ItemUpdate struct {
 	MedKit                *MedicalKit      `json:"MedKit,omitempty"`
 	FoodDrink             *FoodDrink       `json:"FoodDrink,omitempty"`
 	RepairKit             *RepairKit       `json:"RepairKit,omitempty"`
+	Buff                  *RepairBuff      `json:"Buff,omitempty"`
 	Light                 *Light           `json:"Light,omitempty"`
 	Resource              *Resource        `json:"Resource,omitempty"`
 	Tag                   *Tag             `json:"Tag,omitempty"`
+	Dogtag                *Dogtag          `json:"Dogtag,omitempty"`
 	Togglable             *Toggle          `json:"Togglable,omitempty"`
 	RecodableComponent    *RecodeComponent `json:"RecodableComponent,omitempty"`
 	BuyRestrictionCurrent int16            `json:"BuyRestrictionCurrent,omitempty"`
 	BuyRestrictionMax     int16            `json:"BuyRestrictionMax,omitempty"`
 	UnlimitedCount        bool             `json:"UnlimitedCount,omitempty"`
+	SpawnedInSession      bool             `json:"SpawnedInSession,omitempty"`
 }
 
 type RecodeComponent struct {
@@ -307,6 +310,20 @@ type Tag struct {
 	Color string
 }
 
+type Dogtag struct {
+	AccountID       string `json:"AccountId"`
+	ProfileID       string `json:"ProfileId"`
+	Nickname        string `json:"Nickname"`
+	Side            string `json:"Side"`
+	Level           int32  `json:"Level"`
+	Time            string `json:"Time"`
+	Status          string `json:"Status"`
+	KillerAccountID string `json:"KillerAccountId"`
+	KillerProfileID string `json:"KillerProfileId"`
+	KillerName      string `json:"KillerName"`
+	WeaponName      string `json:"WeaponName"`
+}
+
 type Resource struct {
 	Value int16 `json:"Value"`
 }
@@ -333,8 +350,16 @@ type Sight struct {
 }
 
 type Repairable struct {
-	Durability    int `json:"Durability"`
-	MaxDurability int `json:"MaxDurability"`
+	Durability    float64 `json:"Durability"`
+	MaxDurability float64 `json:"MaxDurability"`
+}
+
+// RepairBuff is the enhancement a repair kit can give an item, lost once its durability drops below ThresholdDurability
+type RepairBuff struct {
+	Rarity              string  `json:"rarity"`
+	BuffType            string  `json:"buffType"`
+	Value               float64 `json:"value"`
+	ThresholdDurability float64 `json:"thresholdDurability"`
 }
 type Foldable struct {
 	Folded bool `json:"Folded"`
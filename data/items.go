@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"mtgo/tools"
+	"slices"
 
 	"github.com/alphadose/haxmap"
 	"github.com/goccy/go-json"
@@ -169,6 +170,149 @@ func (i *DatabaseItem) GetItemSlots() map[string]*Slot {
 	return output
 }
 
+// GetItemChambers Get the chamber property from the item if it exists
+func (i *DatabaseItem) GetItemChambers() map[string]*Slot {
+	chambers, ok := i.Props["Chambers"].([]any)
+	if !ok || len(chambers) == 0 {
+		return nil
+	}
+
+	output := make(map[string]*Slot)
+	for _, c := range chambers {
+		chamber := new(Slot)
+		data, err := json.Marshal(c)
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+		if err = json.Unmarshal(data, chamber); err != nil {
+			log.Println(err)
+			return nil
+		}
+		output[chamber.Name] = chamber
+	}
+
+	return output
+}
+
+// GetItemCartridges Get the cartridges property from the item if it exists
+func (i *DatabaseItem) GetItemCartridges() *Cartridges {
+	cartridges, ok := i.Props["Cartridges"].([]any)
+	if !ok || len(cartridges) == 0 {
+		return nil
+	}
+
+	output := new(Cartridges)
+	data, err := json.Marshal(cartridges[0])
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if err = json.Unmarshal(data, output); err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return output
+}
+
+// GetFilter returns the templates and nodes the slot accepts
+func (s *Slot) GetFilter() []string {
+	var filter []string
+	for _, f := range s.Props.Filters {
+		filter = append(filter, f.Filter...)
+	}
+	return filter
+}
+
+// GetFilter returns the templates and nodes the cartridges accept
+func (c *Cartridges) GetFilter() []string {
+	var filter []string
+	for _, f := range c.Props.Filters {
+		filter = append(filter, f.Filter...)
+	}
+	return filter
+}
+
+// GetCompatibleItems returns every TPL or node accepted by the item's slots, chambers and cartridges
+func (i *DatabaseItem) GetCompatibleItems() []string {
+	output := make([]string, 0)
+
+	if slots, ok := i.Props["Slots"].([]any); ok && len(slots) != 0 {
+		for _, slot := range i.GetItemSlots() {
+			for _, filter := range slot.Props.Filters {
+				output = append(output, filter.Filter...)
+			}
+		}
+	}
+
+	for _, chamber := range i.GetItemChambers() {
+		for _, filter := range chamber.Props.Filters {
+			output = append(output, filter.Filter...)
+		}
+	}
+
+	if cartridges := i.GetItemCartridges(); cartridges != nil {
+		for _, filter := range cartridges.Props.Filters {
+			output = append(output, filter.Filter...)
+		}
+	}
+
+	return output
+}
+
+// IsChildOf checks if the item, or any of its parent nodes, is one of the given IDs
+func (i *DatabaseItem) IsChildOf(ids []string) bool {
+	current := i
+	for current != nil {
+		if slices.Contains(ids, current.ID) {
+			return true
+		}
+
+		parent, ok := db.item.Get(current.Parent)
+		if !ok {
+			return false
+		}
+		current = parent
+	}
+	return false
+}
+
+// IsObtainableItem returns whether players can come by the item: it has a handbook price and is neither
+// blacklisted nor a quest item
+func IsObtainableItem(id string) bool {
+	if _, blacklisted := IsItemBlacklist(id); blacklisted {
+		return false
+	}
+
+	item, ok := db.item.Get(id)
+	if !ok || item.Type != "Item" {
+		return false
+	}
+	if questItem, _ := item.Props["QuestItem"].(bool); questItem {
+		return false
+	}
+	price, err := GetPriceByID(id)
+	return err == nil && price > 0
+}
+
+// GetItemsByFilter returns the sorted IDs of every item matching the filter and not matching the excluded filter
+func GetItemsByFilter(filter []string, excluded []string) []string {
+	output := make([]string, 0)
+	db.item.ForEach(func(id string, item *DatabaseItem) bool {
+		if item.Type != "Item" {
+			return true
+		}
+		if item.IsChildOf(filter) && !item.IsChildOf(excluded) {
+			output = append(output, id)
+		}
+		return true
+	})
+
+	slices.Sort(output)
+	return output
+}
+
 func (i *DatabaseItem) GetStackMaxSize() int32 {
 	size, _ := i.Props["StackMaxSize"].(float64)
 	return int32(size)
@@ -259,8 +403,8 @@ func (i *DatabaseItem) CreateItemUPD() (*ItemUpdate, error) {
 			}
 
 			itemUpd.Repairable = new(Repairable)
-			itemUpd.Repairable.MaxDurability = int(maxDurability)
-			itemUpd.Repairable.Durability = int(durability)
+			itemUpd.Repairable.MaxDurability = maxDurability
+			itemUpd.Repairable.Durability = durability
 			return itemUpd, nil
 		}
 	case "55818ae44bdc2dde698b456c", "55818ac54bdc2d5b648b456e",
@@ -295,8 +439,8 @@ func (i *DatabaseItem) CreateItemUPD() (*ItemUpdate, error) {
 			itemUpd.Foldable = new(Foldable)
 			itemUpd.FireMode = new(FireMode)
 
-			itemUpd.Repairable.MaxDurability = int(maxDurability)
-			itemUpd.Repairable.Durability = int(durability)
+			itemUpd.Repairable.MaxDurability = maxDurability
+			itemUpd.Repairable.Durability = durability
 			itemUpd.Foldable.Folded = false
 			itemUpd.FireMode.FireMode = "single"
 
@@ -318,8 +462,8 @@ func (i *DatabaseItem) CreateItemUPD() (*ItemUpdate, error) {
 			itemUpd.Repairable = new(Repairable)
 			itemUpd.FireMode = new(FireMode)
 
-			itemUpd.Repairable.MaxDurability = int(maxDurability)
-			itemUpd.Repairable.Durability = int(durability)
+			itemUpd.Repairable.MaxDurability = maxDurability
+			itemUpd.Repairable.Durability = durability
 			itemUpd.FireMode.FireMode = "single"
 
 			return itemUpd, nil
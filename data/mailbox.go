@@ -0,0 +1,149 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/goccy/go-json"
+
+	"mtgo/tools"
+)
+
+const mailboxNotSaved string = "Mailbox for %s was not saved: %s"
+
+// Mailbox holds the notifications waiting for the player to connect
+type Mailbox []*Notification
+
+// mailboxMu guards every player's mailbox, which the notifier, websockets, jobs and other players' requests all
+// change; mailboxes are saved apart from the rest of the profile, so they never touch what the profile lock guards
+var mailboxMu sync.Mutex
+
+func setMailbox(path string) *Mailbox {
+	output := make(Mailbox, 0)
+
+	data := tools.GetJSONRawMessage(path)
+	if err := json.UnmarshalNoEscape(data, &output); err != nil {
+		msg := tools.CheckParsingError(data, err)
+		log.Println(msg)
+		return &Mailbox{}
+	}
+
+	return &output
+}
+
+// setLegacyMailbox moves the mailbox kept in storage.json, before mailboxes had their own file, to the profile
+func (profile *Profile) setLegacyMailbox() {
+	if profile.Mailbox != nil {
+		return
+	}
+
+	mailbox := make(Mailbox, 0)
+	if profile.Storage != nil {
+		mailbox = append(mailbox, profile.Storage.Mailbox...)
+		profile.Storage.Mailbox = nil
+	}
+	profile.Mailbox = &mailbox
+}
+
+// saveMailbox saves the player's mailbox; the caller holds mailboxMu
+func (m *Mailbox) saveMailbox(sessionID string) error {
+	mailboxFilePath := filepath.Join(profilesPath, sessionID, "mailbox.json")
+
+	if err := tools.WriteToFile(mailboxFilePath, m); err != nil {
+		return fmt.Errorf(mailboxNotSaved, sessionID, err)
+	}
+	log.Println("Mailbox saved")
+	return nil
+}
+
+// SaveMailbox saves the player's mailbox
+func (m *Mailbox) SaveMailbox(sessionID string) error {
+	mailboxMu.Lock()
+	defer mailboxMu.Unlock()
+	return m.saveMailbox(sessionID)
+}
+
+// ClearMailbox drops every notification waiting in the player's mailbox
+func (m *Mailbox) ClearMailbox(sessionID string) error {
+	mailboxMu.Lock()
+	defer mailboxMu.Unlock()
+	*m = make(Mailbox, 0)
+	return m.saveMailbox(sessionID)
+}
+
+func getMailbox(sessionID string) (*Mailbox, error) {
+	profile, err := GetProfileByUID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Mailbox == nil {
+		return nil, fmt.Errorf(mailboxNotExist, sessionID)
+	}
+	return profile.Mailbox, nil
+}
+
+const mailboxNotExist string = "Mailbox for %s does not exist"
+
+// AddToMailbox stores the notifications at the end of the player's mailbox
+func AddToMailbox(sessionID string, notifications ...*Notification) error {
+	mailbox, err := getMailbox(sessionID)
+	if err != nil {
+		return err
+	}
+
+	mailboxMu.Lock()
+	defer mailboxMu.Unlock()
+	*mailbox = append(*mailbox, notifications...)
+	return mailbox.saveMailbox(sessionID)
+}
+
+// ReturnToMailbox stores notifications that could not be sent at the front of the player's mailbox, ahead of the
+// ones that arrived since
+func ReturnToMailbox(sessionID string, notifications []*Notification) error {
+	mailbox, err := getMailbox(sessionID)
+	if err != nil {
+		return err
+	}
+
+	mailboxMu.Lock()
+	defer mailboxMu.Unlock()
+	*mailbox = slices.Concat(notifications, *mailbox)
+	return mailbox.saveMailbox(sessionID)
+}
+
+// ReplaceInMailbox stores the notification in the player's mailbox, dropping the ones of its type it supersedes
+func ReplaceInMailbox(sessionID string, notification *Notification) error {
+	mailbox, err := getMailbox(sessionID)
+	if err != nil {
+		return err
+	}
+
+	mailboxMu.Lock()
+	defer mailboxMu.Unlock()
+	*mailbox = slices.DeleteFunc(*mailbox, func(queued *Notification) bool {
+		return queued.Type == notification.Type
+	})
+	*mailbox = append(*mailbox, notification)
+	return mailbox.saveMailbox(sessionID)
+}
+
+// TakeMailbox empties the player's mailbox, returning the notifications it held
+func TakeMailbox(sessionID string) ([]*Notification, error) {
+	mailbox, err := getMailbox(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	mailboxMu.Lock()
+	defer mailboxMu.Unlock()
+	notifications := *mailbox
+	if len(notifications) == 0 {
+		return notifications, nil
+	}
+
+	*mailbox = make(Mailbox, 0)
+	return notifications, mailbox.saveMailbox(sessionID)
+}
@@ -1,16 +1,36 @@
 package data
 
+import "mtgo/tools"
+
 type Notification struct {
-	Type     string        `json:"type"`
-	EventID  string        `json:"eventId"`
-	DialogID string        `json:"dialogId,omitempty"`
-	Message  DialogMessage `json:"message,omitempty"`
+	Type     string         `json:"type"`
+	EventID  string         `json:"eventId"`
+	DialogID string         `json:"dialogId,omitempty"`
+	Message  *DialogMessage `json:"message,omitempty"`
+
+	SupplyNextTime int `json:"supplyNextTime,omitempty"`
+
+	OfferID    string `json:"offerId,omitempty"`
+	Count      int32  `json:"count,omitempty"`
+	HandbookID string `json:"handbookId,omitempty"`
+
+	RequestID string                `json:"_id,omitempty"`
+	Profile   *FriendRequestProfile `json:"profile,omitempty"`
 }
 
 const (
-	SoldOffer string = "RagfairOfferSold"
-	New       string = "new_message"
-	Ping      string = "ping"
+	SoldOffer    string = "RagfairOfferSold"
+	New          string = "new_message"
+	Ping         string = "ping"
+	TraderSupply string = "TraderSupply"
+
+	FriendRequestNew      string = "friendListNewRequest"
+	FriendRequestAccepted string = "friendListRequestAccept"
+	FriendRequestDeclined string = "friendListRequestDecline"
+	FriendRequestCanceled string = "friendListRequestCancel"
+	FriendRemoved         string = "youAreRemovedFromFriendList"
+	IgnoreAdded           string = "youAreAddedToIgnoreList"
+	IgnoreRemoved         string = "youAreRemoveFromIgnoreList"
 )
 
 func CreateNotification(message *DialogMessage) *Notification {
@@ -18,8 +38,45 @@ func CreateNotification(message *DialogMessage) *Notification {
 		Type:     New,
 		EventID:  message.ID,
 		DialogID: message.UID,
-		Message:  *message,
+		Message:  message,
+	}
+}
+
+// CreateDialogNotification creates a notification of a new message in the dialog of dialogID
+func CreateDialogNotification(dialogID string, message *DialogMessage) *Notification {
+	return &Notification{
+		Type:     New,
+		EventID:  message.ID,
+		DialogID: dialogID,
+		Message:  message,
 	}
 }
 
-func (n *Notification) SendNotification(recipient string) {}
+func CreateOfferSoldNotification(offerID string, handbookID string, count int32) *Notification {
+	return &Notification{
+		Type:       SoldOffer,
+		EventID:    tools.GenerateMongoID(),
+		OfferID:    offerID,
+		HandbookID: handbookID,
+		Count:      count,
+	}
+}
+
+// CreateFriendNotification creates a notification of the friend list event, sent on behalf of the profile
+func CreateFriendNotification(notificationType string, requestID string, profile FriendRequestProfile) *Notification {
+	return &Notification{
+		Type:      notificationType,
+		EventID:   tools.GenerateMongoID(),
+		RequestID: requestID,
+		Profile:   &profile,
+	}
+}
+
+// CreateTraderSupplyNotification creates a notification that traders restocked, and when they restock next
+func CreateTraderSupplyNotification(nextResupply int) *Notification {
+	return &Notification{
+		Type:           TraderSupply,
+		EventID:        tools.GenerateMongoID(),
+		SupplyNextTime: nextResupply,
+	}
+}
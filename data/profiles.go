@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 
 	"github.com/alphadose/haxmap"
 
@@ -47,6 +49,10 @@ func setProfiles() {
 		return
 	}
 	for user := range users {
+		if !recoverProfile(user) {
+			continue
+		}
+
 		profile := new(Profile)
 		userPath := filepath.Join(profilesPath, user)
 		done := make(chan struct{})
@@ -55,6 +61,11 @@ func setProfiles() {
 			path := filepath.Join(userPath, "account.json")
 			if tools.FileExist(path) {
 				profile.Account = setAccount(path)
+				if profile.Account != nil && profile.Account.migratePassword() {
+					if err := profile.Account.SaveAccount(); err != nil {
+						log.Println(err)
+					}
+				}
 			}
 			done <- struct{}{}
 		}()
@@ -97,6 +108,14 @@ func setProfiles() {
 			done <- struct{}{}
 		}()
 
+		go func() {
+			path := filepath.Join(userPath, "mailbox.json")
+			if tools.FileExist(path) {
+				profile.Mailbox = setMailbox(path)
+			}
+			done <- struct{}{}
+		}()
+
 		go func() {
 			path := filepath.Join(userPath, "friends.json")
 			if tools.FileExist(path) {
@@ -107,9 +126,10 @@ func setProfiles() {
 			done <- struct{}{}
 		}()
 
-		for i := 0; i < 5; i++ {
+		for i := 0; i < 6; i++ {
 			<-done
 		}
+		profile.setLegacyMailbox()
 
 		db.profile.Set(user, profile)
 		SetProfileCache(user) //MAYBE I SET THIS SECONDARY
@@ -143,50 +163,47 @@ func (profile *Profile) SaveProfile() {
 			return
 		}
 	}
+	saves := []func() error{
+		profile.Account.SaveAccount,
+		profile.Character.SaveCharacter,
+		func() error { return profile.Dialogue.SaveDialogue(sessionID) },
+		func() error { return profile.Storage.SaveStorage(sessionID) },
+		func() error { return profile.Friends.SaveFriends(sessionID) },
+		func() error { return profile.Mailbox.SaveMailbox(sessionID) },
+	}
+
 	done := make(chan struct{})
-	go func() {
-		if err := profile.Account.SaveAccount(); err != nil {
-			log.Println(err)
-			return
-		}
-		done <- struct{}{}
-	}()
-	go func() {
-		if err := profile.Character.SaveCharacter(); err != nil {
-			log.Println(err)
-			return
-		}
-		done <- struct{}{}
-	}()
-	go func() {
-		if err := profile.Dialogue.SaveDialogue(sessionID); err != nil {
-			log.Println(err)
-			return
-		}
-		done <- struct{}{}
-	}()
-	go func() {
-		if err := profile.Storage.SaveStorage(sessionID); err != nil {
-			log.Println(err)
-			return
-		}
-		done <- struct{}{}
-	}()
-	go func() {
-		if err := profile.Friends.SaveFriends(sessionID); err != nil {
-			log.Println(err)
-			return
-		}
-		done <- struct{}{}
-	}()
+	for _, save := range saves {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			if err := save(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
 
-	for i := 0; i < 5; i++ {
+	for range saves {
 		<-done
 	}
 
+	if err := rotateProfileBackups(sessionID); err != nil {
+		log.Println(err)
+	}
 	log.Println("Profile saved")
 }
 
+// SaveProfiles saves every loaded profile, each under its lock since handlers can outlive the server shutdown
+func SaveProfiles() {
+	db.profile.ForEach(func(_ string, profile *Profile) bool {
+		profile.Lock()
+		defer profile.Unlock()
+		if profile.Account != nil {
+			profile.SaveProfile()
+		}
+		return true
+	})
+}
+
 // #endregion
 
 type Profile struct {
@@ -195,18 +212,76 @@ type Profile struct {
 	Friends   *Friends
 	Storage   *Storage
 	Dialogue  *Dialogue
+	Mailbox   *Mailbox
 	Cache     *PlayerCache
+
+	mu sync.Mutex
+}
+
+// Lock serializes changes to the profile between requests and background jobs
+func (p *Profile) Lock() {
+	p.mu.Lock()
+}
+
+// Unlock releases the profile locked by Lock
+func (p *Profile) Unlock() {
+	p.mu.Unlock()
+}
+
+// LockOtherProfiles locks the profiles of others for a request holding the profile of sessionID, taking every lock
+// in ID order so players acting on each other can not deadlock; the session's profile is released and locked again
+// if any of the others comes first. Players without a profile are skipped. The returned function unlocks the others
+func LockOtherProfiles(sessionID string, others ...string) (func(), error) {
+	self, err := GetProfileByUID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(others))
+	for _, id := range others {
+		if _, ok := db.profile.Get(id); ok && id != sessionID && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	profiles := make([]*Profile, 0, len(ids))
+	for _, id := range ids {
+		profile, _ := db.profile.Get(id)
+		profiles = append(profiles, profile)
+	}
+
+	relock := len(ids) != 0 && ids[0] < sessionID
+	if relock {
+		self.Unlock()
+	}
+	for idx, profile := range profiles {
+		if relock && ids[idx] > sessionID {
+			self.Lock()
+			relock = false
+		}
+		profile.Lock()
+	}
+	if relock {
+		self.Lock()
+	}
+
+	return func() {
+		for _, profile := range profiles {
+			profile.Unlock()
+		}
+	}, nil
 }
 
 type Dialogue map[string]*Dialog
 
 type Friends struct {
-	Friends             []FriendRequest `json:"Friends"`
-	Ignore              []string        `json:"Ignore"`
-	InIgnoreList        []string        `json:"InIgnoreList"`
-	Matching            Matching        `json:"Matching"`
-	FriendRequestInbox  []any           `json:"friendRequestInbox"`
-	FriendRequestOutbox []any           `json:"friendRequestOutbox"`
+	Friends             []FriendRequestProfile `json:"Friends"`
+	Ignore              []string               `json:"Ignore"`
+	InIgnoreList        []string               `json:"InIgnoreList"`
+	Matching            Matching               `json:"Matching"`
+	FriendRequestInbox  []FriendRequest        `json:"friendRequestInbox"`
+	FriendRequestOutbox []FriendRequest        `json:"friendRequestOutbox"`
 }
 
 type Matching struct {
@@ -222,21 +297,31 @@ type FriendRequest struct {
 }
 
 type FriendRequestProfile struct {
-	ID   int32
+	ID   string `json:"_id"`
+	AID  int    `json:"aid"`
 	Info struct {
 		Nickname       string         `json:"Nickname"`
 		Side           string         `json:"Side"`
 		Level          int8           `json:"Level"`
 		MemberCategory MemberCategory `json:"MemberCategory"`
-	}
+	} `json:"Info"`
 }
 
 type Storage struct {
 	//ID        string                 `json:"_id"`
-	Suites    []string        `json:"suites"`
-	Builds    *Builds         `json:"builds"`
-	Insurance []any           `json:"insurance"`
-	Mailbox   []*Notification `json:"mailbox"`
+	Suites    []string                    `json:"suites"`
+	Builds    *Builds                     `json:"builds"`
+	Insurance []*InsurancePackage         `json:"insurance"`
+	Mailbox   []*Notification             `json:"mailbox,omitempty"` // moved to mailbox.json, read to migrate it
+	Purchases map[string]*TraderPurchases `json:"purchases,omitempty"`
+}
+
+// InsurancePackage holds the insured items a trader returns to the player once ReturnTime has passed
+type InsurancePackage struct {
+	TraderID   string          `json:"traderId"`
+	ReturnTime int64           `json:"returnTime"`
+	Location   string          `json:"location"`
+	Items      []InventoryItem `json:"items"`
 }
 
 type Builds struct {
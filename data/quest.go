@@ -261,8 +261,7 @@ func setQuestConditions(conditions map[string]any) QuestAvailabilityConditions {
 					PreviousQuestID: condition["target"].(string),
 				}
 
-				avail, _ := condition["availableAfter"].(float64)
-				if ok {
+				if avail, ok := condition["availableAfter"].(float64); ok {
 					input.Quest[condition["id"].(string)].AvailableAfter = int(avail)
 				}
 
@@ -318,6 +317,7 @@ func setQuestConditions(conditions map[string]any) QuestAvailabilityConditions {
 				handover := &HandoverCondition{
 					ItemToHandover: condition["target"].([]any)[0].(string),
 				}
+				handover.FoundInRaid, _ = condition["onlyFoundInRaid"].(bool)
 
 				isFloat, ok := condition["value"].(float64)
 				if ok {
@@ -360,6 +360,7 @@ func setQuestConditions(conditions map[string]any) QuestAvailabilityConditions {
 				handover := &HandoverCondition{
 					ItemToHandover: condition["target"].([]any)[0].(string),
 				}
+				handover.FoundInRaid, _ = condition["onlyFoundInRaid"].(bool)
 
 				isFloat, ok := condition["value"].(float64)
 				if ok {
@@ -374,6 +375,19 @@ func setQuestConditions(conditions map[string]any) QuestAvailabilityConditions {
 					input.FindItem[condition["id"].(string)] = handover
 					continue
 				}
+			case "CounterCreator":
+				if input.CounterCreator == nil {
+					input.CounterCreator = make(map[string]*CounterCondition)
+				}
+				counterCondition := &CounterCondition{}
+				counterCondition.Type, _ = condition["type"].(string)
+				counterCondition.Value, _ = condition["value"].(float64)
+				if counter, ok := condition["counter"].(map[string]any); ok {
+					counterCondition.CounterID, _ = counter["id"].(string)
+				}
+
+				input.CounterCreator[condition["id"].(string)] = counterCondition
+				continue
 			case "Skill":
 				if input.Skills == nil {
 					input.Skills = make(map[string]*LevelCondition)
@@ -485,6 +499,7 @@ func setQuestRewards(rewards map[string]any) QuestRewardAvailabilityConditions {
 					input.Items = make(map[string]QuestRewardItem)
 				}
 				questRewardItem := QuestRewardItem{}
+				questRewardItem.FindInRaid, _ = reward["findInRaid"].(bool)
 
 				items, _ := reward["items"].([]any)
 				questRewardItem.Items = make([]map[string]any, 0, len(items))
@@ -514,7 +529,24 @@ func setQuestRewards(rewards map[string]any) QuestRewardAvailabilityConditions {
 				input.Items[reward["target"].(string)] = questRewardItem
 				continue
 			case "AssortmentUnlock":
-				input.AssortmentUnlock = reward["target"].(string)
+				if input.AssortmentUnlock == nil {
+					input.AssortmentUnlock = make(map[string]QuestRewardAssortUnlock)
+				}
+				unlock := QuestRewardAssortUnlock{}
+				unlock.TraderID, _ = reward["traderId"].(string)
+				if float, ok := reward["loyaltyLevel"].(float64); ok {
+					unlock.LoyaltyLevel = int(float)
+				}
+
+				items, _ := reward["items"].([]any)
+				unlock.Items = make([]map[string]any, 0, len(items))
+				for _, idem := range items {
+					if idem, ok := idem.(map[string]any); ok {
+						unlock.Items = append(unlock.Items, idem)
+					}
+				}
+
+				input.AssortmentUnlock[reward["target"].(string)] = unlock
 				continue
 			case "TraderStanding":
 				if input.TraderStanding == nil {
@@ -692,11 +724,20 @@ type QuestConditionTypes struct {
 	WeaponAssembly map[string]*HandoverCondition `json:"WeaponAssembly,omitempty"`
 	FindItem       map[string]*HandoverCondition `json:"FindItem,omitempty"`
 	Skills         map[string]*LevelCondition    `json:"Skill,omitempty"`
+	CounterCreator map[string]*CounterCondition  `json:"CounterCreator,omitempty"`
+}
+
+// CounterCondition is progressed in raid by the counter of CounterID until it reaches Value
+type CounterCondition struct {
+	CounterID string
+	Type      string
+	Value     float64
 }
 
 type HandoverCondition struct {
 	ItemToHandover string
 	Amount         float64
+	FoundInRaid    bool `json:",omitempty"`
 }
 
 type QuestCondition struct {
@@ -719,7 +760,7 @@ type QuestRewardAvailabilityConditions struct {
 type QuestRewards struct {
 	Experience            int                                    `json:"Experience,omitempty"`
 	Items                 map[string]QuestRewardItem             `json:"Item,omitempty"`
-	AssortmentUnlock      string                                 `json:"AssortmentUnlock,omitempty"`
+	AssortmentUnlock      map[string]QuestRewardAssortUnlock     `json:"AssortmentUnlock,omitempty"`
 	TraderStanding        map[string]float64                     `json:"TraderStanding,omitempty"`
 	TraderStandingRestore map[string]float64                     `json:"TraderStandingRestore,omitempty"`
 	TraderUnlock          string                                 `json:"TraderUnlock,omitempty"`
@@ -740,6 +781,7 @@ type QuestRewardItem struct {
 }
 
 type QuestRewardAssortUnlock struct {
+	TraderID     string
 	Items        []map[string]any
 	LoyaltyLevel int
 }
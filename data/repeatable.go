@@ -0,0 +1,180 @@
+package data
+
+import (
+	"log"
+	"mtgo/tools"
+
+	"github.com/goccy/go-json"
+)
+
+// #region Repeatable quest getters
+
+func GetRepeatableQuestConfigs() []*RepeatableQuestConfig {
+	return db.core.RepeatableQuests
+}
+
+// GetRewardScalingIndex returns the index of the level bracket the level falls in
+func (c *RepeatableQuestConfig) GetRewardScalingIndex(level int8) int {
+	index := 0
+	for idx, minimum := range c.RewardScaling.Levels {
+		if level >= minimum {
+			index = idx
+		}
+	}
+	return index
+}
+
+// GetEliminationConfig returns the elimination parameters of the level bracket the level falls in
+func (c *RepeatableQuestConfig) GetEliminationConfig(level int8) *RepeatableEliminationConfig {
+	for idx, elimination := range c.Elimination {
+		if level >= elimination.MinLevel && level <= elimination.MaxLevel {
+			return &c.Elimination[idx]
+		}
+	}
+	return nil
+}
+
+// GetQuery converts the repeatable quest to a Query, so it can be handed over, completed and failed like
+// any other quest
+func (q *RepeatableQuest) GetQuery() *Query {
+	conditions := make(map[string]any, len(q.Conditions))
+	for category, list := range q.Conditions {
+		conditions[category] = list
+	}
+
+	rewards := make(map[string]any, len(q.Rewards))
+	for category, list := range q.Rewards {
+		rewards[category] = list
+	}
+
+	return &Query{
+		Name:   q.ID,
+		Trader: q.TraderID,
+		Dialogue: QuestDialogues{
+			Description: q.Description,
+			Started:     q.StartedMessageText,
+			Success:     q.SuccessMessageText,
+			Fail:        q.FailMessageText,
+		},
+		Conditions: setQuestConditions(conditions),
+		Rewards:    setQuestRewards(rewards),
+	}
+}
+
+// #endregion
+
+// #region Repeatable quest setters
+
+func setRepeatableQuestConfigs() {
+	raw := tools.GetJSONRawMessage(repeatablePath)
+	if err := json.UnmarshalNoEscape(raw, &db.core.RepeatableQuests); err != nil {
+		msg := tools.CheckParsingError(raw, err)
+		log.Fatalln(msg)
+	}
+}
+
+// #endregion
+
+// #region Repeatable quest structs
+
+type RepeatableQuestConfig struct {
+	Name                 string                        `json:"name"`
+	Types                []string                      `json:"types"`
+	ResetTime            int                           `json:"resetTime"`
+	NumQuests            int                           `json:"numQuests"`
+	MinPlayerLevel       int8                          `json:"minPlayerLevel"`
+	FreeChanges          int                           `json:"freeChanges"`
+	RewardScaling        RepeatableRewardScaling       `json:"rewardScaling"`
+	Traders              map[string][]string           `json:"traders"`
+	Locations            map[string]string             `json:"locations"`
+	Elimination          []RepeatableEliminationConfig `json:"elimination"`
+	Completion           RepeatableCompletionConfig    `json:"completion"`
+	Exploration          RepeatableExplorationConfig   `json:"exploration"`
+	RewardItemCategories []string                      `json:"rewardItemCategories"`
+}
+
+// RepeatableRewardScaling holds the rewards of each level bracket, starting at the level of the same index
+type RepeatableRewardScaling struct {
+	Levels             []int8    `json:"levels"`
+	Experience         []float64 `json:"experience"`
+	Roubles            []float64 `json:"roubles"`
+	Items              []int     `json:"items"`
+	Reputation         []float64 `json:"reputation"`
+	RerollCost         []int32   `json:"rerollCost"`
+	RerollStandingCost []float64 `json:"rerollStandingCost"`
+}
+
+type RepeatableEliminationConfig struct {
+	MinLevel       int8           `json:"minLevel"`
+	MaxLevel       int8           `json:"maxLevel"`
+	Targets        map[string]int `json:"targets"`
+	MinKills       int            `json:"minKills"`
+	MaxKills       int            `json:"maxKills"`
+	LocationChance int            `json:"locationChance"`
+}
+
+type RepeatableCompletionConfig struct {
+	ItemCategories []string `json:"itemCategories"`
+	MinItems       int      `json:"minItems"`
+	MaxItems       int      `json:"maxItems"`
+	MinCount       int      `json:"minCount"`
+	MaxCount       int      `json:"maxCount"`
+	FoundInRaid    bool     `json:"foundInRaid"`
+}
+
+type RepeatableExplorationConfig struct {
+	MinExtracts int `json:"minExtracts"`
+	MaxExtracts int `json:"maxExtracts"`
+}
+
+type RepeatableQuestSet struct {
+	ID                   string                                 `json:"id"`
+	Name                 string                                 `json:"name"`
+	EndTime              int                                    `json:"endTime"`
+	ActiveQuests         []RepeatableQuest                      `json:"activeQuests"`
+	InactiveQuests       []RepeatableQuest                      `json:"inactiveQuests"`
+	ChangeRequirement    map[string]RepeatableChangeRequirement `json:"changeRequirement"`
+	FreeChanges          int                                    `json:"freeChanges"`
+	FreeChangesAvailable int                                    `json:"freeChangesAvailable"`
+}
+
+type RepeatableChangeRequirement struct {
+	ChangeCost         []RepeatableChangeCost `json:"changeCost"`
+	ChangeStandingCost float64                `json:"changeStandingCost"`
+}
+
+type RepeatableChangeCost struct {
+	TemplateID string `json:"templateId"`
+	Count      int32  `json:"count"`
+}
+
+type RepeatableQuest struct {
+	ID                         string                 `json:"_id"`
+	TraderID                   string                 `json:"traderId"`
+	Location                   string                 `json:"location"`
+	Image                      string                 `json:"image"`
+	Type                       string                 `json:"type"`
+	IsKey                      bool                   `json:"isKey"`
+	Restartable                bool                   `json:"restartable"`
+	InstantComplete            bool                   `json:"instantComplete"`
+	SecretQuest                bool                   `json:"secretQuest"`
+	CanShowNotificationsInGame bool                   `json:"canShowNotificationsInGame"`
+	Rewards                    map[string][]any       `json:"rewards"`
+	Conditions                 map[string][]any       `json:"conditions"`
+	Side                       string                 `json:"side"`
+	Name                       string                 `json:"name"`
+	Note                       string                 `json:"note"`
+	Description                string                 `json:"description"`
+	SuccessMessageText         string                 `json:"successMessageText"`
+	FailMessageText            string                 `json:"failMessageText"`
+	StartedMessageText         string                 `json:"startedMessageText"`
+	ChangeQuestMessageText     string                 `json:"changeQuestMessageText"`
+	AcceptPlayerMessage        string                 `json:"acceptPlayerMessage"`
+	DeclinePlayerMessage       string                 `json:"declinePlayerMessage"`
+	CompletePlayerMessage      string                 `json:"completePlayerMessage"`
+	TemplateID                 string                 `json:"templateId"`
+	ChangeCost                 []RepeatableChangeCost `json:"changeCost"`
+	ChangeStandingCost         float64                `json:"changeStandingCost"`
+}
+
+// #endregion
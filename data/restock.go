@@ -0,0 +1,317 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"mtgo/tools"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	traderStockPath        string = "user/traders/"
+	defaultRestockInterval int32  = 3600
+)
+
+const (
+	assortOutOfStock   string = "Assort item %s of trader %s has %d left, %d requested"
+	assortRestricted   string = "Assort item %s of trader %s is limited to %d per restock, %d already bought"
+	assortNotInStock   string = "Assort item %s of trader %s is not a stocked item"
+	traderStockMissing string = "Trader %s has no stock"
+)
+
+// TraderStock is the trader's stock of limited assort items, shared by every player and restocked every
+// RestockInterval seconds of the trader's base; its lock also guards the trader's assort, which Fence's restock
+// swaps out
+type TraderStock struct {
+	mu           sync.Mutex
+	NextResupply int              `json:"nextResupply"`
+	Stock        map[string]int32 `json:"stock"`
+	initial      map[string]int32
+	items        map[string]*AssortItem
+}
+
+// TraderPurchases counts the assort items a player bought from a trader since its last restock
+type TraderPurchases struct {
+	Resupply int              `json:"resupply"`
+	Counts   map[string]int16 `json:"counts"`
+}
+
+// setTraderStock indexes the trader's limited root assort items, restoring the stock saved before the last shutdown
+func (t *Trader) setTraderStock() {
+	stock := &TraderStock{Stock: make(map[string]int32)}
+	t.Stock = stock
+	t.setStockItems()
+
+	path := filepath.Join(traderStockPath, t.Base.ID+".json")
+	if tools.FileExist(path) {
+		saved := new(TraderStock)
+		raw := tools.GetJSONRawMessage(path)
+		if err := json.UnmarshalNoEscape(raw, saved); err != nil {
+			log.Println(tools.CheckParsingError(raw, err))
+		} else {
+			stock.NextResupply = saved.NextResupply
+			for id, count := range saved.Stock {
+				if _, ok := stock.initial[id]; ok {
+					stock.items[id].Upd.StackObjectsCount = count
+				}
+			}
+		}
+	}
+
+	if stock.NextResupply == 0 {
+		stock.NextResupply = int(tools.GetCurrentTimeInSeconds()) + t.getRestockInterval()
+	}
+	t.setNextResupply(stock.NextResupply)
+}
+
+// setStockItems indexes the root items of the trader's assort, and the initial stock of the limited ones
+func (t *Trader) setStockItems() {
+	t.Stock.initial, t.Stock.items = getStockItems(t.Assort)
+}
+
+func getStockItems(assort *Assort) (map[string]int32, map[string]*AssortItem) {
+	initial := make(map[string]int32)
+	items := make(map[string]*AssortItem)
+	for _, item := range assort.Items {
+		if item.ParentID != "hideout" || item.Upd == nil {
+			continue
+		}
+
+		items[item.ID] = item
+		if !item.Upd.UnlimitedCount {
+			initial[item.ID] = item.Upd.StackObjectsCount
+		}
+	}
+	return initial, items
+}
+
+func (t *Trader) getRestockInterval() int {
+	if t.Base.RestockInterval <= 0 {
+		return int(defaultRestockInterval)
+	}
+	return int(t.Base.RestockInterval)
+}
+
+func (t *Trader) setNextResupply(nextResupply int) {
+	t.Stock.NextResupply = nextResupply
+	t.Assort.NextResupply = nextResupply
+	t.Base.NextResupply = int32(nextResupply)
+}
+
+// Restock refills the trader's stock if its resupply time has passed, returning whether it did
+func (t *Trader) Restock(now int) bool {
+	if t.Stock == nil {
+		return false
+	}
+
+	t.Stock.mu.Lock()
+	due := t.Stock.NextResupply <= now
+	t.Stock.mu.Unlock()
+	if !due {
+		return false
+	}
+
+	// Fence's new assort is generated before locking, so players are not kept waiting on it
+	var generated *fenceAssort
+	if t.Base.ID == fenceID {
+		generated = generateFenceAssort()
+	}
+
+	t.Stock.mu.Lock()
+	defer t.Stock.mu.Unlock()
+
+	if t.Stock.NextResupply > now {
+		return false
+	}
+
+	if generated != nil {
+		t.setFenceAssort(generated)
+	}
+	for id, count := range t.Stock.initial {
+		t.Stock.items[id].Upd.StackObjectsCount = count
+	}
+
+	interval := t.getRestockInterval()
+	next := t.Stock.NextResupply
+	for next <= now {
+		next += interval
+	}
+	t.setNextResupply(next)
+
+	if err := t.saveTraderStock(); err != nil {
+		log.Println(err)
+	}
+	return true
+}
+
+// RestockTraders restocks every trader whose resupply time has passed, returning whether any did
+func RestockTraders() bool {
+	now := int(tools.GetCurrentTimeInSeconds())
+	restocked := false
+	db.trader.Traders.ForEach(func(_ string, trader *Trader) bool {
+		if trader.Restock(now) {
+			restocked = true
+		}
+		return true
+	})
+	return restocked
+}
+
+// GetNextResupply returns the earliest time a trader restocks
+func GetNextResupply() int {
+	next := 0
+	db.trader.Traders.ForEach(func(_ string, trader *Trader) bool {
+		if trader.Stock == nil {
+			return true
+		}
+
+		trader.Stock.mu.Lock()
+		if next == 0 || trader.Stock.NextResupply < next {
+			next = trader.Stock.NextResupply
+		}
+		trader.Stock.mu.Unlock()
+		return true
+	})
+	return next
+}
+
+// CanBuyAssortItem returns an error if the trader's stock or the player's buy restriction does not allow buying
+// count of the assort item
+func (t *Trader) CanBuyAssortItem(storage *Storage, assortID string, count int32) error {
+	if t.Stock == nil {
+		return fmt.Errorf(traderStockMissing, t.Base.ID)
+	}
+
+	t.Stock.mu.Lock()
+	defer t.Stock.mu.Unlock()
+	return t.checkAssortPurchase(storage, assortID, count)
+}
+
+// BuyAssortItem takes count of the assort item out of the trader's stock and counts it against the player's buy
+// restriction, saving the trader's stock
+func (t *Trader) BuyAssortItem(storage *Storage, assortID string, count int32) error {
+	if t.Stock == nil {
+		return fmt.Errorf(traderStockMissing, t.Base.ID)
+	}
+
+	t.Stock.mu.Lock()
+	defer t.Stock.mu.Unlock()
+	if err := t.checkAssortPurchase(storage, assortID, count); err != nil {
+		return err
+	}
+
+	item := t.Stock.items[assortID]
+	if item.Upd.BuyRestrictionMax != 0 {
+		t.addPurchase(storage, assortID, int16(count))
+	}
+	if _, ok := t.Stock.initial[assortID]; !ok {
+		return nil
+	}
+
+	item.Upd.StackObjectsCount -= count
+	return t.saveTraderStock()
+}
+
+func (t *Trader) checkAssortPurchase(storage *Storage, assortID string, count int32) error {
+	item, ok := t.Stock.items[assortID]
+	if !ok {
+		return fmt.Errorf(assortNotInStock, assortID, t.Base.ID)
+	}
+
+	if _, ok := t.Stock.initial[assortID]; ok && item.Upd.StackObjectsCount < count {
+		return fmt.Errorf(assortOutOfStock, assortID, t.Base.ID, item.Upd.StackObjectsCount, count)
+	}
+
+	if limit := item.Upd.BuyRestrictionMax; limit != 0 {
+		bought := t.getPurchaseCounts(storage)[assortID]
+		if int32(bought)+count > int32(limit) {
+			return fmt.Errorf(assortRestricted, assortID, t.Base.ID, limit, bought)
+		}
+	}
+	return nil
+}
+
+// getPurchaseCounts returns the player's purchases from the trader since its last restock, without changing the
+// storage; it is nil if they bought nothing since
+func (t *Trader) getPurchaseCounts(storage *Storage) map[string]int16 {
+	purchases, ok := storage.Purchases[t.Base.ID]
+	if !ok || purchases.Resupply != t.Stock.NextResupply {
+		return nil
+	}
+	return purchases.Counts
+}
+
+// addPurchase counts the assort items bought against the player's buy restriction, resetting their purchases from
+// the trader if it restocked since
+func (t *Trader) addPurchase(storage *Storage, assortID string, count int16) {
+	if storage.Purchases == nil {
+		storage.Purchases = make(map[string]*TraderPurchases)
+	}
+
+	purchases, ok := storage.Purchases[t.Base.ID]
+	if !ok || purchases.Resupply != t.Stock.NextResupply {
+		purchases = &TraderPurchases{
+			Resupply: t.Stock.NextResupply,
+			Counts:   make(map[string]int16),
+		}
+		storage.Purchases[t.Base.ID] = purchases
+	}
+	purchases.Counts[assortID] += count
+}
+
+// setAssortStock copies the trader's stock, next resupply and the player's purchases into their stripped assort;
+// the caller holds the stock lock
+func (t *Trader) setAssortStock(assort *Assort, storage *Storage) {
+	if t.Stock == nil {
+		return
+	}
+
+	assort.NextResupply = t.Stock.NextResupply
+	var counts map[string]int16
+	if storage != nil {
+		counts = t.getPurchaseCounts(storage)
+	}
+
+	for _, item := range assort.Items {
+		stocked, ok := t.Stock.items[item.ID]
+		if !ok || item.Upd == nil {
+			continue
+		}
+
+		item.Upd.StackObjectsCount = stocked.Upd.StackObjectsCount
+		item.Upd.BuyRestrictionCurrent = counts[item.ID]
+	}
+}
+
+// copyStockedItem copies the assort item if it is stocked, as its stock and buy restriction are set per player,
+// and shares it otherwise
+func (t *Trader) copyStockedItem(item *AssortItem) *AssortItem {
+	if t.Stock == nil {
+		return item
+	}
+	if _, ok := t.Stock.items[item.ID]; !ok {
+		return item
+	}
+
+	stocked := *item
+	upd := *item.Upd
+	stocked.Upd = &upd
+	return &stocked
+}
+
+func (t *Trader) saveTraderStock() error {
+	clear(t.Stock.Stock)
+	for id := range t.Stock.initial {
+		t.Stock.Stock[id] = t.Stock.items[id].Upd.StackObjectsCount
+	}
+
+	path := filepath.Join(traderStockPath, t.Base.ID+".json")
+	if err := tools.WriteToFile(path, t.Stock); err != nil {
+		return fmt.Errorf("trader stock not saved: %w", err)
+	}
+	return nil
+}
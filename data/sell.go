@@ -0,0 +1,134 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// defaultSaleStandingPerRouble is the standing a sale gains with the trader for every rouble it is worth, unless the
+// server config sets one: 0.01 standing for every 100,000 roubles sold
+const defaultSaleStandingPerRouble float64 = 0.0000001
+
+const (
+	itemNotBought        string = "Trader %s does not buy item %s"
+	itemProhibited       string = "Trader %s refuses to buy item %s"
+	loyaltyLevelsMissing string = "Trader %s has no loyalty levels"
+)
+
+// IsItemBought returns an error if the item is outside the trader's items_buy or inside its items_buy_prohibited
+func (t *Trader) IsItemBought(item *DatabaseItem) error {
+	prohibited := t.Base.ItemsBuyProhibited
+	if slices.Contains(prohibited.IdList, item.ID) || item.IsChildOf(prohibited.Category) {
+		return fmt.Errorf(itemProhibited, t.Base.ID, item.ID)
+	}
+
+	bought := t.Base.ItemsBuy
+	if !slices.Contains(bought.IdList, item.ID) && !item.IsChildOf(bought.Category) {
+		return fmt.Errorf(itemNotBought, t.Base.ID, item.ID)
+	}
+	return nil
+}
+
+// GetSellPrice returns what the trader pays in their currency for the family of inventory items, at the
+// character's loyalty level, and what it is worth in roubles
+func (t *Trader) GetSellPrice(character *Character[map[string]PlayerTradersInfo], family []InventoryItem) (int32, float64, error) {
+	var roubles float64
+	for _, item := range family {
+		price, err := GetInventoryItemPrice(&item)
+		if err != nil {
+			return 0, 0, err
+		}
+		roubles += price
+	}
+
+	if len(t.Base.LoyaltyLevels) == 0 {
+		return 0, 0, fmt.Errorf(loyaltyLevelsMissing, t.Base.ID)
+	}
+	level := min(max(int(character.TradersInfo[t.Base.ID].LoyaltyLevel), 1), len(t.Base.LoyaltyLevels))
+	coef := t.Base.LoyaltyLevels[level-1].BuyPriceCoef
+	roubles = math.Floor(roubles * float64(100-coef) / 100)
+
+	currency := *GetCurrencyByName(t.Base.Currency)
+	if IsCurrencyByID(currency) && currency != *GetCurrencyByName("RUB") {
+		price, err := ConvertFromRouble(int32(roubles), currency)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int32(price), roubles, nil
+	}
+	return int32(roubles), roubles, nil
+}
+
+// GetInventoryItemPrice returns the handbook price in roubles of the inventory item, scaled by its durability or
+// resource left and its stack count
+func GetInventoryItemPrice(item *InventoryItem) (float64, error) {
+	price, err := GetPriceByID(item.TPL)
+	if err != nil {
+		return 0, err
+	}
+
+	dbItem, err := GetItemByID(item.TPL)
+	if err != nil {
+		return 0, err
+	}
+
+	value := float64(price) * getItemCondition(dbItem, item.UPD)
+	if item.UPD != nil && item.UPD.StackObjectsCount > 1 {
+		value *= float64(item.UPD.StackObjectsCount)
+	}
+	return value, nil
+}
+
+// getItemCondition returns the share of its durability or resource the item has left
+func getItemCondition(item *DatabaseItem, upd *ItemUpdate) float64 {
+	if upd == nil {
+		return 1
+	}
+
+	switch {
+	case upd.Repairable != nil:
+		return getConditionRatio(upd.Repairable.Durability, upd.Repairable.MaxDurability)
+	case upd.MedKit != nil:
+		return getConditionRatio(float64(upd.MedKit.HpResource), getItemProp(item, "MaxHpResource"))
+	case upd.FoodDrink != nil:
+		return getConditionRatio(float64(upd.FoodDrink.HpPercent), getItemProp(item, "MaxResource"))
+	case upd.Resource != nil:
+		return getConditionRatio(float64(upd.Resource.Value), max(getItemProp(item, "MaxResource"), getItemProp(item, "Resource")))
+	case upd.RepairKit != nil:
+		return getConditionRatio(float64(upd.RepairKit.Resource), getItemProp(item, "MaxRepairResource"))
+	}
+	return 1
+}
+
+func getConditionRatio(current float64, maximum float64) float64 {
+	if maximum <= 0 {
+		return 1
+	}
+	return min(max(current/maximum, 0), 1)
+}
+
+func getItemProp(item *DatabaseItem, key string) float64 {
+	value, _ := item.Props[key].(float64)
+	return value
+}
+
+// GetSaleStandingPerRouble returns the standing a sale gains with the trader for every rouble it is worth
+func GetSaleStandingPerRouble() float64 {
+	rate := db.core.ServerConfig.Trading.SaleStandingPerRouble
+	if rate <= 0 {
+		rate = defaultSaleStandingPerRouble
+	}
+	return rate
+}
+
+// AddSale adds the sale, in the trader's currency and worth roubles, to the character's sales sum and standing with
+// the trader, then updates their loyalty level
+func (t *Trader) AddSale(character *Character[map[string]PlayerTradersInfo], price int32, roubles float64) {
+	traderInfo := character.TradersInfo[t.Base.ID]
+	traderInfo.SalesSum += float32(price)
+	traderInfo.Standing += float32(roubles * GetSaleStandingPerRouble())
+	character.TradersInfo[t.Base.ID] = traderInfo
+
+	t.SetTraderLoyaltyLevel(character)
+}
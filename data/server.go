@@ -136,6 +136,22 @@ type ServerConfig struct {
 	Secure             bool        `json:"secure"`
 	DownloadImageFiles bool        `json:"downloadImageFiles"`
 	Ports              ServerPorts `json:"ports"`
+	Mail               ServerMail  `json:"mail"`
+	Trading            ServerTrade `json:"trading"`
+	SessionLifetime    int64       `json:"sessionLifetime"`
+	ProfileBackups     int         `json:"profileBackups"`
+	RestoreBackups     bool        `json:"restoreBackups"`
+}
+
+// ServerMail holds, in hours, how long mail attachments can be redeemed for and how long messages are kept
+type ServerMail struct {
+	RedeemTime  int32 `json:"redeemTime"`
+	StorageTime int32 `json:"storageTime"`
+}
+
+// ServerTrade holds the standing a sale to a trader gains for every rouble it is worth
+type ServerTrade struct {
+	SaleStandingPerRouble float64 `json:"saleStandingPerRouble"`
 }
 
 type ServerPorts struct {
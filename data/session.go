@@ -0,0 +1,84 @@
+package data
+
+import (
+	"errors"
+	"log"
+	"mtgo/tools"
+	"sync/atomic"
+)
+
+const defaultSessionLifetime int64 = 24
+
+var errSessionInvalid = errors.New("session is invalid or has expired")
+
+// Session maps a session token to the profile it was issued for; Expires is extended by concurrent requests
+type Session struct {
+	ProfileID string
+	Expires   atomic.Int64
+}
+
+// CreateSession issues a random session token for the profile, which expires when unused for the configured
+// session lifetime
+func CreateSession(profileID string) (string, error) {
+	token, err := tools.GenerateSessionToken()
+	if err != nil {
+		return "", err
+	}
+
+	session := &Session{ProfileID: profileID}
+	session.Expires.Store(tools.GetCurrentTimeInSeconds() + getSessionLifetime())
+	db.cache.server.sessions.Set(token, session)
+	log.Println("Session created for", profileID)
+	return token, nil
+}
+
+// GetSessionProfileID returns the ID of the profile the token was issued for, extending the session
+func GetSessionProfileID(token string) (string, error) {
+	session, ok := db.cache.server.sessions.Get(token)
+	if !ok {
+		return "", errSessionInvalid
+	}
+
+	now := tools.GetCurrentTimeInSeconds()
+	if session.Expires.Load() <= now {
+		db.cache.server.sessions.Del(token)
+		return "", errSessionInvalid
+	}
+
+	session.Expires.Store(now + getSessionLifetime())
+	return session.ProfileID, nil
+}
+
+// DeleteSession revokes the session token
+func DeleteSession(token string) {
+	db.cache.server.sessions.Del(token)
+}
+
+// DeleteProfileSessions revokes every session issued for the profile
+func DeleteProfileSessions(profileID string) {
+	db.cache.server.sessions.ForEach(func(token string, session *Session) bool {
+		if session.ProfileID == profileID {
+			db.cache.server.sessions.Del(token)
+		}
+		return true
+	})
+}
+
+// HasSession returns whether the profile has a session that has not expired
+func HasSession(profileID string) bool {
+	now := tools.GetCurrentTimeInSeconds()
+	found := false
+	db.cache.server.sessions.ForEach(func(_ string, session *Session) bool {
+		found = session.ProfileID == profileID && session.Expires.Load() > now
+		return !found
+	})
+	return found
+}
+
+func getSessionLifetime() int64 {
+	hours := db.core.ServerConfig.SessionLifetime
+	if hours <= 0 {
+		hours = defaultSessionLifetime
+	}
+	return hours * 3600
+}
@@ -16,7 +16,8 @@ func setStorage(path string) *Storage {
 	data := tools.GetJSONRawMessage(path)
 	if err := json.UnmarshalNoEscape(data, output); err != nil {
 		msg := tools.CheckParsingError(data, err)
-		log.Fatalln(msg)
+		log.Println(msg)
+		return output.CreateStorage()
 	}
 
 	return output
@@ -43,8 +44,7 @@ func (storage *Storage) CreateStorage() *Storage {
 			WeaponBuilds:    make([]*WeaponBuild, 0),
 			MagazineBuilds:  make([]*struct{}, 0),
 		},
-		Insurance: make([]any, 0),
-		Mailbox:   make([]*Notification, 0),
+		Insurance: make([]*InsurancePackage, 0),
 	}
 }
 
@@ -4,7 +4,8 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
-	"time"
+	"slices"
+	"sync"
 
 	"github.com/alphadose/haxmap"
 
@@ -80,6 +81,11 @@ func CloneTrader(name string) *Trader {
 
 // GetAssortItemByID returns entire item from assort as a slice (to get parent item use [0] when calling)
 func (t *Trader) GetAssortItemByID(id string) []*AssortItem {
+	if t.Stock != nil {
+		t.Stock.mu.Lock()
+		defer t.Stock.mu.Unlock()
+	}
+
 	item, ok := t.Index.Assort.Items.Get(id)
 	if ok {
 		return []*AssortItem{t.Assort.Items[item]}
@@ -118,8 +124,21 @@ func (t *Trader) GetStrippedAssort(character *Character[map[string]PlayerTraders
 		return nil, err
 	}
 
+	storage, err := GetStorageByID(character.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the stock lock also guards the assort, which Fence's restock swaps out
+	if t.Stock != nil {
+		t.Stock.mu.Lock()
+		defer t.Stock.mu.Unlock()
+	}
+
+	fence := t.getFenceView(character)
 	cachedAssort, ok := cache.Assorts[traderID]
-	if ok {
+	if ok && cache.Generations[traderID] == t.generation && (fence == nil || cache.FenceLevel == fence.level) {
+		t.setAssortStock(cachedAssort, storage)
 		return cachedAssort, nil
 	}
 
@@ -141,28 +160,21 @@ func (t *Trader) GetStrippedAssort(character *Character[map[string]PlayerTraders
 		LoyalLevelItems: haxmap.New[string, int8](),
 	}
 
-	// TODO: add quest checks
+	statuses := make(map[string]string, len(character.Quests))
+	for _, quest := range character.Quests {
+		statuses[quest.QID] = quest.Status
+	}
 
 	var counter int16
 	t.Assort.LoyalLevelItems.ForEach(func(loyalID string, loyalLevel int8) bool {
-		if loyaltyLevel >= loyalLevel {
-			assort.LoyalLevelItems.Set(loyalID, loyalLevel)
-
-			/* if t.QuestAssort == nil {
-				loyalLevelItems[loyalID] = loyalLevel
-				continue
+		if fence != nil {
+			if fence.isOfferVisible(loyalID) {
+				assort.LoyalLevelItems.Set(loyalID, loyalLevel)
 			}
-
-			for _, condition := range t.QuestAssort {
-				if len(condition) == 0 {
-					continue
-				}
-
-				for aid, qid := range condition {
-
-
-				}
-			} */
+			return true
+		}
+		if loyaltyLevel >= loyalLevel && t.isQuestAssortUnlocked(loyalID, statuses) {
+			assort.LoyalLevelItems.Set(loyalID, loyalLevel)
 		}
 		return true
 	})
@@ -174,89 +186,147 @@ func (t *Trader) GetStrippedAssort(character *Character[map[string]PlayerTraders
 				log.Fatal("ya momma")
 			}
 
-			assort.BarterScheme.Set(loyalID, barterScheme)
+			assort.BarterScheme.Set(loyalID, fence.getBarterScheme(barterScheme))
 			assortIndex.Items.Set(loyalID, counter)
 			counter++
-			assort.Items = append(assort.Items, t.Assort.Items[index])
+			assort.Items = append(assort.Items, t.copyStockedItem(t.Assort.Items[index]))
 		} else if family, ok := t.Index.Assort.ParentItems.Get(loyalID); ok {
 			barterScheme, ok := t.Assort.BarterScheme.Get(loyalID)
 			if !ok {
 				log.Fatal("ya momma")
 			}
-			assort.BarterScheme.Set(loyalID, barterScheme)
+			assort.BarterScheme.Set(loyalID, fence.getBarterScheme(barterScheme))
 			assortIndex.ParentItems.Set(loyalID, make(map[string]int16))
 			parentItems, _ := assortIndex.ParentItems.Get(loyalID)
 			for k, v := range family {
 				parentItems[k] = counter
 				counter++
-				assort.Items = append(assort.Items, t.Assort.Items[v])
+				assort.Items = append(assort.Items, t.copyStockedItem(t.Assort.Items[v]))
 			}
 		}
 		return true
 	})
 
-	assort.NextResupply = SetResupplyTimer()
+	t.setAssortStock(&assort, storage)
 
 	cache.Index[traderID] = &assortIndex
 	cache.Assorts[traderID] = &assort
+	cache.Generations[traderID] = t.generation
+	if fence != nil {
+		cache.FenceLevel = fence.level
+	}
 
 	return cache.Assorts[traderID], nil
 }
 
-type ResupplyTimer struct {
-	TimerResupplyTime     time.Duration
-	ResupplyTimeInSeconds int
-	NextResupplyTime      int
-	TimerSet              bool
-	Profiles              []string
-}
-
-var rs = &ResupplyTimer{
-	TimerResupplyTime:     0,
-	ResupplyTimeInSeconds: 3600, //1 hour
-	NextResupplyTime:      0,
-	TimerSet:              false,
-	Profiles:              make([]string, 0),
+// questAssortStatuses are the quest statuses that unlock the entries of each questassort table
+var questAssortStatuses = map[string][]string{
+	"started": {Started, ForFinish, Success},
+	"success": {Success},
+	"fail":    {Fail},
 }
 
-func SetResupplyTimer() int {
-	if rs.TimerSet {
-		return rs.NextResupplyTime
+// isQuestAssortUnlocked returns whether the quest statuses, keyed by quest ID, unlock the assort entry; entries
+// missing from the trader's questassort and from its quests' AssortmentUnlock rewards are always unlocked
+func (t *Trader) isQuestAssortUnlocked(assortID string, statuses map[string]string) bool {
+	if qid, ok := t.getAssortUnlocks()[assortID]; ok && statuses[qid] != Success {
+		return false
 	}
-
-	rs.NextResupplyTime = int(tools.GetCurrentTimeInSeconds()) + rs.ResupplyTimeInSeconds
-	rs.TimerResupplyTime = time.Duration(rs.ResupplyTimeInSeconds) * time.Second
-
-	profiles := GetProfiles()
-	profiles.ForEach(func(key string, value *Profile) bool {
-		rs.Profiles = append(rs.Profiles, value.Account.UID)
+	if t.QuestAssort == nil {
 		return true
+	}
+
+	unlocked := true
+	t.QuestAssort.ForEach(func(table string, entries map[string]string) bool {
+		qid, ok := entries[assortID]
+		if !ok {
+			return true
+		}
+		unlocked = slices.Contains(questAssortStatuses[table], statuses[qid])
+		return unlocked
 	})
+	return unlocked
+}
 
-	rs.TimerSet = true
+// getAssortUnlocks returns the assort entries the AssortmentUnlock rewards of quests unlock, keyed to their quest.
+// A reward matches the questassort entry sharing its ID, or else the first entry of the quest's success table of
+// its item that no other reward claims; rewards matching neither are logged and left out
+func (t *Trader) getAssortUnlocks() map[string]string {
+	t.unlocksOnce.Do(func() {
+		t.unlocks = make(map[string]string)
+		if t.Fence != nil || t.Assort == nil || t.Index.Assort == nil {
+			return
+		}
 
-	go func() {
-		timer := time.NewTimer(rs.TimerResupplyTime)
-		for {
-			<-timer.C
-			rs.NextResupplyTime += rs.ResupplyTimeInSeconds
+		qids := make([]string, 0)
+		db.quest.query.ForEach(func(qid string, _ *Query) bool {
+			qids = append(qids, qid)
+			return true
+		})
+		slices.Sort(qids)
 
-			for _, pid := range rs.Profiles {
-				traders, err := GetTraderCacheByID(pid)
-				if err != nil {
+		for _, qid := range qids {
+			query, _ := db.quest.query.Get(qid)
+			if query.Rewards.Success == nil {
+				continue
+			}
+			for target, unlock := range query.Rewards.Success.AssortmentUnlock {
+				if unlock.TraderID != t.Base.ID || len(unlock.Items) == 0 {
 					continue
 				}
 
-				for _, assort := range traders.Assorts {
-					assort.NextResupply = rs.NextResupplyTime
+				assortID := t.findAssortUnlock(qid, target, unlock)
+				if assortID == "" {
+					log.Printf(assortUnlockNotFound, target, qid, t.Base.ID)
+					continue
 				}
+				t.unlocks[assortID] = qid
 			}
+		}
+	})
+	return t.unlocks
+}
+
+const assortUnlockNotFound string = "AssortmentUnlock %s of quest %s matches no questassort entry of trader %s\n"
 
-			timer.Reset(rs.TimerResupplyTime)
+func (t *Trader) findAssortUnlock(qid string, target string, unlock QuestRewardAssortUnlock) string {
+	if t.QuestAssort == nil {
+		return ""
+	}
+
+	listed := false
+	t.QuestAssort.ForEach(func(_ string, entries map[string]string) bool {
+		_, listed = entries[target]
+		return !listed
+	})
+	if listed {
+		return target
+	}
+
+	success, ok := t.QuestAssort.Get("success")
+	if !ok {
+		return ""
+	}
+
+	assortIDs := make([]string, 0)
+	for assortID, entryQID := range success {
+		if entryQID == qid {
+			assortIDs = append(assortIDs, assortID)
 		}
-	}()
+	}
+	slices.Sort(assortIDs)
 
-	return rs.NextResupplyTime
+	tpl, _ := unlock.Items[0]["_tpl"].(string)
+	for _, assortID := range assortIDs {
+		if _, claimed := t.unlocks[assortID]; claimed {
+			continue
+		}
+		index, ok := t.Index.Assort.Items.Get(assortID)
+		if ok && t.Assort.Items[index].Tpl == tpl {
+			return assortID
+		}
+	}
+	return ""
 }
 
 // SetTraderLoyaltyLevel determines the loyalty level of a trader based on character attributes
@@ -270,18 +340,24 @@ func (t *Trader) SetTraderLoyaltyLevel(character *Character[map[string]PlayerTra
 	}
 
 	length := int8(len(loyaltyLevels))
+	level := length
 	for idx := traderInfo.LoyaltyLevel; idx < length; idx++ {
 		loyalty := loyaltyLevels[idx]
 
 		if character.Info.Level < loyalty.MinLevel ||
 			character.TradersInfo[traderID].SalesSum < loyalty.MinSalesSum ||
 			character.TradersInfo[traderID].Standing < loyalty.MinStanding {
-			traderInfo.LoyaltyLevel = idx
-			character.TradersInfo[traderID] = traderInfo
-			return
+			level = idx
+			break
+		}
+	}
+
+	if traderInfo.LoyaltyLevel != level {
+		if cache, err := GetTraderCacheByID(character.ID); err == nil {
+			cache.ResetAssort(traderID)
 		}
 	}
-	traderInfo.LoyaltyLevel = length
+	traderInfo.LoyaltyLevel = level
 	character.TradersInfo[traderID] = traderInfo
 }
 
@@ -431,6 +507,9 @@ func setTraders() {
 		for i := 0; i < count; i++ {
 			<-done
 		}
+		if trader.Base != nil && trader.Assort != nil {
+			trader.setTraderStock()
+		}
 		db.trader.Traders.Set(dir, trader)
 	}
 }
@@ -438,31 +517,7 @@ func setTraders() {
 func setTraderOfferLookup() {
 	db.trader.Traders.ForEach(func(_ string, trader *Trader) bool {
 		if trader.Assort != nil && len(trader.Assort.Items) != 0 {
-			trader.Index.Assort = &AssortIndex{
-				Items:       haxmap.New[string, int16](),            //make(map[string]int16),
-				ParentItems: haxmap.New[string, map[string]int16](), //make(map[string]map[string]int16),
-			}
-
-			for index, item := range trader.Assort.Items {
-				itemChildren := GetItemFamilyTree(trader.Assort.Items, item.ID)
-				if len(itemChildren) == 1 {
-					trader.Index.Assort.Items.Set(item.ID, int16(index))
-					continue
-				}
-
-				family := make(map[string]int16)
-				for _, child := range itemChildren {
-					for k, v := range trader.Assort.Items {
-						if child != v.ID {
-							continue
-						}
-
-						family[child] = int16(k)
-						break
-					}
-				}
-				trader.Index.Assort.ParentItems.Set(item.ID, family)
-			}
+			trader.setAssortIndex()
 		}
 
 		if trader.Suits != nil {
@@ -475,6 +530,40 @@ func setTraderOfferLookup() {
 	})
 }
 
+// setAssortIndex indexes the trader's assort items by ID, along with the family of every item with children
+func (t *Trader) setAssortIndex() {
+	t.Index.Assort = getAssortIndex(t.Assort)
+}
+
+func getAssortIndex(assort *Assort) *AssortIndex {
+	index := &AssortIndex{
+		Items:       haxmap.New[string, int16](),            //make(map[string]int16),
+		ParentItems: haxmap.New[string, map[string]int16](), //make(map[string]map[string]int16),
+	}
+
+	for idx, item := range assort.Items {
+		itemChildren := GetItemFamilyTree(assort.Items, item.ID)
+		if len(itemChildren) == 1 {
+			index.Items.Set(item.ID, int16(idx))
+			continue
+		}
+
+		family := make(map[string]int16)
+		for _, child := range itemChildren {
+			for k, v := range assort.Items {
+				if child != v.ID {
+					continue
+				}
+
+				family[child] = int16(k)
+				break
+			}
+		}
+		index.ParentItems.Set(item.ID, family)
+	}
+	return index
+}
+
 type Traders struct {
 	LogisticData *SupplyData
 	Names        *haxmap.Map[string, string]
@@ -493,6 +582,19 @@ type CurrencyCourses struct {
 	DOL int32 `json:"5696686a4bdc2da3298b456a"`
 }
 
+// GetCourse returns the price in roubles of the currency
+func (cc *CurrencyCourses) GetCourse(currency string) (int32, bool) {
+	switch currency {
+	case *GetCurrencyByName("RUB"):
+		return cc.RUB, true
+	case *GetCurrencyByName("EUR"):
+		return cc.EUR, true
+	case *GetCurrencyByName("USD"):
+		return cc.DOL, true
+	}
+	return 0, false
+}
+
 type Trader struct {
 	Index       TraderIndex                            `json:",omitempty"`
 	Base        *TraderBase                            `json:",omitempty"`
@@ -500,6 +602,12 @@ type Trader struct {
 	QuestAssort *haxmap.Map[string, map[string]string] `json:",omitempty"` //map[string]map[string]string `json:",omitempty"`
 	Suits       []TraderSuits                          `json:",omitempty"`
 	Dialogue    *haxmap.Map[string, []string]          `json:",omitempty"` //map[string][]string          `json:",omitempty"`
+	Stock       *TraderStock                           `json:"-"`
+	Fence       *FenceAssort                           `json:"-"`
+	generation  int
+	// unlocks maps the assort entries unlocked by an AssortmentUnlock quest reward to the quest
+	unlocks     map[string]string
+	unlocksOnce sync.Once
 }
 
 type TraderIndex struct {
@@ -533,6 +641,7 @@ type TraderBase struct {
 	Medic                          bool                 `json:"medic"`
 	Name                           string               `json:"name"`
 	NextResupply                   int32                `json:"nextResupply"`
+	RestockInterval                int32                `json:"restockInterval,omitempty"`
 	Nickname                       string               `json:"nickname"`
 	Repair                         TraderRepair         `json:"repair"`
 	SellCategory                   []string             `json:"sell_category"`
@@ -548,6 +657,7 @@ type TraderInsurance struct {
 	MaxStorageTime   int32    `json:"max_storage_time"`
 	MinPayment       float32  `json:"min_payment"`
 	MinReturnHour    int8     `json:"min_return_hour"`
+	ReturnChance     int8     `json:"return_chance,omitempty"`
 }
 
 type ItemsBuy struct {
@@ -615,8 +725,12 @@ type AssortItem struct {
 }
 
 type Scheme struct {
-	Tpl   string  `json:"_tpl"`
-	Count float32 `json:"count"`
+	Tpl            string  `json:"_tpl"`
+	Count          float32 `json:"count"`
+	Level          int32   `json:"level,omitempty"`
+	Side           string  `json:"side,omitempty"`
+	OnlyFunctional bool    `json:"onlyFunctional,omitempty"`
+	FoundInRaid    bool    `json:"foundInRaid,omitempty"`
 }
 
 // #endregion
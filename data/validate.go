@@ -0,0 +1,69 @@
+package data
+
+import (
+	"fmt"
+	"mtgo/tools"
+)
+
+const (
+	profileMissingAccount  string = "Profile %s has no account"
+	profileUIDMismatch     string = "Profile %s belongs to account %s"
+	profileMissingEdition  string = "Profile %s uses edition %s, which does not exist"
+	profilePlaintext       string = "Profile %s has a password that is not hashed"
+	profileItemUnknown     string = "Profile %s has item %s of unknown template %s"
+	profileItemOrphaned    string = "Profile %s has item %s whose parent %s does not exist"
+	profileDuplicateItemID string = "Profile %s has more than one item with ID %s"
+)
+
+// ValidateProfiles checks every loaded profile for problems the server can not fix on its own, returning them
+func ValidateProfiles() []error {
+	problems := make([]error, 0)
+	db.profile.ForEach(func(uid string, profile *Profile) bool {
+		problems = append(problems, validateProfile(uid, profile)...)
+		return true
+	})
+	return problems
+}
+
+func validateProfile(uid string, profile *Profile) []error {
+	problems := make([]error, 0)
+	account := profile.Account
+	if account == nil {
+		return append(problems, fmt.Errorf(profileMissingAccount, uid))
+	}
+	if account.UID != uid {
+		problems = append(problems, fmt.Errorf(profileUIDMismatch, uid, account.UID))
+	}
+	if _, err := GetEditionByName(account.Edition); err != nil {
+		problems = append(problems, fmt.Errorf(profileMissingEdition, uid, account.Edition))
+	}
+	if !tools.IsPasswordHash(account.Password) {
+		problems = append(problems, fmt.Errorf(profilePlaintext, uid))
+	}
+
+	if profile.Character == nil || account.Wipe {
+		return problems
+	}
+
+	items := profile.Character.Inventory.Items
+	ids := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		if _, ok := ids[item.ID]; ok {
+			problems = append(problems, fmt.Errorf(profileDuplicateItemID, uid, item.ID))
+		}
+		ids[item.ID] = struct{}{}
+
+		if _, err := GetItemByID(item.TPL); err != nil {
+			problems = append(problems, fmt.Errorf(profileItemUnknown, uid, item.ID, item.TPL))
+		}
+	}
+	for _, item := range items {
+		if item.ParentID == "" || item.ParentID == "hideout" {
+			continue
+		}
+		if _, ok := ids[item.ParentID]; !ok {
+			problems = append(problems, fmt.Errorf(profileItemOrphaned, uid, item.ID, item.ParentID))
+		}
+	}
+	return problems
+}
@@ -1,29 +1,79 @@
 package data
 
 import (
+	"errors"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+const (
+	outboundQueueSize = 64
+	pingInterval      = 30 * time.Second
+	pongWait          = 2 * pingInterval
+	writeWait         = 10 * time.Second
+)
+
+var (
+	errConnectionClosed = errors.New("websocket connection is closed")
+	errOutboundFull     = errors.New("websocket outbound queue is full")
+)
+
+// Connect is a player's lobby websocket, with the queue of notifications waiting to be written to it
 type Connect struct {
 	*websocket.Conn
+	sessionID string
+	outbound  chan *Notification
+	closed    chan struct{}
+	once      sync.Once
+	// mu keeps notifications from being queued while the closed connection is drained
+	mu sync.Mutex
 }
 
-func DeleteConnection(sessionID string) {
-	if _, ok := db.cache.server.websocket.GetAndDel(sessionID); ok {
-		log.Println("Connection deleted")
+// DeleteConnection removes the connection of the session, unless it was already replaced by a newer one
+func DeleteConnection(sessionID string, conn *Connect) {
+	current, ok := db.cache.server.websocket.Get(sessionID)
+	if !ok || current != conn {
 		return
 	}
-	log.Println("Connection does not exist")
+
+	db.cache.server.websocket.Del(sessionID)
+	log.Println("Connection deleted")
 }
 
-func SetConnection(sessionID string, conn *websocket.Conn) {
-	if _, ok := db.cache.server.websocket.GetOrSet(sessionID, &Connect{conn}); ok {
-		log.Println("Websocket connection has already been established for sessionID:", sessionID)
-		return
+// SetConnection stores the websocket of the session, closing the connection it replaces when the player reconnects
+func SetConnection(sessionID string, conn *websocket.Conn) *Connect {
+	connection := &Connect{
+		Conn:      conn,
+		sessionID: sessionID,
+		outbound:  make(chan *Notification, outboundQueueSize),
+		closed:    make(chan struct{}),
 	}
+
+	if old, ok := db.cache.server.websocket.Swap(sessionID, connection); ok {
+		old.Close()
+		log.Println("Websocket connection has been re-established for sessionID:", sessionID)
+		return connection
+	}
+
+	db.cache.server.websocket.Set(sessionID, connection)
 	log.Println("Websocket connection has been established for sessionID:", sessionID)
+	return connection
+}
+
+// CloseConnections closes every websocket and wakes every long-poll request waiting for notifications
+func CloseConnections() {
+	db.cache.server.websocket.ForEach(func(sessionID string, conn *Connect) bool {
+		conn.Close()
+		db.cache.server.websocket.Del(sessionID)
+		return true
+	})
+	db.cache.server.notifierWaiters.ForEach(func(sessionID string, _ chan struct{}) bool {
+		SignalNotifier(sessionID)
+		return true
+	})
 }
 
 func GetConnection(sessionID string) *Connect {
@@ -36,9 +86,147 @@ func GetConnection(sessionID string) *Connect {
 	return conn
 }
 
+// SendMessage queues the notification to be written to the websocket, failing if the connection is closed or
+// too far behind
 func (conn *Connect) SendMessage(notification *Notification) error {
-	if err := conn.WriteJSON(notification); err != nil {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	select {
+	case <-conn.closed:
+		return errConnectionClosed
+	default:
+	}
+
+	select {
+	case conn.outbound <- notification:
+		return nil
+	default:
+		return errOutboundFull
+	}
+}
+
+// Close closes the websocket and stops its pumps
+func (conn *Connect) Close() {
+	conn.once.Do(func() {
+		close(conn.closed)
+		if err := conn.Conn.Close(); err != nil {
+			log.Println(err)
+		}
+	})
+}
+
+// WritePump writes the queued notifications to the websocket and pings the client, until the connection is closed;
+// whatever was not written is then returned to the player's mailbox
+func (conn *Connect) WritePump() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	var unsent *Notification
+	defer func() { conn.drain(unsent) }()
+
+	ping := &Notification{Type: Ping, EventID: Ping}
+	for {
+		select {
+		case <-conn.closed:
+			return
+		case notification := <-conn.outbound:
+			if err := conn.write(notification); err != nil {
+				log.Println(err)
+				unsent = notification
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				log.Println(err)
+				return
+			}
+			if err := conn.write(ping); err != nil {
+				log.Println(err)
+				return
+			}
+		}
+	}
+}
+
+// drain closes the connection and returns the notification whose write failed, if any, and the ones still queued
+// to the player's mailbox
+func (conn *Connect) drain(unsent *Notification) {
+	conn.Close()
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	pending := make([]*Notification, 0, len(conn.outbound)+1)
+	if unsent != nil {
+		pending = append(pending, unsent)
+	}
+	for len(conn.outbound) != 0 {
+		pending = append(pending, <-conn.outbound)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	if err := ReturnToMailbox(conn.sessionID, pending); err != nil {
+		log.Println(err)
+		return
+	}
+	SignalNotifier(conn.sessionID)
+}
+
+func (conn *Connect) write(notification *Notification) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
 		return err
 	}
-	return nil
+	return conn.WriteJSON(notification)
+}
+
+// ReadPump reads from the websocket until the client disconnects or stops answering pings
+func (conn *Connect) ReadPump() {
+	defer conn.Close()
+
+	if err := conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+		log.Println(err)
+		return
+	}
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Println(err)
+			}
+			return
+		}
+		if err := conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+}
+
+// SignalNotifier wakes the long-poll request of the session waiting for notifications, if there is one
+func SignalNotifier(sessionID string) {
+	waiter, _ := db.cache.server.notifierWaiters.GetOrSet(sessionID, make(chan struct{}, 1))
+	select {
+	case waiter <- struct{}{}:
+	default:
+	}
+}
+
+// WaitForNotifier blocks until the session is signalled or the timeout passes, returning whether it was signalled
+func WaitForNotifier(sessionID string, timeout time.Duration) bool {
+	waiter, _ := db.cache.server.notifierWaiters.GetOrSet(sessionID, make(chan struct{}, 1))
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-waiter:
+		return true
+	case <-timer.C:
+		return false
+	}
 }
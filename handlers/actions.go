@@ -11,6 +11,18 @@ var actionHandlers = map[string]func(map[string]any, string, *data.ProfileChange
 	"QuestAccept": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
 		pkg.QuestAccept(moveAction["qid"].(string), sessionID, profileChangeEvent)
 	},
+	"QuestHandover": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.QuestHandover(moveAction, sessionID, profileChangeEvent)
+	},
+	"QuestComplete": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.QuestComplete(moveAction, sessionID, profileChangeEvent)
+	},
+	"QuestFail": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.QuestFail(moveAction, sessionID, profileChangeEvent)
+	},
+	"RepeatableQuestChange": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.RepeatableQuestChange(moveAction, sessionID, profileChangeEvent)
+	},
 	"Examine": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
 		pkg.ExamineItem(moveAction, sessionID)
 	},
@@ -65,6 +77,39 @@ var actionHandlers = map[string]func(map[string]any, string, *data.ProfileChange
 	"HideoutUpgradeComplete": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
 		pkg.HideoutUpgradeComplete(moveAction, sessionID, profileChangeEvent)
 	},
+	"HideoutSingleProductionStart": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.HideoutSingleProductionStart(moveAction, sessionID, profileChangeEvent)
+	},
+	"HideoutContinuousProductionStart": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.HideoutContinuousProductionStart(moveAction, sessionID, profileChangeEvent)
+	},
+	"HideoutScavCaseProductionStart": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.HideoutScavCaseProductionStart(moveAction, sessionID, profileChangeEvent)
+	},
+	"HideoutTakeProduction": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.HideoutTakeProduction(moveAction, sessionID, profileChangeEvent)
+	},
+	"HideoutToggleArea": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.HideoutToggleArea(moveAction, sessionID, profileChangeEvent)
+	},
+	"RagFairAddOffer": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.RagFairAddOffer(moveAction, sessionID, profileChangeEvent)
+	},
+	"RagFairRemoveOffer": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.RagFairRemoveOffer(moveAction, sessionID, profileChangeEvent)
+	},
+	"RagFairRenewOffer": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.RagFairRenewOffer(moveAction, sessionID, profileChangeEvent)
+	},
+	"Insure": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.Insure(moveAction, sessionID, profileChangeEvent)
+	},
+	"TraderRepair": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.TraderRepair(moveAction, sessionID, profileChangeEvent)
+	},
+	"Repair": func(moveAction map[string]any, sessionID string, profileChangeEvent *data.ProfileChangesEvent) {
+		pkg.Repair(moveAction, sessionID, profileChangeEvent)
+	},
 }
 
 const (
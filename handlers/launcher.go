@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"log"
+	"mtgo/pkg"
+	"net/http"
+)
+
+func LauncherConnect(w http.ResponseWriter, _ *http.Request) {
+	body := pkg.ApplyResponseBody(pkg.GetLauncherConnect())
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func LauncherRegister(w http.ResponseWriter, r *http.Request) {
+	login, err := pkg.RegisterLauncherAccount(r)
+	if err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyLauncherErrorBody(err))
+		return
+	}
+
+	body := pkg.ApplyResponseBody(login)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func LauncherLogin(w http.ResponseWriter, r *http.Request) {
+	login, err := pkg.LoginLauncherAccount(r)
+	if err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyLauncherErrorBody(err))
+		return
+	}
+
+	body := pkg.ApplyResponseBody(login)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func LauncherChangePassword(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.ChangeLauncherPassword(r); err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyLauncherErrorBody(err))
+		return
+	}
+
+	body := pkg.ApplyResponseBody(true)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func LauncherChangeEdition(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.ChangeLauncherEdition(r); err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyLauncherErrorBody(err))
+		return
+	}
+
+	body := pkg.ApplyResponseBody(true)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func LauncherWipe(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.WipeLauncherAccount(r); err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyLauncherErrorBody(err))
+		return
+	}
+
+	body := pkg.ApplyResponseBody(true)
+	pkg.SendZlibJSONReply(w, body)
+}
@@ -18,9 +18,16 @@ func LobbyPushNotifier(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if chi.URLParam(r, "id") != sessionID {
-		log.Fatalln("AAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+		http.Error(w, "notifier channel does not belong to the session", http.StatusForbidden)
+		return
 	}
-	body := pkg.ApplyResponseBody([]any{})
+
+	notifications, err := pkg.GetPendingNotifications(sessionID)
+	if err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(notifications)
 	pkg.SendZlibJSONReply(w, body)
 }
 
@@ -32,7 +39,8 @@ func LobbyGetWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if chi.URLParam(r, "id") != sessionID {
-		log.Fatalln("AAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+		http.Error(w, "notifier channel does not belong to the session", http.StatusForbidden)
+		return
 	}
 	if err := pkg.SendQueuedMessagesToPlayer(sessionID); err != nil {
 		log.Println(err)
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	probing "github.com/prometheus-community/pro-bing"
@@ -263,7 +264,7 @@ func MainProfileSelect(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	channel := pkg.GetChannel(session)
+	channel := pkg.GetChannel(session, pkg.GetSessionToken(r))
 
 	body := pkg.ApplyResponseBody(channel)
 	pkg.SendZlibJSONReply(w, body)
@@ -454,8 +455,25 @@ func MainCurrentGroup(w http.ResponseWriter, _ *http.Request) {
 	pkg.SendZlibJSONReply(w, body)
 }
 
-func MainRepeatableQuests(w http.ResponseWriter, _ *http.Request) {
-	body := pkg.ApplyResponseBody([]any{})
+func MainRepeatableQuests(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := pkg.GetSessionID(r)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	repeatableQuests := pkg.GetRepeatableQuests(character)
+	if err := character.SaveCharacter(); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(repeatableQuests)
 	pkg.SendZlibJSONReply(w, body)
 }
 
@@ -526,6 +544,7 @@ func MainLogout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	profile.SaveProfile()
+	data.DeleteSession(pkg.GetSessionToken(r))
 	//data.GetCachedResponses().SaveIfRequired()
 
 	body := pkg.ApplyResponseBody(map[string]any{"status": "ok"})
@@ -705,31 +724,22 @@ type botCondition struct {
 }
 
 func BotGenerate(w http.ResponseWriter, r *http.Request) {
-	//conditions := new(botConditions)
-	//input, err := json.MarshalNoEscape(pkg.GetParsedBody(r))
-	//if err != nil {
-	//	log.Println(err)
-	//}
-	//if err = json.UnmarshalNoEscape(input, conditions); err != nil {
-	//	log.Println(err)
-	//}
+	conditions := new(botConditions)
+	input, err := json.MarshalNoEscape(pkg.GetParsedBody(r))
+	if err != nil {
+		log.Println(err)
+	}
+	if err = json.UnmarshalNoEscape(input, conditions); err != nil {
+		log.Println(err)
+	}
 
-	//TODO: Send bots lol
-	//bot := data.GetSacrificialBot()
-
-	//bots := make([]map[string]any, 0, 50)
-	//for _, condition := range conditions.Conditions {
-	//	for i := int8(0); i < condition.Limit; i++ {
-	//		clone := bot.Clone()
-	//		clone["_id"] = tools.GenerateMongoID()
-	//		clone["aid"] = i
-	//		clone["Info"].(map[string]any)["Settings"].(map[string]any)["Role"] = condition.Role
-	//		clone["Info"].(map[string]any)["Settings"].(map[string]any)["BotDifficulty"] = condition.Difficulty
-	//		bots = append(bots, clone)
-	//	}
-	//}
+	generator := pkg.NewBotGenerator(time.Now().UnixNano())
+
+	bots := make([]data.DummyBot, 0, 50)
+	for _, condition := range conditions.Conditions {
+		bots = append(bots, generator.Generate(condition.Role, condition.Difficulty, condition.Limit)...)
+	}
 
-	bots := make([]map[string]any, 0)
 	body := pkg.ApplyResponseBody(bots)
 	pkg.SendZlibJSONReply(w, body)
 }
@@ -756,30 +766,8 @@ func OfflineMatchEnd(w http.ResponseWriter, r *http.Request) {
 	pkg.SendZlibJSONReply(w, body)
 }
 
-type raidProfileSave struct {
-	Exit                  string         `json:"exit"`
-	Profile               map[string]any `json:"profile"`
-	IsPlayerScav          bool           `json:"isPlayerScav"`
-	Health                saveHealth     `json:"health"`
-	DisableProgressionNow bool           `json:"disableProgressionNow"`
-}
-
-type saveHealth struct {
-	IsAlive     bool
-	Health      map[string]healthPart
-	Hydration   float64
-	Energy      float64
-	Temperature float64
-}
-
-type healthPart struct {
-	Maximum float64
-	Current float64
-	Effects map[string]any
-}
-
 func RaidProfileSave(w http.ResponseWriter, r *http.Request) {
-	save := new(raidProfileSave)
+	save := new(pkg.RaidProfileSave)
 	input, err := json.MarshalNoEscape(pkg.GetParsedBody(r))
 	if err != nil {
 		log.Println(err)
@@ -788,14 +776,18 @@ func RaidProfileSave(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 	}
 
-	//TODO: Raid Profile Save
-	err = tools.WriteToFile("/raidProfileSave.json", save)
+	sessionID, err := pkg.GetSessionID(r)
 	if err != nil {
+		log.Println(err)
 		return
 	}
 
-	log.Println("Raid Profile Save not implemented yet!")
-	body := pkg.ApplyResponseBody(nil)
+	event := data.GetProfileChangesEvent(sessionID)
+	if err := pkg.SaveRaidProfile(sessionID, save, event); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(event)
 	pkg.SendZlibJSONReply(w, body)
 }
 
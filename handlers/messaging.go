@@ -88,7 +88,165 @@ func MessagingMailDialogRemove(w http.ResponseWriter, r *http.Request) {
 	pkg.SendZlibJSONReply(w, body)
 }
 
-func MessagingMailDialogClear(w http.ResponseWriter, _ *http.Request) {
+func MessagingMailDialogClear(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.ClearMailDialog(r); err != nil {
+		log.Println(err)
+	}
+
 	body := pkg.ApplyResponseBody([]struct{}{})
 	pkg.SendZlibJSONReply(w, body)
 }
+
+func MessagingMailDialogRead(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.ReadMailDialogs(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody([]struct{}{})
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingMailDialogGetAllAttachments(w http.ResponseWriter, r *http.Request) {
+	attachments, err := pkg.CollectMailAttachments(r)
+	if err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(attachments)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingFriendRequestSend(w http.ResponseWriter, r *http.Request) {
+	sent, err := pkg.SendFriendRequest(r)
+	if err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(sent)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingFriendRequestAccept(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.AcceptFriendRequest(r); err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyResponseBody(false))
+		return
+	}
+
+	body := pkg.ApplyResponseBody(true)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingFriendRequestAcceptAll(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.AcceptAllFriendRequests(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(nil)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingFriendRequestDecline(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.DeclineFriendRequest(r); err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyResponseBody(false))
+		return
+	}
+
+	body := pkg.ApplyResponseBody(true)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingFriendRequestCancel(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.CancelFriendRequest(r); err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyResponseBody(false))
+		return
+	}
+
+	body := pkg.ApplyResponseBody(true)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingFriendDelete(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.RemoveFriend(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(nil)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingFriendIgnoreSet(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.IgnorePlayer(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(nil)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingFriendIgnoreRemove(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.UnignorePlayer(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(nil)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingMailMessageSend(w http.ResponseWriter, r *http.Request) {
+	messageID, err := pkg.SendPlayerMessage(r)
+	if err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(messageID)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingMailGroupCreate(w http.ResponseWriter, r *http.Request) {
+	groupID, err := pkg.CreateGroupDialog(r)
+	if err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(groupID)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingMailGroupLeave(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.LeaveGroupDialog(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(nil)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingMailGroupOwnerChange(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.ChangeGroupDialogOwner(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(nil)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingMailGroupUsersAdd(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.AddGroupDialogUser(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(nil)
+	pkg.SendZlibJSONReply(w, body)
+}
+
+func MessagingMailGroupUsersRemove(w http.ResponseWriter, r *http.Request) {
+	if err := pkg.RemoveGroupDialogUser(r); err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(nil)
+	pkg.SendZlibJSONReply(w, body)
+}
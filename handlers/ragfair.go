@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"log"
+	"mtgo/data"
 	"mtgo/pkg"
 	"mtgo/tools"
 	"net/http"
@@ -9,64 +10,71 @@ import (
 	"github.com/goccy/go-json"
 )
 
-type ESortType int8
+func RagfairFind(w http.ResponseWriter, r *http.Request) {
+	ragfair := new(data.RagfairFind)
+	input, err := json.MarshalNoEscape(pkg.GetParsedBody(r))
+	if err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyErrorBody(err))
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &ragfair); err != nil {
+		msg := tools.CheckParsingError(input, err)
+		log.Println(msg)
+		pkg.SendZlibJSONReply(w, pkg.ApplyErrorBody(msg))
+		return
+	}
 
-const (
-	ID ESortType = iota
-	Priority
-	Barter
-	Rating
-	OfferItem
-	Price
-	ExpirationDate
-)
+	flea, err := pkg.GetFlea(ragfair)
+	if err != nil {
+		log.Println(err)
+	}
+
+	body := pkg.ApplyResponseBody(flea)
 
-type ragfairOffers struct {
-	Page              int            `json:"page"`
-	Limit             int            `json:"limit"`
-	SortType          ESortType      `json:"sortType"`
-	SortDirection     int            `json:"sortDirection"`
-	Currency          int            `json:"currency"`
-	PriceFrom         int            `json:"priceFrom"`
-	PriceTo           int            `json:"priceTo"`
-	QuantityFrom      int            `json:"quantityFrom"`
-	QuantityTo        int            `json:"quantityTo"`
-	ConditionFrom     int            `json:"conditionFrom"`
-	ConditionTo       int            `json:"conditionTo"`
-	OneHourExpiration bool           `json:"oneHourExpiration"`
-	RemoveBartering   bool           `json:"removeBartering"`
-	OfferOwnerType    int            `json:"offerOwnerType"`
-	OnlyFunctional    bool           `json:"onlyFunctional"`
-	UpdateOfferCount  bool           `json:"updateOfferCount"`
-	HandbookId        string         `json:"handbookId"`
-	LinkedSearchId    string         `json:"linkedSearchId"`
-	NeededSearchId    string         `json:"neededSearchId"`
-	BuildItems        map[string]int `json:"buildItems"`
-	BuildCount        int            `json:"buildCount"`
-	Tm                int            `json:"tm"`
-	Reload            int            `json:"reload"`
+	pkg.SendZlibJSONReply(w, body)
 }
 
-func RagfairFind(w http.ResponseWriter, r *http.Request) {
-	ragfair := new(ragfairOffers)
-	if input, err := json.MarshalNoEscape(pkg.GetParsedBody(r)); err != nil {
-		log.Fatalln(err)
-	} else if err := json.UnmarshalNoEscape(input, &ragfair); err != nil {
-		msg := tools.CheckParsingError(input, err)
-		log.Fatalln(msg)
+type ragfairOfferFindByID struct {
+	ID int16 `json:"id"`
+}
+
+func RagfairOfferFindByID(w http.ResponseWriter, r *http.Request) {
+	find := new(ragfairOfferFindByID)
+	input, _ := json.MarshalNoEscape(pkg.GetParsedBody(r))
+	if err := json.UnmarshalNoEscape(input, &find); err != nil {
+		log.Println(err)
+		return
 	}
 
-	flea, err := pkg.GetFlea(ragfair.HandbookId)
+	offer, err := pkg.GetOfferByIntID(find.ID)
 	if err != nil {
-		log.Fatalln(err)
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyResponseBody(nil))
+		return
 	}
 
-	if ragfair.UpdateOfferCount {
-		flea.OffersCount += int16(len(flea.Offers))
+	pkg.SendZlibJSONReply(w, pkg.ApplyResponseBody(offer))
+}
+
+type ragfairItemMarketPrice struct {
+	TemplateID string `json:"templateId"`
+}
+
+func RagfairItemMarketPrice(w http.ResponseWriter, r *http.Request) {
+	marketPrice := new(ragfairItemMarketPrice)
+	input, _ := json.MarshalNoEscape(pkg.GetParsedBody(r))
+	if err := json.UnmarshalNoEscape(input, &marketPrice); err != nil {
+		log.Println(err)
+		return
 	}
-	flea.SelectedCategory = ragfair.HandbookId
 
-	body := pkg.ApplyResponseBody(flea)
+	price, err := pkg.GetItemMarketPrice(marketPrice.TemplateID)
+	if err != nil {
+		log.Println(err)
+		pkg.SendZlibJSONReply(w, pkg.ApplyResponseBody(nil))
+		return
+	}
 
-	pkg.SendZlibJSONReply(w, body)
+	pkg.SendZlibJSONReply(w, pkg.ApplyResponseBody(price))
 }
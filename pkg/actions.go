@@ -30,81 +30,60 @@ func QuestAccept(qid string, sessionID string, event *data.ProfileChangesEvent)
 	length := len(cachedQuests.Index)
 	time := int(tools.GetCurrentTimeInSeconds())
 
-	query := data.GetQuestFromQueryByID(qid)
+	query := getQuestQuery(character, qid)
+	if query == nil {
+		log.Printf(questNotFound, qid)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
 
 	quest, ok := cachedQuests.Index[qid]
 	if ok { // if exists, update cache and copy to quest on character
-		cachedQuest := character.Quests[quest]
+		cachedQuest := &character.Quests[quest]
+		if cachedQuest.Status == "AvailableAfter" && time < cachedQuest.AvailableAfter {
+			log.Printf(questNotAvailableYet, qid, cachedQuest.AvailableAfter-time)
+			return
+		}
 
-		cachedQuest.Status = "Started"
+		setQuestStatus(character, cachedQuest, data.Started)
 		cachedQuest.StartTime = time
-		cachedQuest.StatusTimers[cachedQuest.Status] = time
 	} else {
 		quest := &data.CharacterQuest{
 			QID:          qid,
 			StartTime:    time,
 			Status:       "Started",
-			StatusTimers: map[string]int{},
+			StatusTimers: map[string]int{"Started": time},
 		}
 
-		if query.Conditions.AvailableForStart != nil && query.Conditions.AvailableForStart.Quest != nil {
-			startCondition := query.Conditions.AvailableForStart.Quest
-			for _, questCondition := range startCondition {
-				if questCondition.AvailableAfter > 0 {
-					quest.StartTime = 0
-					quest.Status = "AvailableAfter"
-					quest.AvailableAfter = time + questCondition.AvailableAfter
-				}
-			}
+		// a quest unlocked on a timer waits for it before it can be accepted
+		if delay := getQuestStartDelay(query); delay > 0 {
+			quest.StartTime = 0
+			quest.Status = "AvailableAfter"
+			quest.AvailableAfter = time + delay
+			quest.StatusTimers = map[string]int{"AvailableAfter": time}
 		}
 
 		cachedQuests.Index[qid] = int8(length)
 		character.Quests = append(character.Quests, *quest)
+		if quest.Status == "AvailableAfter" {
+			changes.QuestsStatus = append(changes.QuestsStatus, *quest)
+			event.ProfileChanges.Set(character.ID, changes)
+			return
+		}
+		data.ResetTraderAssorts(character.ID)
 	}
 
-	if query.Rewards.Start != nil {
-		log.Println("There are rewards heeyrrrr!")
-		//log.Println(event.ProfileChanges[character.ID].ID)
+	items := ApplyQuestRewardsToCharacter(character, query.Rewards.Start, changes)
 
-		// TODO: Apply then Get Quest rewards and then route messages from there
-		// Character.ApplyQuestRewardsToCharacter()  applies the given reward
-		// Quests.GetQuestReward() returns the given reward
-		// CreateNPCMessageWithReward()
-	}
-
-	dialogue, err := data.GetDialogueByID(character.ID)
-	if err != nil {
+	_, message := data.CreateQuestDialogue(character.ID, "QuestStart", query.Trader, query.Dialogue.Description)
+	message.AttachItems(items, data.GetMessageRedeemTime())
+	if err := SendMailToPlayer(character.ID, query.Trader, "Trader", message); err != nil {
 		log.Println(err)
-		return
-	}
-
-	dialog, message := data.CreateQuestDialogue(character.ID, "QuestStart", query.Trader, query.Dialogue.Description)
-	dialog.New++
-	dialog.Messages = append(dialog.Messages, *message)
-
-	(*dialogue)[query.Trader] = dialog
-
-	notification := data.CreateNotification(message)
-
-	connection := data.GetConnection(character.ID)
-	if connection == nil {
-		log.Println("Can't send message to character because connection is nil, storing...")
-		storage, err := data.GetStorageByID(character.ID)
-		if err != nil {
-			log.Println(err)
-			return
-		}
-
-		storage.Mailbox = append(storage.Mailbox, notification)
-		err = storage.SaveStorage(character.ID)
-		if err != nil {
-			log.Println(err)
-			return
-		}
-	} else {
-		if err := connection.SendMessage(notification); err != nil {
-			return
-		}
 	}
 
 	//TODO: Get new player quests from data now that we've accepted one
@@ -114,23 +93,16 @@ func QuestAccept(qid string, sessionID string, event *data.ProfileChangesEvent)
 		return
 	}
 
-	if changes, ok := event.ProfileChanges.Get(character.ID); ok {
-		changes.Quests = quests
-		event.ProfileChanges.Set(character.ID, changes)
-	}
-	if err = dialogue.SaveDialogue(character.ID); err != nil {
-		return
-	}
+	changes.Quests = quests
+	changes.QuestsStatus = append(changes.QuestsStatus, character.Quests[cachedQuests.Index[qid]])
+	event.ProfileChanges.Set(character.ID, changes)
+
 	if err = character.SaveCharacter(); err != nil {
 		return
 	}
 
 }
 
-func ApplyQuestRewardsToCharacter(rewards *data.QuestRewards) {
-	log.Println()
-}
-
 type examine struct {
 	Action    string     `json:"Action"`
 	Item      string     `json:"item"`
@@ -868,21 +840,47 @@ func TradingConfirm(action map[string]any, sessionID string, event *data.Profile
 }
 
 func buyFromTrader(tradeConfirm *buyFrom, character *data.Character[map[string]data.PlayerTradersInfo], event *data.ProfileChangesEvent) {
-	invCache, err := data.GetInventoryCacheByID(character.ID)
+	trader, err := data.GetTraderByUID(tradeConfirm.TID)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	trader, err := data.GetTraderByUID(tradeConfirm.TID)
+	assortItem := trader.GetAssortItemByID(tradeConfirm.ItemID)
+	if assortItem == nil {
+		log.Println("Item of", tradeConfirm.ItemID, "does not exist in trader assort, killing!")
+		return
+	}
+
+	storage, err := data.GetStorageByID(character.ID)
 	if err != nil {
 		log.Println(err)
 		return
 	}
+	if err := trader.CanBuyAssortItem(storage, tradeConfirm.ItemID, tradeConfirm.Count); err != nil {
+		log.Println(err)
+		return
+	}
 
-	assortItem := trader.GetAssortItemByID(tradeConfirm.ItemID)
-	if assortItem == nil {
-		log.Println("Item of", tradeConfirm.ItemID, "does not exist in trader assort, killing!")
+	assort, err := trader.GetStrippedAssort(character)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	schemes, ok := assort.BarterScheme.Get(tradeConfirm.ItemID)
+	if !ok || int(tradeConfirm.SchemeID) >= len(schemes) || tradeConfirm.SchemeID < 0 {
+		log.Println("Barter scheme", tradeConfirm.SchemeID, "of", tradeConfirm.ItemID, "is not available, killing!")
+		return
+	}
+
+	payment, err := getBarterPayment(character, schemes[tradeConfirm.SchemeID], tradeConfirm.SchemeItems, tradeConfirm.Count)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	value, err := payment.getValue(trader)
+	if err != nil {
+		log.Println(err)
 		return
 	}
 
@@ -898,165 +896,59 @@ func buyFromTrader(tradeConfirm *buyFrom, character *data.Character[map[string]d
 		return
 	}
 
-	stackMaxSize := item.GetStackMaxSize()
-	stackSlice := GetCorrectAmountOfItemsPurchased(tradeConfirm.Count, stackMaxSize)
 	// Basically gets the correct amount of items to be created, based on StackSize
+	stackMaxSize := item.GetStackMaxSize()
+	stackSlice := GetCorrectAmountOfItemsPurchased(tradeConfirm.Count, max(stackMaxSize, 1))
 
-	// Create copy-of Character.Inventory.Items for modification in the case of any failures to assign later
-	copyOfItems := make([]data.InventoryItem, 0, len(character.Inventory.Items)+(len(inventoryItems)*len(stackSlice)))
-	copyOfItems = append(copyOfItems, character.Inventory.Items...)
-	// Create copy-of invCache.Stash.Container for modification in the case of any failures to assign later
-	copyOfMap := invCache.Stash.Container
-
-	toAdd := make([]data.InventoryItem, 0, len(stackSlice))
-
-	height, width := data.MeasurePurchaseForInventoryMapping(inventoryItems)
-	var copyOfInventoryItems []data.InventoryItem
-
+	toAdd := make([]data.InventoryItem, 0, len(inventoryItems)*len(stackSlice))
 	for _, stack := range stackSlice {
-		copyOfInventoryItems = data.AssignNewIDs(inventoryItems)
-
-		index := len(copyOfInventoryItems) - 1
-		mainItem := copyOfInventoryItems[index]
-
-		validLocation := invCache.GetValidLocationForItem(height, width)
-		if validLocation == nil {
-			log.Println("Item", tradeConfirm.ItemID, "was not purchased because we could not find a position in your inventory!!")
-			invCache.Stash.Container = copyOfMap //if failure, assign old map
-			return
-		}
+		copyOfInventoryItems := data.AssignNewIDs(inventoryItems)
 
+		mainItem := &copyOfInventoryItems[len(copyOfInventoryItems)-1]
 		if stackMaxSize > 1 {
+			if mainItem.UPD == nil {
+				mainItem.UPD = new(data.ItemUpdate)
+			}
 			mainItem.UPD.StackObjectsCount = stack
 		}
-
-		mainItem.Location = &data.InventoryItemLocation{
-			IsSearched: true,
-			R:          float64(0),
-			X:          float64(validLocation.X),
-			Y:          float64(validLocation.Y),
-		}
-
-		itemFlatMap := invCache.CreateFlatMapLookup(height, width, &mainItem)
-		itemFlatMap.Coordinates = validLocation.MapInfo
-		invCache.AddItemToContainer(mainItem.ID, itemFlatMap)
-
-		copyOfInventoryItems[index] = mainItem
 		toAdd = append(toAdd, copyOfInventoryItems...)
 	}
-	copyOfInventoryItems = nil
+
 	changes, ok := event.ProfileChanges.Get(character.ID)
 	if !ok {
 		log.Fatal("profile changes event does not exist")
 	}
 
-	toDelete := make(map[string]int16)
-	traderRelations := character.TradersInfo[tradeConfirm.TID]
-	for _, scheme := range tradeConfirm.SchemeItems {
-		index := invCache.GetIndexOfItemByID(scheme.ID)
-		if index == nil {
-			log.Println("Index of", scheme.ID, "does not exist in cache, killing!")
-			return
-		}
-
-		itemInInventory := copyOfItems[*index]
-
-		currency := *data.GetCurrencyByName(trader.Base.Currency)
-		if data.IsCurrencyByID(itemInInventory.TPL) {
-			traderRelations.SalesSum += float32(scheme.Count)
-		} else {
-			priceOfItem, err := data.GetPriceByID(itemInInventory.TPL)
-			if err != nil {
-				log.Println(err)
-				return
-			}
-
-			if trader.Base.Currency != "RUB" {
-				if conversion, err := data.ConvertFromRouble(priceOfItem, currency); err == nil {
-					traderRelations.SalesSum += float32(conversion)
-				} else {
-					log.Println(err)
-					return
-				}
-			} else {
-				traderRelations.SalesSum += float32(priceOfItem)
-			}
-		}
-
-		if itemInInventory.UPD != nil && itemInInventory.UPD.StackObjectsCount != 0 {
-			var remainingBalance = scheme.Count
-
-			if itemInInventory.UPD.StackObjectsCount > remainingBalance {
-				itemInInventory.UPD.StackObjectsCount -= remainingBalance
-
-				changes.Items.Change = append(changes.Items.Change, itemInInventory)
-			} else if itemInInventory.UPD.StackObjectsCount == remainingBalance {
-				toDelete[itemInInventory.ID] = *index
-			} else {
-				remainingBalance -= itemInInventory.UPD.StackObjectsCount
-
-				toDelete[itemInInventory.ID] = *index
-
-				//TODO: Consider creating a look-up cache for mergable Inventory.Items
-
-				var toChange []data.InventoryItem
-				for idx, item := range copyOfItems {
-					if _, ok := toDelete[item.ID]; ok || item.TPL != currency {
-						continue
-					}
-
-					change := item.UPD.StackObjectsCount - remainingBalance
-					if change > 0 {
-						remainingBalance -= item.UPD.StackObjectsCount
-						toDelete[item.ID] = int16(idx)
-						continue
-					} else if change == 0 {
-						remainingBalance -= item.UPD.StackObjectsCount
-						toDelete[item.ID] = int16(idx)
-						break
-					}
-
-					item.UPD.StackObjectsCount = change
-					toChange = append(toChange, item)
-					break
-				}
-				if remainingBalance > 0 {
-					log.Println("Insufficient funds to purchase item, returning")
-					invCache.Stash.Container = copyOfMap
-					return
-				}
-
-				changes.Items.Change = append(changes.Items.Change, toChange...)
-			}
-		} else {
-			toDelete[itemInInventory.ID] = *index
-		}
+	// Nothing of the purchase is kept unless the payment, placing the items and the trader's stock all succeed
+	restore, err := snapshotInventory(character, changes)
+	if err != nil {
+		log.Println(err)
+		return
 	}
-
-	// Add all items from toAdd to Copy of Inventory.Items
-	if len(toAdd) == 0 {
-		log.Println("balls")
+	if err := payment.take(character, changes); err != nil {
+		log.Println(err)
+		restore()
 		return
 	}
-
-	copyOfItems = append(copyOfItems, toAdd...)
-	changes.Items.New = append(changes.Items.New, toAdd...)
-	character.Inventory.Items = copyOfItems
-
-	if len(toDelete) != 0 {
-		indices := make([]int16, 0, len(toDelete))
-		for id, idx := range toDelete {
-			invCache.ClearItemFromContainer(id)
-			indices = append(indices, idx)
-
-			changes.Items.Del = append(changes.Items.Del, data.InventoryItem{ID: id})
-		}
-		character.Inventory.RemoveItemsFromInventoryByIndices(indices)
+	if err := AddItemsToInventory(character, toAdd, changes); err != nil {
+		log.Println(err)
+		restore()
+		return
+	}
+	if err := trader.BuyAssortItem(storage, tradeConfirm.ItemID, tradeConfirm.Count); err != nil {
+		log.Println(err)
+		restore()
+		return
+	}
+	if err := storage.SaveStorage(character.ID); err != nil {
+		log.Println(err)
 	}
-	invCache.SetInventoryIndex(&character.Inventory)
 
-	changes.TraderRelations[tradeConfirm.TID] = traderRelations
+	traderRelations := character.TradersInfo[tradeConfirm.TID]
+	traderRelations.SalesSum += value
 	character.TradersInfo[tradeConfirm.TID] = traderRelations
+	trader.SetTraderLoyaltyLevel(character)
+	changes.TraderRelations[tradeConfirm.TID] = character.TradersInfo[tradeConfirm.TID]
 
 	event.ProfileChanges.Set(character.ID, changes)
 	log.Println(len(stackSlice), "of Item", tradeConfirm.ItemID, "purchased!")
@@ -1069,13 +961,12 @@ func sellToTrader(tradeConfirm *sellTo, character *data.Character[map[string]dat
 		return
 	}
 
-	var saleCurrency string
-	if trader, err := data.GetTraderByUID(tradeConfirm.TID); err != nil {
+	trader, err := data.GetTraderByUID(tradeConfirm.TID)
+	if err != nil {
 		log.Println(err)
 		return
-	} else {
-		saleCurrency = *data.GetCurrencyByName(trader.Base.Currency)
 	}
+	saleCurrency := *data.GetCurrencyByName(trader.Base.Currency)
 
 	var stackMaxSize int32
 	if item, err := data.GetItemByID(saleCurrency); err != nil {
@@ -1091,7 +982,55 @@ func sellToTrader(tradeConfirm *sellTo, character *data.Character[map[string]dat
 		return
 	}
 
-	remainingBalance := tradeConfirm.Price
+	var price int32
+	var roubles float64
+	toDelete := make(map[string]int16)
+	for _, sold := range tradeConfirm.Items {
+		index := cache.GetIndexOfItemByID(sold.ID)
+		if index == nil {
+			log.Println("Index of", sold.ID, "does not exist in cache, killing!")
+			return
+		}
+
+		// everything attached to or inside the item is sold with it, so the trader has to buy all of it
+		family := make([]data.InventoryItem, 0)
+		for _, id := range data.GetInventoryItemFamilyTreeIDs(character.Inventory.Items, sold.ID) {
+			idx := cache.GetIndexOfItemByID(id)
+			if idx == nil {
+				log.Println("Index of", id, "does not exist in cache, killing!")
+				return
+			}
+			if _, ok := toDelete[id]; ok {
+				continue
+			}
+
+			item, err := data.GetItemByID(character.Inventory.Items[*idx].TPL)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			if err := trader.IsItemBought(item); err != nil {
+				log.Println(err)
+				return
+			}
+
+			toDelete[id] = *idx
+			family = append(family, character.Inventory.Items[*idx])
+		}
+
+		itemPrice, itemRoubles, err := trader.GetSellPrice(character, family)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		price += itemPrice
+		roubles += itemRoubles
+	}
+	if price != tradeConfirm.Price {
+		log.Println("Sale to trader", tradeConfirm.TID, "priced at", price, "instead of the requested", tradeConfirm.Price)
+	}
+
+	remainingBalance := price
 
 	toChange := make([]data.InventoryItem, 0)
 	copyOfItems := make([]data.InventoryItem, 0, len(character.Inventory.Items))
@@ -1167,12 +1106,6 @@ func sellToTrader(tradeConfirm *sellTo, character *data.Character[map[string]dat
 	changes.Items.Change = append(changes.Items.Change, toChange...)
 	character.Inventory.Items = copyOfItems
 
-	toDelete := make(map[string]int16)
-	for _, item := range tradeConfirm.Items {
-		index := *cache.GetIndexOfItemByID(item.ID)
-		toDelete[item.ID] = index
-	}
-
 	if len(toDelete) != 0 {
 		indices := make([]int16, 0, len(toDelete))
 		for id, idx := range toDelete {
@@ -1187,11 +1120,8 @@ func sellToTrader(tradeConfirm *sellTo, character *data.Character[map[string]dat
 	}
 	invCache.SetInventoryIndex(&character.Inventory)
 
-	traderRelations := character.TradersInfo[tradeConfirm.TID]
-	traderRelations.SalesSum += float32(tradeConfirm.Price)
-
-	changes.TraderRelations[tradeConfirm.TID] = traderRelations
-	character.TradersInfo[tradeConfirm.TID] = traderRelations
+	trader.AddSale(character, price, roubles)
+	changes.TraderRelations[tradeConfirm.TID] = character.TradersInfo[tradeConfirm.TID]
 
 	event.ProfileChanges.Set(character.ID, changes)
 }
@@ -1290,33 +1220,6 @@ func CustomizationWear(action map[string]any, sessionID string) {
 	}
 }
 
-type hideoutUpgrade struct {
-	Action    string
-	AreaType  int8            `json:"areaType"`
-	Items     []tradingScheme `json:"items"`
-	TimeStamp float64         `json:"timeStamp"`
-}
-
-func HideoutUpgrade(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
-	log.Println("HideoutUpgrade")
-	upgrade := new(hideoutUpgrade)
-	input, err := json.MarshalNoEscape(action)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
-	// character:= data.GetCharacterByID(sessionID)
-	if err := json.UnmarshalNoEscape(input, &upgrade); err != nil {
-		log.Println(err)
-		return
-	}
-
-	hideoutArea := data.GetHideoutAreaByAreaType(upgrade.AreaType)
-
-	log.Println(hideoutArea)
-}
-
 type bindItem struct {
 	Action string
 	Item   string `json:"item"`
@@ -1442,30 +1345,3 @@ func ToggleItem(action map[string]any, sessionID string) {
 		character.Inventory.Items[index].UPD.Togglable.On = toggle.Value
 	}
 }
-
-type hideoutUpgradeComplete struct {
-	Action    string
-	AreaType  uint8   `json:"areaType"`
-	TimeStamp float64 `json:"timeStamp"`
-}
-
-func HideoutUpgradeComplete(action map[string]any, _ string, _ *data.ProfileChangesEvent) {
-	log.Println("HideoutUpgradeComplete")
-	upgradeComplete := new(hideoutUpgradeComplete)
-	input, err := json.MarshalNoEscape(action)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	if err := json.UnmarshalNoEscape(input, &upgradeComplete); err != nil {
-		log.Println(err)
-		return
-	}
-
-	// character := data.GetCharacterByID(sessionID)
-	log.Println(upgradeComplete)
-}
-
-func Insure(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
-
-}
@@ -0,0 +1,612 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"log"
+	"math/rand"
+	"mtgo/data"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultInventory string = "55d7217a4bdc2d86028b456d"
+	stashTPL         string = "566abbc34bdc2d92178b4576"
+	questRaidTPL     string = "5963866286f7747bf429b572"
+	questStashTPL    string = "5963866b86f7747bfa1c4462"
+	sortingTableTPL  string = "602543c13fee350cd564d032"
+	defaultPockets   string = "557ffd194bdc2d28148b457f"
+
+	magazineSlot   string = "mod_magazine"
+	cartridgesSlot string = "cartridges"
+
+	maxModDepth    int = 8
+	optionalChance int = 50
+	lootAttempts   int = 10
+	alwaysChance   int = 100
+)
+
+// maxLootStack caps the stacks of ammo and loot put in a bot's containers
+const maxLootStack int32 = 60
+
+// BotGenerator builds bot profiles from the bot database; the same seed always produces the same bots
+type BotGenerator struct {
+	rand *rand.Rand
+	aid  int
+}
+
+// botInventory keeps track of the inventory being generated and which grid cells are already taken
+type botInventory struct {
+	inventory *data.Inventory
+	grids     map[string]map[string][][]bool
+}
+
+// NewBotGenerator returns a BotGenerator seeded with seed
+func NewBotGenerator(seed int64) *BotGenerator {
+	return &BotGenerator{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Generate creates limit bots of the given role and difficulty
+func (bg *BotGenerator) Generate(role string, difficulty string, limit int8) []data.DummyBot {
+	output := make([]data.DummyBot, 0, limit)
+
+	botType, err := data.GetBotByName(strings.ToLower(role))
+	if err != nil {
+		log.Println(err)
+		return output
+	}
+
+	template := data.GetSacrificialBot()
+	for i := int8(0); i < limit; i++ {
+		bot := template.Clone()
+		bg.setBotInfo(bot, role, difficulty)
+		bg.setBotHealth(bot, botType)
+		bot["Inventory"] = bg.generateInventory(botType.GetBotLoadout(), botType.GetBotChances())
+		output = append(output, bot)
+	}
+	return output
+}
+
+func (bg *BotGenerator) setBotInfo(bot data.DummyBot, role string, difficulty string) {
+	bg.aid++
+	bot["_id"] = bg.newID()
+	bot["aid"] = bg.aid
+
+	info, ok := bot["Info"].(map[string]any)
+	if !ok {
+		info = make(map[string]any)
+		bot["Info"] = info
+	}
+
+	if name := bg.pick(data.GetBotNamesByRole(role)); name != "" {
+		info["Nickname"] = name
+		info["LowerNickname"] = strings.ToLower(name)
+	}
+
+	switch strings.ToLower(role) {
+	case "usec":
+		info["Side"] = "Usec"
+	case "bear":
+		info["Side"] = "Bear"
+	default:
+		info["Side"] = "Savage"
+	}
+
+	settings, ok := info["Settings"].(map[string]any)
+	if !ok {
+		settings = make(map[string]any)
+		info["Settings"] = settings
+	}
+	settings["Role"] = role
+	settings["BotDifficulty"] = difficulty
+
+	appearance := data.GetBotAppearanceByRole(role)
+	if appearance == nil {
+		return
+	}
+
+	if voice := bg.pick(appearance.Voice); voice != "" {
+		info["Voice"] = voice
+	}
+
+	customization, ok := bot["Customization"].(map[string]any)
+	if !ok {
+		customization = make(map[string]any)
+		bot["Customization"] = customization
+	}
+	parts := []struct {
+		key     string
+		options []string
+	}{
+		{"Head", appearance.Head},
+		{"Body", appearance.Body},
+		{"Feet", appearance.Feet},
+		{"Hands", appearance.Hands},
+	}
+	for _, part := range parts {
+		if value := bg.pick(part.options); value != "" {
+			customization[part.key] = value
+		}
+	}
+}
+
+func (bg *BotGenerator) setBotHealth(bot data.DummyBot, botType *data.BotType) {
+	if len(botType.Health) == 0 {
+		return
+	}
+
+	health, ok := bot["Health"].(map[string]any)
+	if !ok {
+		health = make(map[string]any)
+		bot["Health"] = health
+	}
+	for key, value := range botType.Health {
+		health[key] = value
+	}
+}
+
+// generateInventory equips the bot from the loadout, each equipment and mod slot being filled by its chance
+func (bg *BotGenerator) generateInventory(loadout *data.BotLoadout, chances *data.BotChances) *data.Inventory {
+	inventory := &data.Inventory{
+		Items:              make([]data.InventoryItem, 0),
+		Equipment:          bg.newID(),
+		Stash:              bg.newID(),
+		QuestRaidItems:     bg.newID(),
+		QuestStashItems:    bg.newID(),
+		SortingTable:       bg.newID(),
+		FastPanel:          make(map[string]string),
+		HideoutAreaStashes: make(map[string]string),
+	}
+	inventory.Items = append(inventory.Items,
+		data.InventoryItem{ID: inventory.Equipment, TPL: defaultInventory},
+		data.InventoryItem{ID: inventory.Stash, TPL: stashTPL},
+		data.InventoryItem{ID: inventory.QuestRaidItems, TPL: questRaidTPL},
+		data.InventoryItem{ID: inventory.QuestStashItems, TPL: questStashTPL},
+		data.InventoryItem{ID: inventory.SortingTable, TPL: sortingTableTPL},
+	)
+
+	bi := &botInventory{
+		inventory: inventory,
+		grids:     make(map[string]map[string][][]bool),
+	}
+
+	if loadout == nil {
+		loadout = new(data.BotLoadout)
+	}
+
+	pockets := loadout.Pocket
+	if len(pockets) == 0 {
+		pockets = []string{defaultPockets}
+	}
+
+	equipment := []struct {
+		slot    string
+		options []string
+	}{
+		{"Earpiece", loadout.Earpiece},
+		{"Headwear", loadout.Headerwear},
+		{"FaceCover", loadout.Facecover},
+		{"ArmorVest", loadout.BodyArmor},
+		{"TacticalVest", loadout.Vest},
+		{"Backpack", loadout.Backpack},
+		{"FirstPrimaryWeapon", loadout.PrimaryWeapon},
+		{"SecondPrimaryWeapon", loadout.SecondaryWeapon},
+		{"Holster", loadout.Holster},
+		{"Scabbard", loadout.Melee},
+		{"Pockets", pockets},
+	}
+
+	containers := make([]string, 0, 3)
+	ammunition := make([]string, 0, 3)
+	for _, entry := range equipment {
+		if !bg.rollChance(chances.Equipment, entry.slot, alwaysChance) {
+			continue
+		}
+		tpl := bg.pick(entry.options)
+		if tpl == "" {
+			continue
+		}
+
+		item := bg.addItem(bi, tpl, inventory.Equipment, entry.slot, nil)
+		if item == nil {
+			continue
+		}
+
+		switch entry.slot {
+		case "FirstPrimaryWeapon", "SecondPrimaryWeapon", "Holster":
+			if ammo := bg.generateWeapon(bi, item, chances.WeaponMods); ammo != "" {
+				ammunition = append(ammunition, ammo)
+			}
+		case "TacticalVest", "Pockets", "Backpack":
+			containers = append(containers, item.ID)
+			bg.fillSlots(bi, item, 0, nil, chances.EquipmentMods)
+		default:
+			bg.fillSlots(bi, item, 0, nil, chances.EquipmentMods)
+		}
+	}
+
+	for _, ammo := range ammunition {
+		for i := 0; i < 2; i++ {
+			bg.addToContainers(bi, containers, ammo)
+		}
+	}
+
+	if len(loadout.Loot) != 0 {
+		attempts := bg.rand.Intn(lootAttempts + 1)
+		for i := 0; i < attempts; i++ {
+			bg.addToContainers(bi, containers, bg.pick(loadout.Loot))
+		}
+	}
+
+	return inventory
+}
+
+// generateWeapon fills the weapon with compatible mods and ammunition, returning the chosen ammo
+func (bg *BotGenerator) generateWeapon(bi *botInventory, weapon *data.InventoryItem, chances map[string]int) string {
+	weaponInDatabase, err := data.GetItemByID(weapon.TPL)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+
+	ammo := bg.pickWeaponAmmo(weaponInDatabase)
+	bg.fillSlots(bi, weapon, 0, map[string]struct{}{weapon.TPL: {}}, chances)
+
+	if ammo == "" {
+		return ""
+	}
+
+	chambers := weaponInDatabase.GetItemChambers()
+	for _, name := range sortedKeys(chambers) {
+		bg.addStack(bi, ammo, weapon.ID, name, nil, 1)
+	}
+
+	for _, item := range bi.inventory.Items {
+		if item.ParentID != weapon.ID || item.SlotID != magazineSlot {
+			continue
+		}
+		bg.fillMagazine(bi, item.ID, item.TPL, ammo)
+		break
+	}
+	return ammo
+}
+
+func (bg *BotGenerator) pickWeaponAmmo(weapon *data.DatabaseItem) string {
+	chambers := weapon.GetItemChambers()
+	for _, name := range sortedKeys(chambers) {
+		if ammo := bg.pick(bg.getSlotCandidates(chambers[name])); ammo != "" {
+			return ammo
+		}
+	}
+
+	if ammo, ok := weapon.Props["defAmmo"].(string); ok {
+		return ammo
+	}
+	return ""
+}
+
+// fillMagazine fills the magazine with as much of ammo as it fits, picking another compatible
+// cartridge if the magazine does not accept it
+func (bg *BotGenerator) fillMagazine(bi *botInventory, magazineID string, magazineTPL string, ammo string) {
+	magazine, err := data.GetItemByID(magazineTPL)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	cartridges := magazine.GetItemCartridges()
+	if cartridges == nil || cartridges.MaxCount == 0 {
+		return
+	}
+
+	if candidates := data.GetBotFilterItems(cartridges.GetFilter()); !slices.Contains(candidates, ammo) {
+		ammo = bg.pick(candidates)
+	}
+	if ammo == "" {
+		return
+	}
+
+	ammoInDatabase, err := data.GetItemByID(ammo)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	stackMax := ammoInDatabase.GetStackMaxSize()
+	if stackMax <= 0 {
+		stackMax = 1
+	}
+
+	location := 0
+	for remaining := int32(cartridges.MaxCount); remaining > 0; location++ {
+		count := min(remaining, stackMax)
+		bg.addStack(bi, ammo, magazineID, cartridgesSlot, location, count)
+		remaining -= count
+	}
+}
+
+// fillSlots recursively fills the slots of item with compatible mods, optional slots by their chance; tpls holds
+// every template already on the item so conflicting mods are skipped
+func (bg *BotGenerator) fillSlots(bi *botInventory, item *data.InventoryItem, depth int, tpls map[string]struct{}, chances map[string]int) {
+	if depth >= maxModDepth {
+		return
+	}
+
+	itemInDatabase, err := data.GetItemByID(item.TPL)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if slots, ok := itemInDatabase.Props["Slots"].([]any); !ok || len(slots) == 0 {
+		return
+	}
+
+	if tpls == nil {
+		tpls = map[string]struct{}{item.TPL: {}}
+	}
+
+	slots := itemInDatabase.GetItemSlots()
+	for _, name := range sortedKeys(slots) {
+		slot := slots[name]
+		if !slot.Required && name != magazineSlot && !bg.rollChance(chances, name, optionalChance) {
+			continue
+		}
+
+		candidates := bg.getSlotCandidates(slot)
+		candidates = slices.DeleteFunc(slices.Clone(candidates), func(tpl string) bool {
+			return bg.isConflicting(tpl, tpls)
+		})
+
+		tpl := bg.pick(candidates)
+		if tpl == "" {
+			continue
+		}
+
+		mod := bg.addItem(bi, tpl, item.ID, name, nil)
+		if mod == nil {
+			continue
+		}
+		tpls[tpl] = struct{}{}
+		bg.fillSlots(bi, mod, depth+1, tpls, chances)
+	}
+}
+
+func (bg *BotGenerator) isConflicting(tpl string, tpls map[string]struct{}) bool {
+	item, err := data.GetItemByID(tpl)
+	if err != nil {
+		return true
+	}
+
+	conflicts, _ := item.Props["ConflictingItems"].([]any)
+	for _, conflict := range conflicts {
+		if id, ok := conflict.(string); ok {
+			if _, ok := tpls[id]; ok {
+				return true
+			}
+		}
+	}
+
+	for existing := range tpls {
+		other, err := data.GetItemByID(existing)
+		if err != nil {
+			continue
+		}
+		conflicts, _ := other.Props["ConflictingItems"].([]any)
+		for _, conflict := range conflicts {
+			if conflict == tpl {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addToContainers places a single stack of tpl in the first container grid that accepts and fits it
+func (bg *BotGenerator) addToContainers(bi *botInventory, containers []string, tpl string) bool {
+	if tpl == "" {
+		return false
+	}
+
+	item, err := data.GetItemByID(tpl)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	height, width := item.GetItemSize()
+	if height <= 0 || width <= 0 {
+		return false
+	}
+
+	for _, containerID := range containers {
+		var containerTPL string
+		for _, inventoryItem := range bi.inventory.Items {
+			if inventoryItem.ID == containerID {
+				containerTPL = inventoryItem.TPL
+				break
+			}
+		}
+
+		container, err := data.GetItemByID(containerTPL)
+		if err != nil {
+			continue
+		}
+
+		grids := container.GetItemGrids()
+		for _, name := range sortedKeys(grids) {
+			grid := grids[name]
+			if !gridAccepts(grid, item) {
+				continue
+			}
+
+			x, y, ok := bi.findSpace(containerID, grid, height, width)
+			if !ok {
+				continue
+			}
+
+			location := data.InventoryItemLocation{IsSearched: true, R: 0, X: x, Y: y}
+			stack := int32(1)
+			if stackMax := min(item.GetStackMaxSize(), maxLootStack); stackMax > 1 {
+				stack = 1 + bg.rand.Int31n(stackMax)
+			}
+			bg.addStack(bi, tpl, containerID, name, location, stack)
+			return true
+		}
+	}
+	return false
+}
+
+func gridAccepts(grid *data.Grid, item *data.DatabaseItem) bool {
+	if len(grid.Props.Filters) == 0 {
+		return true
+	}
+
+	for _, filter := range grid.Props.Filters {
+		if len(filter.Filter) != 0 && !item.IsChildOf(filter.Filter) {
+			continue
+		}
+		if item.IsChildOf(filter.ExcludedFilter) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// findSpace returns the first free position in the grid for an item of the given size and marks it as taken
+func (bi *botInventory) findSpace(containerID string, grid *data.Grid, height int8, width int8) (int, int, bool) {
+	gridHeight, gridWidth := int(grid.Props.CellsV), int(grid.Props.CellsH)
+	h, w := int(height), int(width)
+	if h > gridHeight || w > gridWidth {
+		return 0, 0, false
+	}
+
+	if bi.grids[containerID] == nil {
+		bi.grids[containerID] = make(map[string][][]bool)
+	}
+	cells, ok := bi.grids[containerID][grid.Name]
+	if !ok {
+		cells = make([][]bool, gridHeight)
+		for row := range cells {
+			cells[row] = make([]bool, gridWidth)
+		}
+		bi.grids[containerID][grid.Name] = cells
+	}
+
+	for y := 0; y+h <= gridHeight; y++ {
+		for x := 0; x+w <= gridWidth; x++ {
+			if !isAreaFree(cells, x, y, h, w) {
+				continue
+			}
+			for row := y; row < y+h; row++ {
+				for column := x; column < x+w; column++ {
+					cells[row][column] = true
+				}
+			}
+			return x, y, true
+		}
+	}
+	return 0, 0, false
+}
+
+func isAreaFree(cells [][]bool, x int, y int, height int, width int) bool {
+	for row := y; row < y+height; row++ {
+		for column := x; column < x+width; column++ {
+			if cells[row][column] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (bg *BotGenerator) addStack(bi *botInventory, tpl string, parent string, slot string, location any, count int32) {
+	item := bg.addItem(bi, tpl, parent, slot, location)
+	if item == nil {
+		return
+	}
+	if item.UPD == nil {
+		item.UPD = new(data.ItemUpdate)
+	}
+	item.UPD.StackObjectsCount = count
+	bi.inventory.Items[len(bi.inventory.Items)-1] = *item
+}
+
+// addItem appends a new item to the inventory and returns a copy of it
+func (bg *BotGenerator) addItem(bi *botInventory, tpl string, parent string, slot string, location any) *data.InventoryItem {
+	itemInDatabase, err := data.GetItemByID(tpl)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	item := data.InventoryItem{
+		ID:       bg.newID(),
+		TPL:      tpl,
+		ParentID: parent,
+		SlotID:   slot,
+		Location: location,
+		UPD:      createBotItemUPD(itemInDatabase),
+	}
+	bi.inventory.Items = append(bi.inventory.Items, item)
+	return &item
+}
+
+// createBotItemUPD is a quieter CreateItemUPD that only sets what the client needs on a bot
+func createBotItemUPD(item *data.DatabaseItem) *data.ItemUpdate {
+	maxDurability, ok := item.Props["MaxDurability"].(float64)
+	if !ok {
+		return nil
+	}
+	durability, ok := item.Props["Durability"].(float64)
+	if !ok {
+		durability = maxDurability
+	}
+
+	upd := &data.ItemUpdate{
+		Repairable: &data.Repairable{
+			Durability:    durability,
+			MaxDurability: maxDurability,
+		},
+	}
+	if item.IsWeapon() {
+		upd.FireMode = &data.FireMode{FireMode: "single"}
+	}
+	return upd
+}
+
+func (bg *BotGenerator) getSlotCandidates(slot *data.Slot) []string {
+	return data.GetBotFilterItems(slot.GetFilter())
+}
+
+// rollChance returns whether the slot gets filled, by its chance or fallback if chances has none for it
+func (bg *BotGenerator) rollChance(chances map[string]int, slot string, fallback int) bool {
+	chance, ok := chances[slot]
+	if !ok {
+		chance = fallback
+	}
+	return bg.rand.Intn(100) < chance
+}
+
+func (bg *BotGenerator) pick(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	return list[bg.rand.Intn(len(list))]
+}
+
+func (bg *BotGenerator) newID() string {
+	id := make([]byte, 12)
+	bg.rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func sortedKeys[T any](input map[string]T) []string {
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,229 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"mtgo/data"
+)
+
+// fixtureItems is the item database the tests run against, in place of assets/items.json
+const fixtureItems string = "testdata/items.json"
+
+func TestMain(m *testing.M) {
+	os.Exit(runWithFixtureDatabase(m))
+}
+
+// runWithFixtureDatabase loads the database from a copy of the repository's assets whose items are the fixture
+// ones, since the database is read from paths relative to the working directory
+func runWithFixtureDatabase(m *testing.M) int {
+	items, err := filepath.Abs(fixtureItems)
+	if err != nil {
+		panic(err)
+	}
+	assets, err := filepath.Abs(filepath.Join("..", "assets"))
+	if err != nil {
+		panic(err)
+	}
+
+	dir, err := os.MkdirTemp("", "mtgo-test")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "assets"), 0755); err != nil {
+		panic(err)
+	}
+	entries, err := os.ReadDir(assets)
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "items.json" {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(assets, entry.Name()), filepath.Join(dir, "assets", entry.Name())); err != nil {
+			panic(err)
+		}
+	}
+	if err := os.Symlink(items, filepath.Join(dir, "assets", "items.json")); err != nil {
+		panic(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	data.SetPrimaryDatabase()
+	data.SetCache()
+	data.SetBotItemPools()
+	return m.Run()
+}
+
+const (
+	fixtureRifle    string = "5644bd2b4bdc2d3b4c8b4572"
+	fixtureMagazine string = "55d480c04bdc2d1d4e8b456a"
+	fixtureAmmo     string = "56dff3afd2720bba668b4567"
+	fixtureGrenade  string = "5448be9a4bdc2dfd2f8b456a"
+)
+
+func getBotInventory(t *testing.T, bot data.DummyBot) *data.Inventory {
+	t.Helper()
+	inventory, ok := bot["Inventory"].(*data.Inventory)
+	if !ok {
+		t.Fatal("bot has no inventory")
+	}
+	return inventory
+}
+
+func TestBotGeneratorIsDeterministic(t *testing.T) {
+	first := NewBotGenerator(42).Generate("assault", "normal", 3)
+	second := NewBotGenerator(42).Generate("assault", "normal", 3)
+	if len(first) != 3 {
+		t.Fatalf("generated %d bots, want 3", len(first))
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("bots generated with the same seed differ")
+	}
+
+	other := NewBotGenerator(7).Generate("assault", "normal", 3)
+	if reflect.DeepEqual(first, other) {
+		t.Fatal("bots generated with different seeds are the same")
+	}
+}
+
+func TestBotGeneratorArmsBots(t *testing.T) {
+	for _, bot := range NewBotGenerator(1).Generate("assault", "normal", 10) {
+		inventory := getBotInventory(t, bot)
+
+		var rifle, magazine string
+		for _, item := range inventory.Items {
+			switch {
+			case item.ParentID == inventory.Equipment && item.SlotID == "FirstPrimaryWeapon":
+				if item.TPL != fixtureRifle {
+					t.Fatalf("primary weapon is %s, want %s", item.TPL, fixtureRifle)
+				}
+				rifle = item.ID
+			case item.TPL == fixtureMagazine:
+				magazine = item.ID
+			}
+		}
+		if rifle == "" || magazine == "" {
+			t.Fatal("bot has no loaded primary weapon")
+		}
+
+		var chambered bool
+		var cartridges int32
+		for _, item := range inventory.Items {
+			if item.ParentID == rifle && item.SlotID == "patron_in_weapon" {
+				chambered = item.TPL == fixtureAmmo
+			}
+			if item.ParentID == magazine {
+				if item.TPL != fixtureAmmo || item.SlotID != cartridgesSlot {
+					t.Errorf("magazine holds %s in %s", item.TPL, item.SlotID)
+				}
+				cartridges += item.UPD.StackObjectsCount
+			}
+		}
+		if !chambered {
+			t.Error("weapon chamber is empty")
+		}
+		if cartridges != 30 {
+			t.Errorf("magazine holds %d cartridges, want 30", cartridges)
+		}
+	}
+}
+
+func TestBotGeneratorFillsSlotsByChance(t *testing.T) {
+	bots := NewBotGenerator(3).Generate("assault", "normal", 20)
+
+	counts := make(map[string]int)
+	for _, bot := range bots {
+		inventory := getBotInventory(t, bot)
+		for _, item := range inventory.Items {
+			if item.ParentID == inventory.Equipment {
+				counts[item.SlotID]++
+			}
+		}
+	}
+
+	for _, slot := range []string{"FirstPrimaryWeapon", "Scabbard", "Pockets"} {
+		if counts[slot] != len(bots) {
+			t.Errorf("%d of %d bots have their %s filled, want all", counts[slot], len(bots), slot)
+		}
+	}
+	for _, slot := range []string{"Headwear", "ArmorVest", "Backpack"} {
+		if counts[slot] == 0 || counts[slot] == len(bots) {
+			t.Errorf("%d of %d bots have their %s filled, want some", counts[slot], len(bots), slot)
+		}
+	}
+	if counts["SecondPrimaryWeapon"] != 0 {
+		t.Errorf("%d bots have a second primary weapon", counts["SecondPrimaryWeapon"])
+	}
+}
+
+func TestBotGeneratorPacksLootInGrids(t *testing.T) {
+	loot := []string{"5734758f24597738025ee253", "57347ca924597744596b4e71", "544fb45d4bdc2dee738b4568", fixtureAmmo}
+
+	var packed int
+	for _, bot := range NewBotGenerator(5).Generate("assault", "normal", 20) {
+		inventory := getBotInventory(t, bot)
+
+		taken := make(map[string]map[[2]int]struct{})
+		for _, item := range inventory.Items {
+			location, ok := item.Location.(data.InventoryItemLocation)
+			if !ok {
+				continue
+			}
+			if !slices.Contains(loot, item.TPL) {
+				t.Errorf("bot carries %s, which is not bot loot", item.TPL)
+			}
+			packed++
+			if item.TPL == fixtureGrenade {
+				t.Error("bot carries loot from outside the bot loot categories")
+			}
+			if item.UPD != nil && item.UPD.StackObjectsCount > maxLootStack {
+				t.Errorf("item %s has a stack of %d", item.TPL, item.UPD.StackObjectsCount)
+			}
+
+			dbItem, err := data.GetItemByID(item.TPL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			height, width := dbItem.GetItemSize()
+			left, _ := location.X.(int)
+			top, _ := location.Y.(int)
+			grid := item.ParentID + "/" + item.SlotID
+			if taken[grid] == nil {
+				taken[grid] = make(map[[2]int]struct{})
+			}
+			for y := top; y < top+int(height); y++ {
+				for x := left; x < left+int(width); x++ {
+					if _, ok := taken[grid][[2]int{x, y}]; ok {
+						t.Fatalf("%s overlaps another item in %s", item.TPL, grid)
+					}
+					taken[grid][[2]int{x, y}] = struct{}{}
+				}
+			}
+		}
+	}
+	if packed == 0 {
+		t.Fatal("no bot carries anything in its containers")
+	}
+}
+
+func TestBotFilterItemsMatchItemFilter(t *testing.T) {
+	filters := [][]string{
+		{"5422acb9af1c889c16000029"},
+		{"5448eb774bdc2d0a728b4567", "543be5664bdc2dd4348b4569"},
+		{fixtureAmmo},
+	}
+	for _, filter := range filters {
+		if got, want := data.GetBotFilterItems(filter), data.GetItemsByFilter(filter, nil); !slices.Equal(got, want) {
+			t.Errorf("filter %v expands to %v, want %v", filter, got, want)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mtgo/data"
+	"mtgo/tools"
+	"net/http"
+	"slices"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	userDialogType  int8 = 1
+	groupDialogType int8 = 6
+)
+
+const (
+	notGroupMember  string = "%s is not a member of group %s"
+	notGroupOwner   string = "%s is not the owner of group %s"
+	messageIgnored  string = "%s can not message %s, they are ignored"
+	inviteIgnored   string = "%s can not invite %s to a group, they are ignored"
+	dialogTypeWrong string = "Dialog type %d can not be messaged by players"
+	groupInvite     string = "%s invited you to %s"
+)
+
+type sendMessage struct {
+	DialogID string `json:"dialogId"`
+	Type     int8   `json:"type"`
+	Text     string `json:"text"`
+	ReplyTo  string `json:"replyTo"`
+}
+
+type createGroup struct {
+	Name  string   `json:"Name"`
+	Users []string `json:"Users"`
+}
+
+type groupMember struct {
+	DialogID string `json:"dialogId"`
+	UID      string `json:"uid"`
+}
+
+// SendPlayerMessage stores the message in the dialogue of every participant and notifies them, returning the
+// ID of the message
+func SendPlayerMessage(r *http.Request) (string, error) {
+	request := new(sendMessage)
+	input, _ := json.Marshal(GetParsedBody(r))
+	if err := json.Unmarshal(input, request); err != nil {
+		return "", err
+	}
+
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return "", err
+	}
+
+	switch request.Type {
+	case userDialogType:
+		return sendDirectMessage(sessionID, request.DialogID, request.Text)
+	case groupDialogType:
+		return sendGroupMessage(sessionID, request.DialogID, request.Text)
+	default:
+		return "", fmt.Errorf(dialogTypeWrong, request.Type)
+	}
+}
+
+// sendDirectMessage stores the message in both players' dialogues, each under the ID of the other player; neither
+// is written unless both exist
+func sendDirectMessage(sessionID string, recipient string, text string) (string, error) {
+	if recipient == sessionID {
+		return "", errors.New("players can not message themselves")
+	}
+
+	unlock, err := data.LockOtherProfiles(sessionID, recipient)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	friends, err := data.GetFriendsByID(recipient)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(friends.Ignore, sessionID) {
+		return "", fmt.Errorf(messageIgnored, sessionID, recipient)
+	}
+
+	sender, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		return "", err
+	}
+	receiver, err := data.GetCharacterByID(recipient)
+	if err != nil {
+		return "", err
+	}
+	users := []data.DialogUser{data.CreateDialogUser(sender), data.CreateDialogUser(receiver)}
+
+	senderDialogues, err := data.GetDialogueByID(sessionID)
+	if err != nil {
+		return "", err
+	}
+	receiverDialogues, err := data.GetDialogueByID(recipient)
+	if err != nil {
+		return "", err
+	}
+
+	message := data.CreateUserMessage(sessionID, "User", text)
+	if err := storeUserMessage(senderDialogues, sessionID, recipient, userDialogType, users, message); err != nil {
+		return "", err
+	}
+	if err := storeUserMessage(receiverDialogues, recipient, sessionID, userDialogType, users, message); err != nil {
+		return "", err
+	}
+
+	if err := SendNotificationToPlayer(recipient, data.CreateDialogNotification(sessionID, message)); err != nil {
+		log.Println(err)
+	}
+	return message.ID, nil
+}
+
+// sendGroupMessage stores the message in the group dialog of every member
+func sendGroupMessage(sessionID string, groupID string, text string) (string, error) {
+	group, unlock, err := lockGroupDialog(sessionID, groupID, false)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	message := data.CreateUserMessage(sessionID, "Group", text)
+	sendGroupDialogMessage(group, message, group.Users)
+	return message.ID, nil
+}
+
+// sendGroupDialogMessage stores the message in the group dialog of every given member and notifies them, the
+// sender aside
+func sendGroupDialogMessage(group *data.Dialog, message *data.DialogMessage, users []data.DialogUser) {
+	for _, user := range users {
+		dialogues, err := data.GetDialogueByID(user.ID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if err := storeUserMessage(dialogues, user.ID, group.ID, groupDialogType, group.Users, message); err != nil {
+			log.Println(err)
+			continue
+		}
+		if user.ID == message.UID {
+			continue
+		}
+
+		if err := SendNotificationToPlayer(user.ID, data.CreateDialogNotification(group.ID, message)); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// storeUserMessage adds the message to the dialog of dialogID in the owner's dialogues, creating it if it does
+// not exist; messages are unread for everyone but their sender
+func storeUserMessage(dialogues *data.Dialogue, owner string, dialogID string, dialogType int8, users []data.DialogUser, message *data.DialogMessage) error {
+	dialog, ok := (*dialogues)[dialogID]
+	if !ok {
+		dialog = &data.Dialog{
+			ID:       dialogID,
+			Type:     dialogType,
+			Messages: make([]data.DialogMessage, 0),
+		}
+		(*dialogues)[dialogID] = dialog
+	}
+	if dialogType == userDialogType {
+		dialog.Users = slices.Clone(users)
+	}
+
+	stored := *message
+	stored.IsRead = message.UID == owner
+	dialog.Messages = append(dialog.Messages, stored)
+	if !stored.IsRead {
+		dialog.New++
+	}
+
+	return dialogues.SaveDialogue(owner)
+}
+
+// CreateGroupDialog creates a group chat owned by the session's player with the given players as members,
+// returning the ID of the group
+func CreateGroupDialog(r *http.Request) (string, error) {
+	request := new(createGroup)
+	input, _ := json.Marshal(GetParsedBody(r))
+	if err := json.Unmarshal(input, request); err != nil {
+		return "", err
+	}
+
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return "", err
+	}
+
+	unlock, err := data.LockOtherProfiles(sessionID, request.Users...)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	owner, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	group := &data.Dialog{
+		ID:    tools.GenerateMongoID(),
+		Type:  groupDialogType,
+		Name:  request.Name,
+		Owner: sessionID,
+		Users: []data.DialogUser{data.CreateDialogUser(owner)},
+	}
+	for _, id := range request.Users {
+		if id == sessionID || group.HasUser(id) {
+			continue
+		}
+
+		if err := checkGroupInvite(sessionID, id); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		character, err := data.GetCharacterByID(id)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		group.Users = append(group.Users, data.CreateDialogUser(character))
+	}
+
+	syncGroupDialog(group, nil)
+	inviteGroupDialogUsers(group, group.Users[1:])
+	return group.ID, nil
+}
+
+// LeaveGroupDialog removes the session's player from the group, handing ownership over to the next member
+// if they owned it
+func LeaveGroupDialog(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	groupID, _ := GetParsedBody(r).(map[string]any)["dialogId"].(string)
+
+	group, unlock, err := lockGroupDialog(sessionID, groupID, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	group.Users = slices.DeleteFunc(slices.Clone(group.Users), func(user data.DialogUser) bool {
+		return user.ID == sessionID
+	})
+	if group.Owner == sessionID && len(group.Users) != 0 {
+		group.Owner = group.Users[0].ID
+	}
+
+	syncGroupDialog(group, []string{sessionID})
+	return nil
+}
+
+// ChangeGroupDialogOwner hands ownership of the group over to another member
+func ChangeGroupDialogOwner(r *http.Request) error {
+	request, sessionID, err := getGroupMemberRequest(r)
+	if err != nil {
+		return err
+	}
+
+	group, unlock, err := lockGroupDialog(sessionID, request.DialogID, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !group.HasUser(request.UID) {
+		return fmt.Errorf(notGroupMember, request.UID, request.DialogID)
+	}
+
+	group.Owner = request.UID
+	syncGroupDialog(group, nil)
+	return nil
+}
+
+// AddGroupDialogUser adds the player to the group
+func AddGroupDialogUser(r *http.Request) error {
+	request, sessionID, err := getGroupMemberRequest(r)
+	if err != nil {
+		return err
+	}
+
+	group, unlock, err := lockGroupDialog(sessionID, request.DialogID, true, request.UID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if group.HasUser(request.UID) {
+		return nil
+	}
+	if err := checkGroupInvite(sessionID, request.UID); err != nil {
+		return err
+	}
+
+	character, err := data.GetCharacterByID(request.UID)
+	if err != nil {
+		return err
+	}
+
+	user := data.CreateDialogUser(character)
+	group.Users = append(slices.Clone(group.Users), user)
+	syncGroupDialog(group, nil)
+	inviteGroupDialogUsers(group, []data.DialogUser{user})
+	return nil
+}
+
+// RemoveGroupDialogUser removes the player from the group
+func RemoveGroupDialogUser(r *http.Request) error {
+	request, sessionID, err := getGroupMemberRequest(r)
+	if err != nil {
+		return err
+	}
+
+	group, unlock, err := lockGroupDialog(sessionID, request.DialogID, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if request.UID == sessionID || !group.HasUser(request.UID) {
+		return fmt.Errorf(notGroupMember, request.UID, request.DialogID)
+	}
+
+	group.Users = slices.DeleteFunc(slices.Clone(group.Users), func(user data.DialogUser) bool {
+		return user.ID == request.UID
+	})
+	syncGroupDialog(group, []string{request.UID})
+	return nil
+}
+
+// inviteGroupDialogUsers sends the invited members a message from the owner of the group, so the group shows up
+// in their chats
+func inviteGroupDialogUsers(group *data.Dialog, invited []data.DialogUser) {
+	if len(invited) == 0 {
+		return
+	}
+
+	var owner string
+	for _, user := range group.Users {
+		if user.ID == group.Owner {
+			owner = user.Info.Nickname
+			break
+		}
+	}
+
+	message := data.CreateUserMessage(group.Owner, "Group", fmt.Sprintf(groupInvite, owner, group.Name))
+	sendGroupDialogMessage(group, message, invited)
+}
+
+func getGroupMemberRequest(r *http.Request) (*groupMember, string, error) {
+	request := new(groupMember)
+	input, _ := json.Marshal(GetParsedBody(r))
+	if err := json.Unmarshal(input, request); err != nil {
+		return nil, "", err
+	}
+
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return nil, "", err
+	}
+	return request, sessionID, nil
+}
+
+// getGroupDialog returns a copy of the group dialog, as stored in the dialogue of the session's player
+func getGroupDialog(sessionID string, groupID string) (*data.Dialog, error) {
+	dialogues, err := data.GetDialogueByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	dialog, ok := (*dialogues)[groupID]
+	if !ok || dialog.Type != groupDialogType || !dialog.HasUser(sessionID) {
+		return nil, fmt.Errorf(notGroupMember, sessionID, groupID)
+	}
+
+	group := *dialog
+	return &group, nil
+}
+
+// lockGroupDialog returns a copy of the group dialog with every member, and the extra players, locked; only its
+// owner can get it if owned is set. The returned function unlocks them
+func lockGroupDialog(sessionID string, groupID string, owned bool, extra ...string) (*data.Dialog, func(), error) {
+	locked := make([]string, 0)
+	unlock := func() {}
+	for {
+		group, err := getGroupDialog(sessionID, groupID)
+		if err != nil {
+			unlock()
+			return nil, nil, err
+		}
+		if owned && group.Owner != sessionID {
+			unlock()
+			return nil, nil, fmt.Errorf(notGroupOwner, sessionID, groupID)
+		}
+
+		ids := slices.Clone(extra)
+		for _, user := range group.Users {
+			ids = append(ids, user.ID)
+		}
+		// members may have changed while the session's profile was released to lock them, so retry until they hold
+		if !slices.ContainsFunc(ids, func(id string) bool { return !slices.Contains(locked, id) }) {
+			return group, unlock, nil
+		}
+
+		unlock()
+		if unlock, err = data.LockOtherProfiles(sessionID, ids...); err != nil {
+			return nil, nil, err
+		}
+		locked = ids
+	}
+}
+
+// checkGroupInvite returns an error if the invited player ignores the owner of the group
+func checkGroupInvite(sessionID string, invited string) error {
+	friends, err := data.GetFriendsByID(invited)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(friends.Ignore, sessionID) {
+		return fmt.Errorf(inviteIgnored, sessionID, invited)
+	}
+	return nil
+}
+
+// syncGroupDialog copies the name, owner and members of the group to the dialogue of every member, creating the
+// dialog for new members, and removes the group from the dialogue of every removed player
+func syncGroupDialog(group *data.Dialog, removed []string) {
+	for _, user := range group.Users {
+		dialogues, err := data.GetDialogueByID(user.ID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		dialog, ok := (*dialogues)[group.ID]
+		if !ok {
+			dialog = &data.Dialog{
+				ID:       group.ID,
+				Type:     groupDialogType,
+				Messages: make([]data.DialogMessage, 0),
+			}
+			(*dialogues)[group.ID] = dialog
+		}
+		dialog.Name = group.Name
+		dialog.Owner = group.Owner
+		dialog.Users = slices.Clone(group.Users)
+
+		if err := dialogues.SaveDialogue(user.ID); err != nil {
+			log.Println(err)
+		}
+	}
+
+	for _, id := range removed {
+		dialogues, err := data.GetDialogueByID(id)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		delete(*dialogues, group.ID)
+		if err := dialogues.SaveDialogue(id); err != nil {
+			log.Println(err)
+		}
+	}
+}
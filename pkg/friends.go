@@ -0,0 +1,367 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mtgo/data"
+	"mtgo/tools"
+	"net/http"
+	"slices"
+)
+
+const (
+	friendRequestNotExist string = "Friend request from %s to %s does not exist"
+	alreadyFriends        string = "%s and %s are already friends"
+	friendRequestPending  string = "Friend request from %s to %s is already pending"
+	friendRequestIgnored  string = "%s is ignored by %s"
+	friendNotExist        string = "%s is not a friend of %s"
+)
+
+type FriendRequestSent struct {
+	Status     int8   `json:"status"`
+	RequestID  string `json:"requestId"`
+	RetryAfter int32  `json:"retryAfter"`
+}
+
+// SendFriendRequest sends a friend request to the player, accepting theirs instead if they already sent one
+func SendFriendRequest(r *http.Request) (*FriendRequestSent, error) {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return nil, err
+	}
+	to, _ := GetParsedBody(r).(map[string]any)["to"].(string)
+	if to == sessionID {
+		return nil, errors.New("players can not send friend requests to themselves")
+	}
+
+	sender, receiver, unlock, err := lockFriendsOfBoth(sessionID, to)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if sender.IsFriend(to) {
+		return nil, fmt.Errorf(alreadyFriends, sessionID, to)
+	}
+	if sender.HasOutboxRequest(to) {
+		return nil, fmt.Errorf(friendRequestPending, sessionID, to)
+	}
+	if slices.Contains(receiver.Ignore, sessionID) {
+		return nil, fmt.Errorf(friendRequestIgnored, sessionID, to)
+	}
+
+	if request, ok := sender.GetInboxRequest(to); ok {
+		requestID := request.ID
+		if err := acceptFriendRequest(sessionID, sender, to, receiver); err != nil {
+			return nil, err
+		}
+		return &FriendRequestSent{RequestID: requestID}, nil
+	}
+
+	senderCharacter, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	receiverCharacter, err := data.GetCharacterByID(to)
+	if err != nil {
+		return nil, err
+	}
+
+	request := data.FriendRequest{
+		ID:   tools.GenerateMongoID(),
+		From: sessionID,
+		To:   to,
+		Date: int32(tools.GetCurrentTimeInSeconds()),
+	}
+
+	request.Profile = data.CreateFriendProfile(receiverCharacter)
+	sender.FriendRequestOutbox = append(sender.FriendRequestOutbox, request)
+
+	request.Profile = data.CreateFriendProfile(senderCharacter)
+	receiver.FriendRequestInbox = append(receiver.FriendRequestInbox, request)
+
+	if err := saveFriendsOfBoth(sessionID, sender, to, receiver); err != nil {
+		return nil, err
+	}
+
+	notification := data.CreateFriendNotification(data.FriendRequestNew, request.ID, request.Profile)
+	if err := SendNotificationToPlayer(to, notification); err != nil {
+		log.Println(err)
+	}
+
+	return &FriendRequestSent{RequestID: request.ID}, nil
+}
+
+// AcceptFriendRequest accepts the friend request sent by the player
+func AcceptFriendRequest(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	from, _ := GetParsedBody(r).(map[string]any)["profileId"].(string)
+
+	receiver, sender, unlock, err := lockFriendsOfBoth(sessionID, from)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return acceptFriendRequest(sessionID, receiver, from, sender)
+}
+
+// AcceptAllFriendRequests accepts every pending friend request sent to the player
+func AcceptAllFriendRequests(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+
+	friends, err := data.GetFriendsByID(sessionID)
+	if err != nil {
+		return err
+	}
+
+	inbox := slices.Clone(friends.FriendRequestInbox)
+	for _, request := range inbox {
+		receiver, sender, unlock, err := lockFriendsOfBoth(sessionID, request.From)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if err := acceptFriendRequest(sessionID, receiver, request.From, sender); err != nil {
+			log.Println(err)
+		}
+		unlock()
+	}
+	return nil
+}
+
+// acceptFriendRequest accepts the friend request sent by the player of from; the caller holds both profiles
+func acceptFriendRequest(sessionID string, receiver *data.Friends, from string, sender *data.Friends) error {
+	request, ok := receiver.GetInboxRequest(from)
+	if !ok {
+		return fmt.Errorf(friendRequestNotExist, from, sessionID)
+	}
+	requestID := request.ID
+
+	receiverCharacter, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		return err
+	}
+	senderCharacter, err := data.GetCharacterByID(from)
+	if err != nil {
+		return err
+	}
+
+	receiver.RemoveRequests(from)
+	sender.RemoveRequests(sessionID)
+
+	profile := data.CreateFriendProfile(receiverCharacter)
+	if !receiver.IsFriend(from) {
+		receiver.Friends = append(receiver.Friends, data.CreateFriendProfile(senderCharacter))
+	}
+	if !sender.IsFriend(sessionID) {
+		sender.Friends = append(sender.Friends, profile)
+	}
+
+	if err := saveFriendsOfBoth(sessionID, receiver, from, sender); err != nil {
+		return err
+	}
+
+	notification := data.CreateFriendNotification(data.FriendRequestAccepted, requestID, profile)
+	if err := SendNotificationToPlayer(from, notification); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+// DeclineFriendRequest declines the friend request sent by the player
+func DeclineFriendRequest(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	from, _ := GetParsedBody(r).(map[string]any)["profileId"].(string)
+
+	receiver, sender, unlock, err := lockFriendsOfBoth(sessionID, from)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	request, ok := receiver.GetInboxRequest(from)
+	if !ok {
+		return fmt.Errorf(friendRequestNotExist, from, sessionID)
+	}
+	requestID := request.ID
+
+	receiver.RemoveRequests(from)
+	sender.RemoveRequests(sessionID)
+	if err := saveFriendsOfBoth(sessionID, receiver, from, sender); err != nil {
+		return err
+	}
+
+	return notifyFriendEvent(sessionID, from, data.FriendRequestDeclined, requestID)
+}
+
+// CancelFriendRequest withdraws the friend request sent to the player
+func CancelFriendRequest(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	to, _ := GetParsedBody(r).(map[string]any)["profileId"].(string)
+
+	sender, receiver, unlock, err := lockFriendsOfBoth(sessionID, to)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	request, ok := receiver.GetInboxRequest(sessionID)
+	if !ok {
+		return fmt.Errorf(friendRequestNotExist, sessionID, to)
+	}
+	requestID := request.ID
+
+	sender.RemoveRequests(to)
+	receiver.RemoveRequests(sessionID)
+	if err := saveFriendsOfBoth(sessionID, sender, to, receiver); err != nil {
+		return err
+	}
+
+	return notifyFriendEvent(sessionID, to, data.FriendRequestCanceled, requestID)
+}
+
+// RemoveFriend removes the player from the friend list, and the session's player from theirs
+func RemoveFriend(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	friendID, _ := GetParsedBody(r).(map[string]any)["friend_id"].(string)
+
+	friends, other, unlock, err := lockFriendsOfBoth(sessionID, friendID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !friends.RemoveFriend(friendID) {
+		return fmt.Errorf(friendNotExist, friendID, sessionID)
+	}
+	other.RemoveFriend(sessionID)
+
+	if err := saveFriendsOfBoth(sessionID, friends, friendID, other); err != nil {
+		return err
+	}
+
+	return notifyFriendEvent(sessionID, friendID, data.FriendRemoved, "")
+}
+
+// IgnorePlayer adds the player to the ignore list, which stops them from sending friend requests, and ends any
+// friendship or pending request between the two
+func IgnorePlayer(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	uid, _ := GetParsedBody(r).(map[string]any)["uid"].(string)
+
+	friends, other, unlock, err := lockFriendsOfBoth(sessionID, uid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if slices.Contains(friends.Ignore, uid) {
+		return nil
+	}
+	friends.Ignore = append(friends.Ignore, uid)
+	if !slices.Contains(other.InIgnoreList, sessionID) {
+		other.InIgnoreList = append(other.InIgnoreList, sessionID)
+	}
+
+	removed := friends.RemoveFriend(uid)
+	other.RemoveFriend(sessionID)
+	friends.RemoveRequests(uid)
+	other.RemoveRequests(sessionID)
+
+	if err := saveFriendsOfBoth(sessionID, friends, uid, other); err != nil {
+		return err
+	}
+
+	if removed {
+		if err := notifyFriendEvent(sessionID, uid, data.FriendRemoved, ""); err != nil {
+			log.Println(err)
+		}
+	}
+	return notifyFriendEvent(sessionID, uid, data.IgnoreAdded, "")
+}
+
+// UnignorePlayer removes the player from the ignore list
+func UnignorePlayer(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	uid, _ := GetParsedBody(r).(map[string]any)["uid"].(string)
+
+	friends, other, unlock, err := lockFriendsOfBoth(sessionID, uid)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !slices.Contains(friends.Ignore, uid) {
+		return nil
+	}
+	friends.Ignore = slices.DeleteFunc(friends.Ignore, func(id string) bool { return id == uid })
+	other.InIgnoreList = slices.DeleteFunc(other.InIgnoreList, func(id string) bool { return id == sessionID })
+
+	if err := saveFriendsOfBoth(sessionID, friends, uid, other); err != nil {
+		return err
+	}
+
+	return notifyFriendEvent(sessionID, uid, data.IgnoreRemoved, "")
+}
+
+// lockFriendsOfBoth locks the other player's profile alongside the session's and returns the friends of both; the
+// returned function unlocks the other player
+func lockFriendsOfBoth(sessionID string, otherID string) (*data.Friends, *data.Friends, func(), error) {
+	unlock, err := data.LockOtherProfiles(sessionID, otherID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	friends, err := data.GetFriendsByID(sessionID)
+	if err != nil {
+		unlock()
+		return nil, nil, nil, err
+	}
+	other, err := data.GetFriendsByID(otherID)
+	if err != nil {
+		unlock()
+		return nil, nil, nil, err
+	}
+	return friends, other, unlock, nil
+}
+
+func saveFriendsOfBoth(sessionID string, friends *data.Friends, otherID string, other *data.Friends) error {
+	if err := friends.SaveFriends(sessionID); err != nil {
+		return err
+	}
+	return other.SaveFriends(otherID)
+}
+
+// notifyFriendEvent notifies the player of recipient that the session's player caused the friend list event
+func notifyFriendEvent(sessionID string, recipient string, notificationType string, requestID string) error {
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		return err
+	}
+
+	notification := data.CreateFriendNotification(notificationType, requestID, data.CreateFriendProfile(character))
+	return SendNotificationToPlayer(recipient, notification)
+}
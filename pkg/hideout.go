@@ -0,0 +1,954 @@
+package pkg
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"mtgo/data"
+	"mtgo/tools"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	stashSizeBonus       string = "StashSize"
+	additionalSlotsBonus string = "AdditionalSlots"
+	productionSpeedBonus string = "ProductionSpeed"
+	areaNotInHideout     string = "Hideout Area Type %d does not exist in character hideout\n"
+	areaConstructing     string = "Hideout Area Type %d is already being constructed\n"
+	areaNotConstructing  string = "Hideout Area Type %d is not being constructed\n"
+	areaNotComplete      string = "Hideout Area Type %d is still being constructed for %d seconds\n"
+	areaLevelTooLow      string = "Hideout Area Type %d must be level %d"
+	loyaltyTooLow        string = "Trader %s must be loyalty level %d"
+	skillTooLow          string = "Skill %s must be level %d"
+	questNotComplete     string = "Quest %s must be completed"
+	duplicateSchemeItem  string = "Item %s is given more than once"
+	insufficientResource string = "%d resource of %s required but only %d left"
+)
+
+type hideoutUpgrade struct {
+	Action    string
+	AreaType  int8            `json:"areaType"`
+	Items     []tradingScheme `json:"items"`
+	TimeStamp float64         `json:"timeStamp"`
+}
+
+// HideoutUpgrade takes the requirements of the next level of the area and starts its construction
+func HideoutUpgrade(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	upgrade := new(hideoutUpgrade)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &upgrade); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	area := getCharacterHideoutArea(character, upgrade.AreaType)
+	if area == nil {
+		log.Printf(areaNotInHideout, upgrade.AreaType)
+		return
+	}
+	if area.Constructing {
+		log.Printf(areaConstructing, upgrade.AreaType)
+		return
+	}
+
+	stage, err := data.GetHideoutAreaStage(upgrade.AreaType, area.Level+1)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err := checkHideoutRequirements(character, stage.Requirements, upgrade.Items); err != nil {
+		log.Println(err)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	if err := RemoveItemCountsFromInventory(character, upgrade.Items, changes); err != nil {
+		log.Println(err)
+		return
+	}
+
+	if stage.ConstructionTime <= 0 {
+		completeHideoutUpgrade(character, area, changes)
+	} else {
+		area.Constructing = true
+		area.CompleteTime = int(tools.GetCurrentTimeInSeconds()) + int(stage.ConstructionTime)
+	}
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+type hideoutUpgradeComplete struct {
+	Action    string
+	AreaType  int8    `json:"areaType"`
+	TimeStamp float64 `json:"timeStamp"`
+}
+
+// HideoutUpgradeComplete finishes the construction of the area, raising its level and applying its bonuses
+func HideoutUpgradeComplete(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	upgradeComplete := new(hideoutUpgradeComplete)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &upgradeComplete); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	area := getCharacterHideoutArea(character, upgradeComplete.AreaType)
+	if area == nil {
+		log.Printf(areaNotInHideout, upgradeComplete.AreaType)
+		return
+	}
+	if !area.Constructing {
+		log.Printf(areaNotConstructing, upgradeComplete.AreaType)
+		return
+	}
+	if now := int(tools.GetCurrentTimeInSeconds()); now < area.CompleteTime {
+		log.Printf(areaNotComplete, upgradeComplete.AreaType, area.CompleteTime-now)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	completeHideoutUpgrade(character, area, changes)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+// completeHideoutUpgrade raises the level of the area, adds its new slots and applies the bonuses of the new stage
+func completeHideoutUpgrade(character *data.Character[map[string]data.PlayerTradersInfo], area *data.PlayerHideoutArea, changes *data.ProfileChanges) {
+	stage, err := data.GetHideoutAreaStage(int8(area.Type), area.Level+1)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	area.Level++
+	area.Constructing = false
+	area.CompleteTime = 0
+
+	slots := stage.Slots
+	for _, bonus := range stage.Bonuses {
+		if bonus.Type == additionalSlotsBonus {
+			slots += int(bonus.Value)
+		}
+		applyHideoutBonus(character, &bonus, changes)
+	}
+
+	for idx := len(area.Slots); idx < slots; idx++ {
+		area.Slots = append(area.Slots, map[string]any{"locationIndex": idx})
+	}
+}
+
+// applyHideoutBonus adds the bonus to the character, swapping their stash for a bigger one if it is a StashSize bonus
+func applyHideoutBonus(character *data.Character[map[string]data.PlayerTradersInfo], bonus *data.HideoutBonus, changes *data.ProfileChanges) {
+	id := bonus.ID
+	if id == "" {
+		id = tools.GenerateMongoID()
+	}
+
+	character.Bonuses = append(character.Bonuses, data.Bonus{
+		ID:         id,
+		Type:       bonus.Type,
+		TemplateID: bonus.TemplateID,
+		IsPositive: bonus.Value >= 0,
+		Passive:    bonus.Passive,
+		Production: bonus.Production,
+		Value:      int32(bonus.Value),
+		Visible:    bonus.Visible,
+	})
+
+	if bonus.Type != stashSizeBonus || bonus.TemplateID == "" {
+		return
+	}
+
+	for idx, item := range character.Inventory.Items {
+		if item.ID != character.Inventory.Stash {
+			continue
+		}
+		character.Inventory.Items[idx].TPL = bonus.TemplateID
+		changes.Items.Change = append(changes.Items.Change, character.Inventory.Items[idx])
+		break
+	}
+
+	cache, err := data.GetCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	cache.Inventory = data.SetInventoryContainer(&character.Inventory)
+}
+
+// checkHideoutRequirements verifies the character meets every area, trader loyalty and skill requirement,
+// and that the given items cover every item requirement
+func checkHideoutRequirements(character *data.Character[map[string]data.PlayerTradersInfo], requirements []data.HideoutRequirement, items []tradingScheme) error {
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		return err
+	}
+
+	resources := getResourceRequirements(requirements)
+	provided := make(map[string]int32)
+	resource := make(map[string]int32)
+	seen := make(map[string]struct{}, len(items))
+	for _, scheme := range items {
+		if _, ok := seen[scheme.ID]; ok {
+			return fmt.Errorf(duplicateSchemeItem, scheme.ID)
+		}
+		seen[scheme.ID] = struct{}{}
+
+		index := invCache.GetIndexOfItemByID(scheme.ID)
+		if index == nil {
+			return fmt.Errorf(itemNotInInventory, scheme.ID)
+		}
+		item := &character.Inventory.Items[*index]
+
+		if _, ok := resources[item.TPL]; ok {
+			left := getItemResource(item)
+			if scheme.Count > left {
+				return fmt.Errorf(insufficientResource, scheme.Count, scheme.ID, left)
+			}
+			resource[item.TPL] += left
+		}
+		provided[item.TPL] += scheme.Count
+	}
+
+	for _, requirement := range requirements {
+		switch requirement.Type {
+		case "Area":
+			area := getCharacterHideoutArea(character, requirement.AreaType)
+			if area == nil || area.Level < requirement.RequiredLevel {
+				return fmt.Errorf(areaLevelTooLow, requirement.AreaType, requirement.RequiredLevel)
+			}
+		case "Item":
+			if provided[requirement.TemplateID] < requirement.Count {
+				return fmt.Errorf(insufficientItemCount, requirement.Count, requirement.TemplateID, provided[requirement.TemplateID])
+			}
+		case "Tool":
+			if provided[requirement.TemplateID] == 0 {
+				return fmt.Errorf(insufficientItemCount, 1, requirement.TemplateID, 0)
+			}
+		case "Resource":
+			if resource[requirement.TemplateID] < requirement.Resource {
+				return fmt.Errorf(insufficientResource, requirement.Resource, requirement.TemplateID, resource[requirement.TemplateID])
+			}
+		case "TraderLoyalty":
+			if character.TradersInfo[requirement.TraderID].LoyaltyLevel < requirement.LoyaltyLevel {
+				return fmt.Errorf(loyaltyTooLow, requirement.TraderID, requirement.LoyaltyLevel)
+			}
+		case "Skill":
+			if getCharacterSkillLevel(character, requirement.SkillName) < requirement.SkillLevel {
+				return fmt.Errorf(skillTooLow, requirement.SkillName, requirement.SkillLevel)
+			}
+		case "QuestComplete":
+			if !isQuestComplete(character, requirement.QuestID) {
+				return fmt.Errorf(questNotComplete, requirement.QuestID)
+			}
+		}
+	}
+	return nil
+}
+
+func isQuestComplete(character *data.Character[map[string]data.PlayerTradersInfo], qid string) bool {
+	for _, quest := range character.Quests {
+		if quest.QID == qid {
+			return quest.Status == "Success"
+		}
+	}
+	return false
+}
+
+func getCharacterHideoutArea(character *data.Character[map[string]data.PlayerTradersInfo], areaType int8) *data.PlayerHideoutArea {
+	if character.Hideout == nil {
+		return nil
+	}
+	for idx, area := range character.Hideout.Areas {
+		if area.Type == int(areaType) {
+			return &character.Hideout.Areas[idx]
+		}
+	}
+	return nil
+}
+
+// getCharacterSkillLevel returns the level of the skill, every 100 points of progress being one level
+func getCharacterSkillLevel(character *data.Character[map[string]data.PlayerTradersInfo], name string) int {
+	for _, skill := range character.Skills.Common {
+		if skill.ID == name {
+			return skill.Progress / 100
+		}
+	}
+	return 0
+}
+
+// #region Hideout production
+
+const (
+	bitcoinFarmArea   int8   = 20
+	generatorArea     int8   = 4
+	scavCaseArea      int8   = 14
+	craftingSkill     string = "Crafting"
+	productionNotDone string = "Production %s is not finished\n"
+	productionMissing string = "Production %s does not exist in character hideout\n"
+)
+
+type hideoutProductionStart struct {
+	Action    string
+	RecipeID  string          `json:"recipeId"`
+	Items     []tradingScheme `json:"items"`
+	Tools     []tradingScheme `json:"tools"`
+	TimeStamp float64         `json:"timestamp"`
+}
+
+// HideoutSingleProductionStart takes the requirements of the recipe and starts crafting it
+func HideoutSingleProductionStart(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	start := new(hideoutProductionStart)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &start); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	recipe, err := data.GetHideoutRecipe(start.RecipeID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err := checkHideoutRequirements(character, recipe.Requirements, slices.Concat(start.Items, start.Tools)); err != nil {
+		log.Println(err)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	if err := takeProductionRequirements(character, recipe.Requirements, start.Items, changes); err != nil {
+		log.Println(err)
+		return
+	}
+
+	givenTools := make([]data.InventoryItem, 0)
+	if len(start.Tools) != 0 {
+		toolIDs := make([]string, 0, len(start.Tools))
+		for _, tool := range start.Tools {
+			toolIDs = append(toolIDs, tool.ID)
+		}
+		if givenTools, err = RemoveItemsFromInventory(character, toolIDs, changes); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	production := newProduction(recipe.ID, getProductionTime(character, recipe), recipe.NeedFuelForAllProductionTime)
+	production.Products = createProductionItems(recipe.EndProduct, recipe.Count)
+	production.GivenItemsInStart = givenTools
+
+	setCharacterProduction(character, production, changes)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+type hideoutContinuousProductionStart struct {
+	Action    string
+	RecipeID  string  `json:"recipeId"`
+	TimeStamp float64 `json:"timestamp"`
+}
+
+// HideoutContinuousProductionStart starts a production that keeps running until its products are full
+func HideoutContinuousProductionStart(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	start := new(hideoutContinuousProductionStart)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &start); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	recipe, err := data.GetHideoutRecipe(start.RecipeID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err := checkHideoutRequirements(character, recipe.Requirements, nil); err != nil {
+		log.Println(err)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	production := newProduction(recipe.ID, getProductionTime(character, recipe), recipe.NeedFuelForAllProductionTime)
+	setCharacterProduction(character, production, changes)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+// HideoutScavCaseProductionStart takes the payment of the scav case recipe and rolls its rewards by rarity
+func HideoutScavCaseProductionStart(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	start := new(hideoutProductionStart)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &start); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	recipe, err := data.GetScavCaseRecipe(start.RecipeID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err := checkHideoutRequirements(character, recipe.Requirements, start.Items); err != nil {
+		log.Println(err)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	if err := RemoveItemCountsFromInventory(character, start.Items, changes); err != nil {
+		log.Println(err)
+		return
+	}
+
+	production := newProduction(recipe.ID, recipe.ProductionTime, false)
+	production.Products = rollScavCaseProducts(recipe)
+
+	setCharacterProduction(character, production, changes)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+type hideoutTakeProduction struct {
+	Action    string
+	RecipeID  string  `json:"recipeId"`
+	TimeStamp float64 `json:"timestamp"`
+}
+
+// HideoutTakeProduction moves the finished products, and any tools given at the start, into the stash
+func HideoutTakeProduction(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	take := new(hideoutTakeProduction)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &take); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	UpdateHideoutProductions(character)
+
+	production, ok := character.Hideout.Production[take.RecipeID]
+	if !ok || production == nil {
+		log.Printf(productionMissing, take.RecipeID)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	recipe, _ := getContinuousRecipe(take.RecipeID)
+	if recipe != nil {
+		if len(production.Products) == 0 {
+			log.Printf(productionNotDone, take.RecipeID)
+			return
+		}
+		if err := AddItemsToInventory(character, production.Products, changes); err != nil {
+			log.Println(err)
+			return
+		}
+		production.Products = make([]data.InventoryItem, 0)
+	} else {
+		if production.Progress < production.ProductionTime {
+			log.Printf(productionNotDone, take.RecipeID)
+			return
+		}
+		items := slices.Concat(production.Products, production.GivenItemsInStart)
+		if err := AddItemsToInventory(character, items, changes); err != nil {
+			log.Println(err)
+			return
+		}
+		delete(character.Hideout.Production, take.RecipeID)
+	}
+
+	changes.Production = &character.Hideout.Production
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+type hideoutToggleArea struct {
+	Action    string
+	AreaType  int8    `json:"area"`
+	Enabled   bool    `json:"enabled"`
+	TimeStamp float64 `json:"timestamp"`
+}
+
+// HideoutToggleArea turns the area on or off, bringing productions up to date first so they progress at the
+// speed they had until now
+func HideoutToggleArea(action map[string]any, sessionID string, _ *data.ProfileChangesEvent) {
+	toggle := new(hideoutToggleArea)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &toggle); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	area := getCharacterHideoutArea(character, toggle.AreaType)
+	if area == nil {
+		log.Printf(areaNotInHideout, toggle.AreaType)
+		return
+	}
+
+	UpdateHideoutProductions(character)
+	area.Active = toggle.Enabled
+}
+
+// UpdateHideoutProductions brings the progress of every running production up to the current time, so crafts
+// keep going while the server is down
+func UpdateHideoutProductions(character *data.Character[map[string]data.PlayerTradersInfo]) {
+	if character.Hideout == nil {
+		return
+	}
+
+	now := time.Now().Unix()
+	for rid, production := range character.Hideout.Production {
+		if production == nil || !production.InProgress {
+			continue
+		}
+
+		last := production.UpdateTimestamp
+		if last == 0 {
+			last, _ = strconv.ParseInt(production.StartTimestamp, 10, 64)
+		}
+		production.UpdateTimestamp = now
+		if last == 0 || now <= last {
+			continue
+		}
+
+		recipe, _ := getContinuousRecipe(rid)
+		elapsed := float64(now-last) * getProductionSpeed(character, rid)
+		if recipe == nil {
+			production.Progress = min(production.Progress+elapsed, production.ProductionTime)
+			continue
+		}
+
+		production.ProductionTime = getProductionTime(character, recipe)
+		if production.ProductionTime <= 0 || len(production.Products) >= recipe.ProductionLimitCount {
+			production.Progress = 0
+			continue
+		}
+
+		production.Progress += elapsed
+		for production.Progress >= production.ProductionTime && len(production.Products) < recipe.ProductionLimitCount {
+			production.Products = append(production.Products, createProductionItems(recipe.EndProduct, recipe.Count)...)
+			production.Progress -= production.ProductionTime
+		}
+		if len(production.Products) >= recipe.ProductionLimitCount {
+			production.Progress = 0
+		}
+	}
+}
+
+func newProduction(rid string, productionTime float64, needFuel bool) *data.Production {
+	now := time.Now().Unix()
+	return &data.Production{
+		Progress:                     0,
+		InProgress:                   true,
+		RecipeID:                     rid,
+		Products:                     make([]data.InventoryItem, 0),
+		ProductionTime:               productionTime,
+		StartTimestamp:               strconv.FormatInt(now, 10),
+		GivenItemsInStart:            make([]data.InventoryItem, 0),
+		NeedFuelForAllProductionTime: needFuel,
+		UpdateTimestamp:              now,
+	}
+}
+
+func setCharacterProduction(character *data.Character[map[string]data.PlayerTradersInfo], production *data.Production, changes *data.ProfileChanges) {
+	if character.Hideout.Production == nil {
+		character.Hideout.Production = make(map[string]*data.Production)
+	}
+	character.Hideout.Production[production.RecipeID] = production
+	changes.Production = &character.Hideout.Production
+}
+
+// getContinuousRecipe returns the hideout recipe if it is a continuous one
+func getContinuousRecipe(rid string) (*data.HideoutRecipe, error) {
+	if data.IsScavCaseRecipe(rid) {
+		return nil, nil
+	}
+
+	recipe, err := data.GetHideoutRecipe(rid)
+	if err != nil || !recipe.Continuous {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+// getProductionTime returns how long the recipe takes, reduced by the Crafting skill or, for the bitcoin farm,
+// by the graphics cards installed in it, and by the character's ProductionSpeed bonuses
+func getProductionTime(character *data.Character[map[string]data.PlayerTradersInfo], recipe *data.HideoutRecipe) float64 {
+	return getBaseProductionTime(character, recipe) * max(0, 1-getBonusValue(character, productionSpeedBonus)/100)
+}
+
+// getBonusValue returns the sum of the values, in percent, of the character's bonuses of the type
+func getBonusValue(character *data.Character[map[string]data.PlayerTradersInfo], bonusType string) float64 {
+	var value float64
+	for _, bonus := range character.Bonuses {
+		if bonus.Type == bonusType {
+			value += float64(bonus.Value)
+		}
+	}
+	return value
+}
+
+func getBaseProductionTime(character *data.Character[map[string]data.PlayerTradersInfo], recipe *data.HideoutRecipe) float64 {
+	if recipe.AreaType == bitcoinFarmArea {
+		area := getCharacterHideoutArea(character, bitcoinFarmArea)
+		if area == nil {
+			return 0
+		}
+
+		gpus := 0
+		for _, slot := range area.Slots {
+			if slot, ok := slot.(map[string]any); ok && slot["item"] != nil {
+				gpus++
+			}
+		}
+		if gpus == 0 {
+			return 0
+		}
+
+		settings, err := data.GetHideoutSettings()
+		if err != nil {
+			return recipe.ProductionTime
+		}
+		return recipe.ProductionTime / (1 + float64(gpus-1)*settings.GPUBoostRate)
+	}
+
+	reduction := data.GetGlobals().Config.SkillsSettings.Crafting.ProductionTimeReductionPerLevel
+	level := getCharacterSkillLevel(character, craftingSkill)
+	return recipe.ProductionTime * max(0, 1-float64(level)*reduction/100)
+}
+
+// getProductionSpeed returns how fast the production runs; areas that need fuel slow down while the generator is off
+func getProductionSpeed(character *data.Character[map[string]data.PlayerTradersInfo], rid string) float64 {
+	if data.IsScavCaseRecipe(rid) {
+		return 1
+	}
+
+	recipe, err := data.GetHideoutRecipe(rid)
+	if err != nil {
+		return 1
+	}
+
+	area := data.GetHideoutAreaByAreaType(recipe.AreaType)
+	if area == nil {
+		return 1
+	}
+	if needsFuel, _ := (*area)["needsFuel"].(bool); !needsFuel {
+		return 1
+	}
+
+	if generator := getCharacterHideoutArea(character, generatorArea); generator != nil && generator.Active {
+		return 1
+	}
+
+	settings, err := data.GetHideoutSettings()
+	if err != nil {
+		return 1
+	}
+	return settings.GeneratorSpeedWithoutFuel
+}
+
+// takeProductionRequirements removes the item requirements from the inventory, drawing Resource requirements
+// from the resource of the items instead of their count
+func takeProductionRequirements(character *data.Character[map[string]data.PlayerTradersInfo], requirements []data.HideoutRequirement, items []tradingScheme, changes *data.ProfileChanges) error {
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		return err
+	}
+
+	remaining := getResourceRequirements(requirements)
+	counted := make([]tradingScheme, 0, len(items))
+	for _, scheme := range items {
+		index := invCache.GetIndexOfItemByID(scheme.ID)
+		if index == nil {
+			return fmt.Errorf(itemNotInInventory, scheme.ID)
+		}
+		item := &character.Inventory.Items[*index]
+
+		need, ok := remaining[item.TPL]
+		if !ok {
+			counted = append(counted, scheme)
+			continue
+		}
+
+		left := getItemResource(item)
+		taken := min(need, left)
+		if taken <= 0 {
+			continue
+		}
+		remaining[item.TPL] -= taken
+		if taken >= left {
+			counted = append(counted, tradingScheme{ID: scheme.ID, Count: 1})
+			continue
+		}
+
+		if item.UPD == nil {
+			item.UPD = new(data.ItemUpdate)
+		}
+		if item.UPD.Resource == nil {
+			item.UPD.Resource = new(data.Resource)
+		}
+		item.UPD.Resource.Value = int16(left - taken)
+		changes.Items.Change = append(changes.Items.Change, *item)
+	}
+
+	return RemoveItemCountsFromInventory(character, counted, changes)
+}
+
+// getResourceRequirements returns the resource each Resource requirement takes, keyed by its item
+func getResourceRequirements(requirements []data.HideoutRequirement) map[string]int32 {
+	resources := make(map[string]int32)
+	for _, requirement := range requirements {
+		if requirement.Type == "Resource" {
+			resources[requirement.TemplateID] += requirement.Resource
+		}
+	}
+	return resources
+}
+
+// getItemResource returns the resource the item has left, which is full until its UPD tracks it
+func getItemResource(item *data.InventoryItem) int32 {
+	if item.UPD != nil && item.UPD.Resource != nil {
+		return int32(item.UPD.Resource.Value)
+	}
+
+	dbItem, err := data.GetItemByID(item.TPL)
+	if err != nil {
+		return 0
+	}
+	for _, key := range []string{"MaxResource", "Resource"} {
+		if value, ok := dbItem.Props[key].(float64); ok && value > 0 {
+			return int32(value)
+		}
+	}
+	return 0
+}
+
+// createProductionItems creates count of the product, split into stacks, marked as found in raid
+func createProductionItems(tpl string, count int32) []data.InventoryItem {
+	item, err := data.GetItemByID(tpl)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	stackMaxSize := max(item.GetStackMaxSize(), 1)
+	output := make([]data.InventoryItem, 0)
+	for _, stack := range GetCorrectAmountOfItemsPurchased(count, stackMaxSize) {
+		product := data.CreateNewItem(tpl, "")
+		if upd, err := item.CreateItemUPD(); err == nil && upd != nil {
+			product.UPD = upd
+		} else {
+			product.UPD = new(data.ItemUpdate)
+		}
+		product.UPD.SpawnedInSession = true
+		if stackMaxSize > 1 {
+			product.UPD.StackObjectsCount = stack
+		}
+		output = append(output, *product)
+	}
+	return output
+}
+
+// rollScavCaseProducts picks random items of each rarity, as many as the recipe's range for that rarity allows
+func rollScavCaseProducts(recipe *data.ScavCaseRecipe) []data.InventoryItem {
+	pools := data.GetScavCaseItemPools()
+
+	rarities := make([]string, 0, len(recipe.EndProducts))
+	for rarity := range recipe.EndProducts {
+		rarities = append(rarities, rarity)
+	}
+	slices.Sort(rarities)
+
+	output := make([]data.InventoryItem, 0)
+	for _, rarity := range rarities {
+		pool := pools[rarity]
+		if len(pool) == 0 {
+			continue
+		}
+
+		bounds := recipe.EndProducts[rarity]
+		minimum, _ := strconv.Atoi(bounds.Min)
+		maximum, _ := strconv.Atoi(bounds.Max)
+		if maximum < minimum {
+			continue
+		}
+
+		amount := minimum + rand.Intn(maximum-minimum+1)
+		for range amount {
+			output = append(output, createProductionItems(pool[rand.Intn(len(pool))], 1)...)
+		}
+	}
+	return output
+}
+
+// #endregion
+
+// #region Hideout notifications
+
+const (
+	hideoutNotifyTick         = 30 * time.Second
+	hideoutAreaComplete       = "Construction of a hideout area is complete"
+	hideoutProductionComplete = "A hideout production is complete"
+)
+
+// hideoutNotified holds the completions already sent, so each is sent once; only the notify job touches it
+var hideoutNotified = make(map[string]struct{})
+
+// StartHideoutNotifications periodically tells players, through the mail, that their hideout constructions and
+// crafts are complete
+func StartHideoutNotifications() {
+	startJob(hideoutNotifyTick, func() {
+		pending := make(map[string]struct{}, len(hideoutNotified))
+		data.GetProfiles().ForEach(func(id string, profile *data.Profile) bool {
+			profile.Lock()
+			defer profile.Unlock()
+
+			if profile.Character != nil && profile.Character.Hideout != nil && profile.Dialogue != nil {
+				notifyHideoutCompletions(id, profile.Character, pending)
+			}
+			return true
+		})
+		hideoutNotified = pending
+	})
+}
+
+// notifyHideoutCompletions mails the player for every completion not sent yet, adding each completion still
+// waiting on the player to pending
+func notifyHideoutCompletions(sessionID string, character *data.Character[map[string]data.PlayerTradersInfo], pending map[string]struct{}) {
+	now := int(tools.GetCurrentTimeInSeconds())
+	for _, area := range character.Hideout.Areas {
+		if area.Constructing && area.CompleteTime != 0 && now >= area.CompleteTime {
+			key := fmt.Sprintf("%s:area:%d:%d", sessionID, area.Type, area.CompleteTime)
+			notifyHideoutCompletion(sessionID, key, hideoutAreaComplete, pending)
+		}
+	}
+
+	UpdateHideoutProductions(character)
+	for rid, production := range character.Hideout.Production {
+		if production == nil || !production.InProgress || production.ProductionTime <= 0 {
+			continue
+		}
+		if recipe, _ := getContinuousRecipe(rid); recipe != nil || production.Progress < production.ProductionTime {
+			continue
+		}
+
+		key := fmt.Sprintf("%s:production:%s:%s", sessionID, rid, production.StartTimestamp)
+		notifyHideoutCompletion(sessionID, key, hideoutProductionComplete, pending)
+	}
+}
+
+func notifyHideoutCompletion(sessionID string, key string, text string, pending map[string]struct{}) {
+	pending[key] = struct{}{}
+	if _, ok := hideoutNotified[key]; ok {
+		return
+	}
+
+	message := data.CreateMessageWithItems(systemSenderID, "System", "", nil, 0)
+	message.Text = text
+	if err := SendMailToPlayer(sessionID, systemSenderID, "System", message); err != nil {
+		log.Println(err)
+		delete(pending, key)
+	}
+}
+
+// #endregion
@@ -0,0 +1,287 @@
+package pkg
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"mtgo/data"
+	"mtgo/tools"
+	"slices"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	insuranceReturnTick          = time.Minute
+	defaultInsuranceReturnChance = 80
+	laboratoryLocation           = "laboratory"
+)
+
+const (
+	itemAlreadyInsured  string = "Item %s is already insured\n"
+	itemNotInsurable    string = "Item %s can not be insured by %s\n"
+	traderNoInsurance   string = "Trader %s does not offer insurance"
+	noInsuranceDialogue string = "Trader %s has no %s dialogue"
+)
+
+type insure struct {
+	Action string
+	TID    string   `json:"tid"`
+	Items  []string `json:"items"`
+}
+
+// Insure charges the insurance cost of every item in roubles and insures them with the trader
+func Insure(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	insurance := new(insure)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &insurance); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	trader, err := data.GetTraderByUID(insurance.TID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if !trader.Base.Insurance.Availability {
+		log.Println(fmt.Errorf(traderNoInsurance, insurance.TID))
+		return
+	}
+
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	items := make([]string, 0, len(insurance.Items))
+	for _, id := range insurance.Items {
+		index := invCache.GetIndexOfItemByID(id)
+		if index == nil {
+			log.Println(fmt.Errorf(itemNotInInventory, id))
+			continue
+		}
+
+		if slices.ContainsFunc(character.InsuredItems, func(insured data.InsuredItem) bool {
+			return insured.ItemID == id
+		}) {
+			log.Printf(itemAlreadyInsured, id)
+			continue
+		}
+
+		item, err := data.GetItemByID(character.Inventory.Items[*index].TPL)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if item.IsChildOf(trader.Base.Insurance.ExcludedCategory) {
+			log.Printf(itemNotInsurable, id, insurance.TID)
+			continue
+		}
+		items = append(items, id)
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	costs, err := GetInsuranceCosts(sessionID, []string{insurance.TID}, items)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	var total int32
+	for _, id := range items {
+		total += costs[insurance.TID][character.Inventory.Items[*invCache.GetIndexOfItemByID(id)].TPL]
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	if total > 0 {
+		if err := RemoveCurrencyFromInventory(character, roublesTPL, total, changes); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	for _, id := range items {
+		character.InsuredItems = append(character.InsuredItems, data.InsuredItem{Tid: insurance.TID, ItemID: id})
+	}
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+// storeInsuranceReturns takes the insurance off every insured item lost in raid, deciding which of them
+// each trader returns and storing the returns until they are due
+func storeInsuranceReturns(character *data.Character[map[string]data.PlayerTradersInfo], raidItems []data.InventoryItem, kept map[string]struct{}) {
+	equipment := character.Inventory.Equipment
+	lost := make(map[string]data.InventoryItem)
+	for _, items := range [][]data.InventoryItem{character.Inventory.Items, raidItems} {
+		family := data.GetInventoryItemFamilyTreeIDs(items, equipment)
+		for _, item := range items {
+			if item.ID == equipment || !slices.Contains(family, item.ID) {
+				continue
+			}
+			if _, ok := kept[item.ID]; !ok {
+				lost[item.ID] = item
+			}
+		}
+	}
+
+	lostByTrader := make(map[string][]data.InventoryItem)
+	character.InsuredItems = slices.DeleteFunc(character.InsuredItems, func(insured data.InsuredItem) bool {
+		item, ok := lost[insured.ItemID]
+		if ok {
+			lostByTrader[insured.Tid] = append(lostByTrader[insured.Tid], item)
+		}
+		return ok
+	})
+	if len(lostByTrader) == 0 {
+		return
+	}
+
+	storage, err := data.GetStorageByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	location := data.GetPlayerMap(character.ID)
+	now := tools.GetCurrentTimeInSeconds()
+	for tid, items := range lostByTrader {
+		trader, err := data.GetTraderByUID(tid)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		insurance := trader.Base.Insurance
+
+		hours := tools.GetRandomInt(int(insurance.MinReturnHour), int(insurance.MaxReturnHour))
+		storage.Insurance = append(storage.Insurance, &data.InsurancePackage{
+			TraderID:   tid,
+			ReturnTime: now + int64(hours*3600),
+			Location:   location,
+			Items:      rollInsuranceReturns(items, insurance.ReturnChance),
+		})
+
+		if err := sendInsuranceMessage(character.ID, trader, "insuranceStart", nil); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if err := storage.SaveStorage(character.ID); err != nil {
+		log.Println(err)
+	}
+}
+
+// rollInsuranceReturns returns the items that were not looted, detaching any whose parent was
+func rollInsuranceReturns(items []data.InventoryItem, returnChance int8) []data.InventoryItem {
+	chance := int(returnChance)
+	if chance == 0 {
+		chance = defaultInsuranceReturnChance
+	}
+
+	returned := make([]data.InventoryItem, 0, len(items))
+	for _, item := range items {
+		if rand.Intn(100) < chance {
+			returned = append(returned, item)
+		}
+	}
+
+	for idx, item := range returned {
+		if !slices.ContainsFunc(returned, func(parent data.InventoryItem) bool { return parent.ID == item.ParentID }) {
+			returned[idx].ParentID = ""
+			returned[idx].SlotID = ""
+			returned[idx].Location = nil
+		}
+		if returned[idx].UPD != nil {
+			returned[idx].UPD.SpawnedInSession = false
+		}
+	}
+	return returned
+}
+
+// StartInsuranceReturns periodically mails players the insurance returns that are due
+func StartInsuranceReturns() {
+	startJob(insuranceReturnTick, func() {
+		data.GetProfiles().ForEach(func(id string, profile *data.Profile) bool {
+			profile.Lock()
+			defer profile.Unlock()
+
+			if profile.Storage != nil && len(profile.Storage.Insurance) != 0 {
+				sendInsuranceReturns(id, profile.Storage)
+			}
+			return true
+		})
+	})
+}
+
+func sendInsuranceReturns(sessionID string, storage *data.Storage) {
+	now := tools.GetCurrentTimeInSeconds()
+	pending := make([]*data.InsurancePackage, 0, len(storage.Insurance))
+	for _, insurance := range storage.Insurance {
+		if insurance.ReturnTime > now {
+			pending = append(pending, insurance)
+			continue
+		}
+
+		trader, err := data.GetTraderByUID(insurance.TraderID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		dialogue := "insuranceFound"
+		switch {
+		case insurance.Location == laboratoryLocation:
+			dialogue = "insuranceFailedLabs"
+			insurance.Items = nil
+		case len(insurance.Items) == 0:
+			dialogue = "insuranceFailed"
+		}
+
+		if err := sendInsuranceMessage(sessionID, trader, dialogue, insurance.Items); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if len(pending) == len(storage.Insurance) {
+		return
+	}
+
+	storage.Insurance = pending
+	if err := storage.SaveStorage(sessionID); err != nil {
+		log.Println(err)
+	}
+}
+
+// sendInsuranceMessage mails a random message of the trader's dialogue with the items attached, which can be
+// redeemed for as long as the trader stores them
+func sendInsuranceMessage(sessionID string, trader *data.Trader, dialogue string, items []data.InventoryItem) error {
+	var templates []string
+	if trader.Dialogue != nil {
+		templates, _ = trader.Dialogue.Get(dialogue)
+	}
+	if len(templates) == 0 {
+		return fmt.Errorf(noInsuranceDialogue, trader.Base.ID, dialogue)
+	}
+
+	storageTime := trader.Base.Insurance.MaxStorageTime * 3600
+	message := data.CreateMessageWithItems(trader.Base.ID, "Insurance", templates[rand.Intn(len(templates))], items, storageTime)
+	return SendMailToPlayer(sessionID, trader.Base.ID, "Trader", message)
+}
@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+var jobs = struct {
+	wg   sync.WaitGroup
+	stop chan struct{}
+	once sync.Once
+}{stop: make(chan struct{})}
+
+// startJob runs the job every tick until StopBackgroundJobs is called
+func startJob(tick time.Duration, job func()) {
+	jobs.wg.Add(1)
+	go func() {
+		defer jobs.wg.Done()
+
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobs.stop:
+				return
+			case <-ticker.C:
+				job()
+			}
+		}
+	}()
+}
+
+// StopBackgroundJobs stops every periodic job, waiting for the ones running to finish
+func StopBackgroundJobs() {
+	jobs.once.Do(func() { close(jobs.stop) })
+	jobs.wg.Wait()
+}
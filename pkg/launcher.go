@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"mtgo/data"
+	"net/http"
+
+	"github.com/goccy/go-json"
+)
+
+const launcherConfig = "{'BackendUrl':'%s','Version':'live'}"
+
+var errInvalidCredentials = errors.New("invalid username or password")
+
+type launcherAccount struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	NewPassword string `json:"newPassword"`
+	Edition     string `json:"edition"`
+	Lang        string `json:"lang"`
+}
+
+type LauncherConnect struct {
+	Name       string   `json:"name"`
+	BackendURL string   `json:"backendUrl"`
+	Config     string   `json:"config"`
+	Editions   []string `json:"editions"`
+}
+
+type LauncherLogin struct {
+	UID     string `json:"uid"`
+	Token   string `json:"token"`
+	Edition string `json:"edition"`
+	Wipe    bool   `json:"wipe"`
+}
+
+// ApplyLauncherErrorBody returns a ResponseBody carrying the error for launchers to show
+func ApplyLauncherErrorBody(err error) *ResponseBody {
+	return ApplyErrorBody(err)
+}
+
+// GetLauncherConnect returns what launchers need to connect a game client to the server
+func GetLauncherConnect() *LauncherConnect {
+	return &LauncherConnect{
+		Name:       data.GetServerConfig().Name,
+		BackendURL: data.GetMainAddress(),
+		Config:     fmt.Sprintf(launcherConfig, data.GetMainAddress()),
+		Editions:   data.GetEditionNames(),
+	}
+}
+
+// RegisterLauncherAccount registers the account and logs it in
+func RegisterLauncherAccount(r *http.Request) (*LauncherLogin, error) {
+	request, err := getLauncherAccount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := data.RegisterAccount(request.Username, request.Password, request.Edition, request.Lang); err != nil {
+		return nil, err
+	}
+	return LoginLauncherAccount(r)
+}
+
+// LoginLauncherAccount checks the account's credentials and issues a session token for the game client
+func LoginLauncherAccount(r *http.Request) (*LauncherLogin, error) {
+	account, err := authenticateLauncherAccount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := data.CreateSession(account.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LauncherLogin{
+		UID:     account.UID,
+		Token:   token,
+		Edition: account.Edition,
+		Wipe:    account.Wipe,
+	}, nil
+}
+
+// ChangeLauncherPassword replaces the account's password
+func ChangeLauncherPassword(r *http.Request) error {
+	account, err := authenticateLauncherAccount(r)
+	if err != nil {
+		return err
+	}
+
+	request, err := getLauncherAccount(r)
+	if err != nil {
+		return err
+	}
+	if request.NewPassword == "" {
+		return errors.New("new password can not be empty")
+	}
+
+	profile, err := data.GetProfileByUID(account.UID)
+	if err != nil {
+		return err
+	}
+
+	profile.Lock()
+	defer profile.Unlock()
+	if err := account.SetPassword(request.NewPassword); err != nil {
+		return err
+	}
+	if err := account.SaveAccount(); err != nil {
+		return err
+	}
+
+	data.DeleteProfileSessions(account.UID)
+	return nil
+}
+
+// ChangeLauncherEdition switches the account to another edition, wiping the profile so the next character starts
+// with the new edition's stash
+func ChangeLauncherEdition(r *http.Request) error {
+	account, err := authenticateLauncherAccount(r)
+	if err != nil {
+		return err
+	}
+
+	request, err := getLauncherAccount(r)
+	if err != nil {
+		return err
+	}
+	if _, err := data.GetEditionByName(request.Edition); err != nil {
+		return err
+	}
+
+	return account.ChangeEdition(request.Edition)
+}
+
+// WipeLauncherAccount wipes the account's profile
+func WipeLauncherAccount(r *http.Request) error {
+	account, err := authenticateLauncherAccount(r)
+	if err != nil {
+		return err
+	}
+	return account.WipeProfile()
+}
+
+func getLauncherAccount(r *http.Request) (*launcherAccount, error) {
+	request := new(launcherAccount)
+	input, err := json.MarshalNoEscape(GetParsedBody(r))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.UnmarshalNoEscape(input, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func authenticateLauncherAccount(r *http.Request) (*data.Account, error) {
+	request, err := getLauncherAccount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := data.GetAccountByUsername(request.Username)
+	if err != nil || !account.CheckPassword(request.Password) {
+		return nil, errInvalidCredentials
+	}
+	return account, nil
+}
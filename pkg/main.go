@@ -6,6 +6,7 @@ import (
 	"math"
 	"mtgo/data"
 	"mtgo/tools"
+	"net/url"
 	"strings"
 )
 
@@ -76,6 +77,8 @@ func GetMainProfileList(sessionID string) []any {
 		return profiles
 	}
 
+	UpdateHideoutProductions(character)
+
 	playerScav := data.GetPlayerScav()
 	playerScav.Info.RegistrationDate = int32(tools.GetCurrentTimeInSeconds())
 	playerScav.AID = character.AID
@@ -248,11 +251,13 @@ func SetChannelTemplate() {
 	channel.Template.Notifier.Server = data.GetLobbyIPandPort()
 }
 
-func GetChannel(sessionID string) data.Channel {
+// GetChannel creates the notifier channel of the session, whose websocket URL carries the session token as the
+// client does not send its cookie on the upgrade
+func GetChannel(sessionID string, token string) data.Channel {
 	channel := data.GetChannelsTemplate()
 	channel.Notifier.ChannelID = sessionID
 	channel.Notifier.NotifierServer = fmt.Sprintf(notiFormat, data.GetLobbyIPandPort(), sessionID)
-	channel.Notifier.WS = fmt.Sprintf(wssFormat, data.GetWebSocketAddress(), sessionID)
+	channel.Notifier.WS = fmt.Sprintf(wssFormat, data.GetWebSocketAddress(), sessionID, url.QueryEscape(token))
 	channel.SetChannel(sessionID)
 
 	return channel
@@ -317,7 +322,7 @@ func GetQuestList(sessionID string) ([]any, error) {
 
 func GetMainPrices() *data.SupplyData {
 	supplyData := data.GetSupplyData()
-	supplyData.SupplyNextTime = data.SetResupplyTimer()
+	supplyData.SupplyNextTime = data.GetNextResupply()
 	return supplyData
 }
 
@@ -381,10 +386,8 @@ func GetInsuranceCosts(sessionID string, traders []string, items []string) (map[
 			}
 
 			itemPrice, _ := data.GetPriceByID(itemTPL)
-			insuranceCost := int32(math.Round(float64(itemPrice) * 0.3))
-			if traderInsurance.PriceCoef > 0 {
-				insuranceCost *= int32(1 - traderInsurance.PriceCoef/100)
-			}
+			insuranceCost := int32(math.Round(float64(itemPrice) * 0.3 * (1 - float64(traderInsurance.PriceCoef)/100)))
+			insuranceCost = max(insuranceCost, int32(trader.Base.Insurance.MinPayment))
 
 			if item != insuranceCost {
 				item = insuranceCost
@@ -402,7 +405,7 @@ const (
 	storageBuildNotExist    = "Storage builds for %s does not exist"
 	channelNotifierNotExist = "Channel.Notifier for %s does not exist"
 	notiFormat              = "%s/push/notifier/get/%s"
-	wssFormat               = "%s/push/notifier/getwebsocket/%s"
+	wssFormat               = "%s/push/notifier/getwebsocket/%s?token=%s"
 )
 
 type ProfileStatuses struct {
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log"
 	"mtgo/data"
+	"mtgo/tools"
 	"net/http"
+	"time"
 
 	"github.com/goccy/go-json"
 )
@@ -12,23 +14,34 @@ import (
 const systemSenderID string = "59e7125688a45068a6249071"
 const itemsMaxStorageLifetimeSeconds int = 3600
 
-func SendQueuedMessagesToPlayer(sessionID string) error {
-	storage, err := data.GetStorageByID(sessionID)
+// SendMailToPlayer adds the message to the dialog of dialogID, creating it if it does not exist, and notifies the player
+func SendMailToPlayer(sessionID string, dialogID string, dialogType string, message *data.DialogMessage) error {
+	dialogues, err := data.GetDialogueByID(sessionID)
 	if err != nil {
 		return err
 	}
 
-	connection := data.GetConnection(sessionID)
-	for _, v := range storage.Mailbox {
-		if err := connection.SendMessage(v); err != nil {
-			return err
+	dialog, ok := (*dialogues)[dialogID]
+	if !ok {
+		dialog = &data.Dialog{
+			ID:       dialogID,
+			Type:     data.GetMessageType(dialogType),
+			Messages: make([]data.DialogMessage, 0),
 		}
+		(*dialogues)[dialogID] = dialog
 	}
-	storage.Mailbox = storage.Mailbox[:0]
-	if err := storage.SaveStorage(sessionID); err != nil {
+
+	dialog.Messages = append(dialog.Messages, *message)
+	dialog.New++
+	if message.HasRewards {
+		dialog.AttachmentsNew++
+	}
+
+	if err := dialogues.SaveDialogue(sessionID); err != nil {
 		return err
 	}
-	return nil
+
+	return SendNotificationToPlayer(sessionID, data.CreateNotification(message))
 }
 
 func GetFriendsList(r *http.Request) (*FriendsList, error) {
@@ -141,7 +154,7 @@ func GetMailDialogView(r *http.Request) (*data.DialogMessageView, error) {
 	if !ok {
 		log.Println("Dialogue does not exist, check ID:", request.DialogID, ".")
 		output.Messages = make([]data.DialogMessage, 0)
-		output.Profiles = make([]data.DialogUserInfo, 0)
+		output.Profiles = make([]data.DialogUser, 0)
 		output.HasMessageWithRewards = false
 
 		return output, nil
@@ -150,23 +163,18 @@ func GetMailDialogView(r *http.Request) (*data.DialogMessageView, error) {
 	switch request.Type {
 	case 2:
 		output.Messages = dialog.Messages
-		output.Profiles = make([]data.DialogUserInfo, 0)
+		output.Profiles = make([]data.DialogUser, 0)
 		output.HasMessageWithRewards = dialog.HasMessagesWithRewards()
-	case 1:
-	case 6:
-		log.Println("WE HAVENT GOTTEN HERE YET BUDDY")
-
+	case 1, 6:
 		output.Messages = dialog.Messages
-		//TODO: handle profiles
-		output.Profiles = make([]data.DialogUserInfo, 0)
+		output.Profiles = append(make([]data.DialogUser, 0, len(dialog.Users)), dialog.Users...)
 		output.HasMessageWithRewards = dialog.HasMessagesWithRewards()
 	default:
 		log.Println("Request Type:", request.Type, "unsupported at the moment!")
 	}
 
 	if dialog.New != 0 {
-		dialog.New = 0
-		dialog.AttachmentsNew = dialog.GetUnreadMessagesWithAttachments()
+		dialog.MarkAsRead()
 		if err := dialogues.SaveDialogue(sessionID); err != nil {
 			return nil, err
 		}
@@ -245,15 +253,174 @@ func RemoveMailDialog(r *http.Request) error {
 	return nil
 }
 
+const (
+	mailExpiryTick   = 10 * time.Minute
+	stashFullWarning = "Not enough space in stash, the remaining attachments were left in the mail"
+)
+
+// CollectMailAttachments moves the uncollected attachments of the dialog into the stash, leaving the
+// attachments of every message that does not fit in the mail and warning the player about it; the new stash
+// items are returned as the view's profile changes
+func CollectMailAttachments(r *http.Request) (*data.DialogMessageView, error) {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return nil, err
+	}
+	dialogID, _ := GetParsedBody(r).(map[string]any)["dialogId"].(string)
+
+	dialogues, err := data.GetDialogueByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	dialog, ok := (*dialogues)[dialogID]
+	if !ok {
+		return nil, fmt.Errorf(dialogNotExist, dialogID)
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &data.DialogMessageView{
+		Messages: make([]data.DialogMessage, 0),
+		Profiles: make([]data.DialogUser, 0),
+	}
+
+	changes := &data.ProfileChanges{ID: character.ID}
+	now := int32(tools.GetCurrentTimeInSeconds())
+	stashFull := false
+	for idx, message := range dialog.Messages {
+		if !message.HasRewards || message.RewardCollected || message.Items == nil || message.DT+message.MaxStorageTime <= now {
+			continue
+		}
+
+		if err := AddItemsToInventory(character, message.Items.Data, changes); err != nil {
+			log.Println(err)
+			stashFull = true
+			output.HasMessageWithRewards = true
+			continue
+		}
+
+		dialog.Messages[idx].RewardCollected = true
+		output.Messages = append(output.Messages, dialog.Messages[idx])
+	}
+
+	if len(output.Messages) != 0 {
+		output.Changes = changes
+		dialog.AttachmentsNew = dialog.GetUnreadMessagesWithAttachments()
+		if err := character.SaveCharacter(); err != nil {
+			return nil, err
+		}
+		if err := dialogues.SaveDialogue(sessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	if stashFull {
+		message := data.CreateMessageWithItems(systemSenderID, "System", "", nil, 0)
+		message.Text = stashFullWarning
+		if err := SendMailToPlayer(sessionID, systemSenderID, "System", message); err != nil {
+			return nil, err
+		}
+	}
+
+	return output, nil
+}
+
+// ReadMailDialogs marks every message of the dialogs as read
+func ReadMailDialogs(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	dialogIDs, _ := GetParsedBody(r).(map[string]any)["dialogs"].([]any)
+
+	dialogues, err := data.GetDialogueByID(sessionID)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range dialogIDs {
+		dialogID, _ := id.(string)
+		dialog, ok := (*dialogues)[dialogID]
+		if !ok {
+			log.Println(fmt.Errorf(dialogNotExist, dialogID))
+			continue
+		}
+		dialog.MarkAsRead()
+	}
+
+	return dialogues.SaveDialogue(sessionID)
+}
+
+// ClearMailDialog removes every message of the dialog, along with their attachments
+func ClearMailDialog(r *http.Request) error {
+	sessionID, err := GetSessionID(r)
+	if err != nil {
+		return err
+	}
+	dialogID, _ := GetParsedBody(r).(map[string]any)["dialogId"].(string)
+
+	dialogues, err := data.GetDialogueByID(sessionID)
+	if err != nil {
+		return err
+	}
+
+	dialog, ok := (*dialogues)[dialogID]
+	if !ok {
+		return fmt.Errorf(dialogNotExist, dialogID)
+	}
+
+	dialog.Messages = dialog.Messages[:0]
+	dialog.New = 0
+	dialog.AttachmentsNew = 0
+	return dialogues.SaveDialogue(sessionID)
+}
+
+// StartMailExpiry periodically removes the expired messages and attachments from every player's mail
+func StartMailExpiry() {
+	startJob(mailExpiryTick, func() {
+		storageTime := data.GetMessageStorageTime()
+		data.GetProfiles().ForEach(func(id string, profile *data.Profile) bool {
+			profile.Lock()
+			defer profile.Unlock()
+
+			if profile.Dialogue == nil {
+				return true
+			}
+
+			expired := false
+			for dialogID, dialog := range *profile.Dialogue {
+				if !dialog.RemoveExpired(storageTime) {
+					continue
+				}
+				expired = true
+				if len(dialog.Messages) == 0 && !dialog.Pinned {
+					delete(*profile.Dialogue, dialogID)
+				}
+			}
+
+			if expired {
+				if err := profile.Dialogue.SaveDialogue(id); err != nil {
+					log.Println(err)
+				}
+			}
+			return true
+		})
+	})
+}
+
 type FriendsList struct {
-	Friends      []data.FriendRequest
+	Friends      []data.FriendRequestProfile
 	Ignore       []string
 	InIgnoreList []string
 }
 
 type FriendRequestMailbox struct {
-	Err  int   `json:"err"`
-	Data []any `json:"data"`
+	Err  int                  `json:"err"`
+	Data []data.FriendRequest `json:"data"`
 }
 
 type DialogView struct {
@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"log"
+	"mtgo/data"
+	"time"
+)
+
+const longPollTimeout = 20 * time.Second
+
+// SendNotificationToPlayer queues the notification on the player's websocket, or stores it in their mailbox to
+// be sent when they reconnect or poll the notifier
+func SendNotificationToPlayer(sessionID string, notification *data.Notification) error {
+	connection := data.GetConnection(sessionID)
+	if connection != nil {
+		err := connection.SendMessage(notification)
+		if err == nil {
+			return nil
+		}
+		log.Println(err)
+	}
+
+	if err := data.AddToMailbox(sessionID, notification); err != nil {
+		return err
+	}
+
+	data.SignalNotifier(sessionID)
+	return nil
+}
+
+// BroadcastNotification sends the notification to every signed in player; players without a websocket keep only
+// the latest notification of its type in their mailbox
+func BroadcastNotification(notification *data.Notification) {
+	data.GetProfiles().ForEach(func(id string, profile *data.Profile) bool {
+		if profile.Storage == nil || !data.HasSession(id) {
+			return true
+		}
+
+		if connection := data.GetConnection(id); connection != nil {
+			err := connection.SendMessage(notification)
+			if err == nil {
+				return true
+			}
+			log.Println(err)
+		}
+
+		if err := data.ReplaceInMailbox(id, notification); err != nil {
+			log.Println(err)
+			return true
+		}
+		data.SignalNotifier(id)
+		return true
+	})
+}
+
+// SendQueuedMessagesToPlayer flushes the player's mailbox to their websocket, keeping whatever could not be sent
+func SendQueuedMessagesToPlayer(sessionID string) error {
+	connection := data.GetConnection(sessionID)
+	if connection == nil {
+		return nil
+	}
+
+	notifications, err := data.TakeMailbox(sessionID)
+	if err != nil || len(notifications) == 0 {
+		return err
+	}
+
+	for idx, notification := range notifications {
+		if err := connection.SendMessage(notification); err != nil {
+			log.Println(err)
+			return data.ReturnToMailbox(sessionID, notifications[idx:])
+		}
+	}
+	return nil
+}
+
+// GetPendingNotifications returns the player's queued notifications for the long-poll notifier, waiting for one
+// to arrive if there are none
+func GetPendingNotifications(sessionID string) ([]*data.Notification, error) {
+	notifications, err := data.TakeMailbox(sessionID)
+	if err != nil || len(notifications) != 0 {
+		return notifications, err
+	}
+
+	if !data.WaitForNotifier(sessionID, longPollTimeout) {
+		return make([]*data.Notification, 0), nil
+	}
+	return data.TakeMailbox(sessionID)
+}
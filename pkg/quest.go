@@ -0,0 +1,653 @@
+package pkg
+
+import (
+	"log"
+	"mtgo/data"
+	"mtgo/tools"
+	"slices"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	questNotFound          string = "Quest %s does not exist\n"
+	questNotStarted        string = "Quest %s has not been started\n"
+	questNotAvailableYet   string = "Quest %s can not be accepted for another %d seconds\n"
+	conditionNotHandover   string = "Condition %s of quest %s does not take items\n"
+	conditionsIncomplete   string = "Quest %s can not be completed, condition %s is not done\n"
+	itemNotForCondition    string = "Item %s can not be handed over for condition %s\n"
+	traderNotInTradersInfo string = "Trader %s does not exist in TradersInfo\n"
+)
+
+type questHandover struct {
+	Action      string
+	QID         string          `json:"qid"`
+	ConditionID string          `json:"conditionId"`
+	Items       []tradingScheme `json:"items"`
+}
+
+// QuestHandover takes the items handed over for a HandoverItem or FindItem condition, completing the
+// condition once enough have been given
+func QuestHandover(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	handover := new(questHandover)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &handover); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	quest := getCharacterQuest(character, handover.QID)
+	if quest == nil || (quest.Status != "Started" && quest.Status != "AvailableForFinish") {
+		log.Printf(questNotStarted, handover.QID)
+		return
+	}
+
+	query := getQuestQuery(character, handover.QID)
+	if query == nil || query.Conditions.AvailableForFinish == nil {
+		return
+	}
+
+	condition, ok := query.Conditions.AvailableForFinish.HandoverItem[handover.ConditionID]
+	if !ok {
+		condition, ok = query.Conditions.AvailableForFinish.FindItem[handover.ConditionID]
+	}
+	if !ok {
+		log.Printf(conditionNotHandover, handover.ConditionID, handover.QID)
+		return
+	}
+
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	counter := getTaskConditionCounter(character, handover.ConditionID, handover.QID, "HandoverItem")
+	remaining := int32(condition.Amount) - int32(counter["value"].(float64))
+
+	toHandover := make([]tradingScheme, 0, len(handover.Items))
+	for _, scheme := range handover.Items {
+		if remaining <= 0 {
+			break
+		}
+
+		index := invCache.GetIndexOfItemByID(scheme.ID)
+		if index == nil {
+			return
+		}
+
+		item := character.Inventory.Items[*index]
+		if item.TPL != condition.ItemToHandover {
+			log.Printf(itemNotForCondition, scheme.ID, handover.ConditionID)
+			return
+		}
+		if condition.FoundInRaid && (item.UPD == nil || !item.UPD.SpawnedInSession) {
+			log.Printf(itemNotForCondition, scheme.ID, handover.ConditionID)
+			return
+		}
+
+		count := min(scheme.Count, remaining)
+		toHandover = append(toHandover, tradingScheme{ID: scheme.ID, Count: count})
+		remaining -= count
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	if err := RemoveItemCountsFromInventory(character, toHandover, changes); err != nil {
+		log.Println(err)
+		return
+	}
+
+	counter["value"] = condition.Amount - float64(remaining)
+	if remaining <= 0 && !slices.Contains(quest.CompletedConditions, handover.ConditionID) {
+		quest.CompletedConditions = append(quest.CompletedConditions, handover.ConditionID)
+	}
+
+	changes.QuestsStatus = append(changes.QuestsStatus, *quest)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+type questComplete struct {
+	Action            string
+	QID               string `json:"qid"`
+	RemoveExcessItems bool   `json:"removeExcessItems"`
+}
+
+// QuestComplete marks the quest as successful, gives its rewards through the mail and unlocks the quests
+// that depend on it
+func QuestComplete(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	complete := new(questComplete)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &complete); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	quest := getCharacterQuest(character, complete.QID)
+	if quest == nil || (quest.Status != "Started" && quest.Status != "AvailableForFinish") {
+		log.Printf(questNotStarted, complete.QID)
+		return
+	}
+
+	query := getQuestQuery(character, complete.QID)
+	if query == nil {
+		return
+	}
+
+	if id, done := isQuestReadyToFinish(character, quest, query.Conditions.AvailableForFinish); !done {
+		log.Printf(conditionsIncomplete, complete.QID, id)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	setQuestStatus(character, quest, data.Success)
+	finishQuest(character, query, data.Success, query.Rewards.Success, changes)
+	unlockDependentQuests(character, complete.QID, changes)
+
+	changes.QuestsStatus = append(changes.QuestsStatus, *quest)
+	if quests, err := data.GetQuestsAvailableToPlayer(*character); err == nil {
+		changes.Quests = quests
+	}
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+type questFail struct {
+	Action            string
+	QID               string `json:"qid"`
+	RemoveExcessItems bool   `json:"removeExcessItems"`
+}
+
+// QuestFail marks the quest as failed, giving any rewards for failing it through the mail
+func QuestFail(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	fail := new(questFail)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &fail); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	quest := getCharacterQuest(character, fail.QID)
+	if quest == nil || (quest.Status != "Started" && quest.Status != "AvailableForFinish") {
+		log.Printf(questNotStarted, fail.QID)
+		return
+	}
+
+	query := getQuestQuery(character, fail.QID)
+	if query == nil {
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	setQuestStatus(character, quest, data.Fail)
+	finishQuest(character, query, data.Fail, query.Rewards.Fail, changes)
+	unlockDependentQuests(character, fail.QID, changes)
+
+	changes.QuestsStatus = append(changes.QuestsStatus, *quest)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+// finishQuest applies the rewards of the quest and sends its success or fail message with the reward items
+func finishQuest(character *data.Character[map[string]data.PlayerTradersInfo], query *data.Query, status string, rewards *data.QuestRewards, changes *data.ProfileChanges) {
+	items := ApplyQuestRewardsToCharacter(character, rewards, changes)
+
+	templateID := query.Dialogue.Success
+	if status == data.Fail {
+		templateID = query.Dialogue.Fail
+	}
+
+	_, message := data.CreateQuestDialogue(character.ID, "Quest"+status, query.Trader, templateID)
+	message.AttachItems(items, data.GetMessageRedeemTime())
+	if err := SendMailToPlayer(character.ID, query.Trader, "Trader", message); err != nil {
+		log.Println(err)
+	}
+}
+
+// ApplyQuestRewardsToCharacter gives the character the experience, trader standing, trader unlocks, skills and
+// recipes of the rewards, returning the reward items to be sent to them
+func ApplyQuestRewardsToCharacter(character *data.Character[map[string]data.PlayerTradersInfo], rewards *data.QuestRewards, changes *data.ProfileChanges) []data.InventoryItem {
+	items := make([]data.InventoryItem, 0)
+	if rewards == nil {
+		return items
+	}
+
+	if rewards.Experience != 0 {
+		character.Info.Experience += int32(rewards.Experience)
+		if level := data.GetLevelByExperience(character.Info.Experience); level > character.Info.Level {
+			character.Info.Level = level
+		}
+		changes.Experience = character.Info.Experience
+	}
+
+	for tid, standing := range rewards.TraderStanding {
+		addTraderStanding(character, tid, standing, changes)
+	}
+	for tid, standing := range rewards.TraderStandingRestore {
+		addTraderStanding(character, tid, standing, changes)
+	}
+
+	if rewards.TraderUnlock != "" {
+		traderInfo := character.TradersInfo[rewards.TraderUnlock]
+		traderInfo.Unlocked = true
+		character.TradersInfo[rewards.TraderUnlock] = traderInfo
+		changes.TraderRelations[rewards.TraderUnlock] = traderInfo
+	}
+
+	for name, points := range rewards.Skills {
+		addSkillPoints(character, name, points)
+	}
+	if len(rewards.Skills) != 0 {
+		changes.Skills = character.Skills
+	}
+
+	// the entries unlocked are locked behind the quest, so drop the cached assorts of their traders to show them
+	if len(rewards.AssortmentUnlock) != 0 {
+		if cache, err := data.GetTraderCacheByID(character.ID); err == nil {
+			for _, unlock := range rewards.AssortmentUnlock {
+				cache.ResetAssort(unlock.TraderID)
+			}
+		}
+	}
+
+	for _, scheme := range rewards.ProductionScheme {
+		rid, err := data.GetHideoutRecipeIDByProduct(scheme.AreaID, scheme.Item)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if slices.Contains(character.UnlockedInfo.UnlockedProductionRecipe, any(rid)) {
+			continue
+		}
+		character.UnlockedInfo.UnlockedProductionRecipe = append(character.UnlockedInfo.UnlockedProductionRecipe, rid)
+
+		if changes.RecipeUnlocked == nil {
+			changes.RecipeUnlocked = &map[string]bool{}
+		}
+		(*changes.RecipeUnlocked)[rid] = true
+	}
+
+	for _, reward := range rewards.Items {
+		rewardItems, err := convertRewardItems(reward.Items, reward.FindInRaid)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		items = append(items, rewardItems...)
+	}
+
+	return items
+}
+
+// getQuestStartDelay returns how long after its previous quests are complete the quest becomes available
+func getQuestStartDelay(query *data.Query) int {
+	delay := 0
+	if forStart := query.Conditions.AvailableForStart; forStart != nil {
+		for _, condition := range forStart.Quest {
+			delay = max(delay, condition.AvailableAfter)
+		}
+	}
+	return delay
+}
+
+// unlockDependentQuests makes every quest waiting on the quest available, delayed by its AvailableAfter
+func unlockDependentQuests(character *data.Character[map[string]data.PlayerTradersInfo], qid string, changes *data.ProfileChanges) {
+	cachedQuests, err := data.GetQuestCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	now := int(tools.GetCurrentTimeInSeconds())
+	data.GetQuestsQuery().ForEach(func(key string, query *data.Query) bool {
+		if _, ok := cachedQuests.Index[key]; ok || data.CheckIfQuestForOtherFaction(character.Info.Side, key) {
+			return true
+		}
+
+		forStart := query.Conditions.AvailableForStart
+		if forStart == nil || forStart.Quest == nil {
+			return true
+		}
+
+		dependent := false
+		for _, condition := range forStart.Quest {
+			if condition.PreviousQuestID == qid {
+				dependent = true
+				break
+			}
+		}
+		if !dependent || !character.ArePreviousQuestsComplete(forStart.Quest, cachedQuests) {
+			return true
+		}
+
+		if forStart.Level != nil && !tools.LevelComparisonCheck(forStart.Level.Level, character.Info.Level, forStart.Level.CompareMethod) {
+			return true
+		}
+
+		quest := data.CharacterQuest{
+			QID:          key,
+			Status:       "AvailableForStart",
+			StatusTimers: map[string]int{},
+		}
+		if delay := getQuestStartDelay(query); delay > 0 {
+			quest.Status = "AvailableAfter"
+			quest.AvailableAfter = now + delay
+		}
+		quest.StatusTimers[quest.Status] = now
+
+		cachedQuests.Index[key] = int8(len(character.Quests))
+		character.Quests = append(character.Quests, quest)
+		changes.QuestsStatus = append(changes.QuestsStatus, quest)
+		return true
+	})
+}
+
+// convertRewardItems converts the items of a reward to inventory items with new IDs
+func convertRewardItems(rewardItems []map[string]any, foundInRaid bool) ([]data.InventoryItem, error) {
+	input, err := json.MarshalNoEscape(rewardItems)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]data.InventoryItem, 0, len(rewardItems))
+	if err := json.UnmarshalNoEscape(input, &items); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]string, len(items))
+	for _, item := range items {
+		ids[item.ID] = tools.GenerateMongoID()
+	}
+
+	for idx, item := range items {
+		items[idx].ID = ids[item.ID]
+		if parent, ok := ids[item.ParentID]; ok {
+			items[idx].ParentID = parent
+		}
+		if foundInRaid {
+			if items[idx].UPD == nil {
+				items[idx].UPD = new(data.ItemUpdate)
+			}
+			items[idx].UPD.SpawnedInSession = true
+		}
+	}
+	return items, nil
+}
+
+func addTraderStanding(character *data.Character[map[string]data.PlayerTradersInfo], tid string, standing float64, changes *data.ProfileChanges) {
+	traderInfo, ok := character.TradersInfo[tid]
+	if !ok {
+		log.Printf(traderNotInTradersInfo, tid)
+		return
+	}
+	traderInfo.Standing += float32(standing)
+	character.TradersInfo[tid] = traderInfo
+
+	if trader, err := data.GetTraderByUID(tid); err == nil {
+		trader.SetTraderLoyaltyLevel(character)
+	}
+	changes.TraderRelations[tid] = character.TradersInfo[tid]
+}
+
+func addSkillPoints(character *data.Character[map[string]data.PlayerTradersInfo], name string, points int) {
+	for idx, skill := range character.Skills.Common {
+		if skill.ID == name {
+			character.Skills.Common[idx].Progress += points
+			character.Skills.Common[idx].LastAccess = tools.GetCurrentTimeInSeconds()
+			return
+		}
+	}
+
+	character.Skills.Common = append(character.Skills.Common, data.SkillsCommon{
+		ID:         name,
+		Progress:   points,
+		LastAccess: tools.GetCurrentTimeInSeconds(),
+	})
+}
+
+// setQuestStatus changes the status of the character's quest, dropping their cached trader assorts as the new
+// status can unlock questassort entries
+func setQuestStatus(character *data.Character[map[string]data.PlayerTradersInfo], quest *data.CharacterQuest, status string) {
+	if quest.StatusTimers == nil {
+		quest.StatusTimers = make(map[string]int)
+	}
+	quest.Status = status
+	quest.StatusTimers[status] = int(tools.GetCurrentTimeInSeconds())
+	data.ResetTraderAssorts(character.ID)
+}
+
+func getCharacterQuest(character *data.Character[map[string]data.PlayerTradersInfo], qid string) *data.CharacterQuest {
+	cachedQuests, err := data.GetQuestCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	index, ok := cachedQuests.Index[qid]
+	if !ok {
+		return nil
+	}
+	return &character.Quests[index]
+}
+
+// getTaskConditionCounter returns the counter of the condition, creating it if it does not exist
+func getTaskConditionCounter(character *data.Character[map[string]data.PlayerTradersInfo], conditionID string, qid string, conditionType string) map[string]any {
+	if character.TaskConditionCounters == nil {
+		character.TaskConditionCounters = make(map[string]any)
+	}
+
+	if counter, ok := character.TaskConditionCounters[conditionID].(map[string]any); ok {
+		if _, ok := counter["value"].(float64); ok {
+			return counter
+		}
+	}
+
+	counter := map[string]any{
+		"id":       conditionID,
+		"sourceId": qid,
+		"type":     conditionType,
+		"value":    float64(0),
+	}
+	character.TaskConditionCounters[conditionID] = counter
+	return counter
+}
+
+// mergeTaskConditionCounters takes the raid counters of the CounterCreator conditions of started quests, clamped
+// to the value of their condition; a counter never goes back, and the rest of the raid counters are dropped.
+// Counters in the older ConditionCounters format name their quest qid instead of sourceId
+func mergeTaskConditionCounters(character *data.Character[map[string]data.PlayerTradersInfo], counters map[string]any, legacy []any) {
+	raidCounters := make([]any, 0, len(counters)+len(legacy))
+	for _, c := range counters {
+		raidCounters = append(raidCounters, c)
+	}
+	raidCounters = append(raidCounters, legacy...)
+
+	for _, c := range raidCounters {
+		counter, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		id, _ := counter["id"].(string)
+		qid, _ := counter["sourceId"].(string)
+		if qid == "" {
+			qid, _ = counter["qid"].(string)
+		}
+		value, ok := counter["value"].(float64)
+		if !ok || id == "" {
+			continue
+		}
+
+		quest := getCharacterQuest(character, qid)
+		if quest == nil || quest.Status != data.Started {
+			continue
+		}
+		query := getQuestQuery(character, qid)
+		if query == nil || query.Conditions.AvailableForFinish == nil {
+			continue
+		}
+
+		condition := findCounterCondition(query.Conditions.AvailableForFinish.CounterCreator, id)
+		if condition == nil {
+			continue
+		}
+
+		conditionType, _ := counter["type"].(string)
+		stored := getTaskConditionCounter(character, id, qid, conditionType)
+		current, _ := stored["value"].(float64)
+		stored["value"] = max(current, min(value, condition.Value))
+	}
+}
+
+// findCounterCondition returns the CounterCreator condition the counter of id belongs to, the counter being keyed
+// by either the condition or its counter
+func findCounterCondition(conditions map[string]*data.CounterCondition, id string) *data.CounterCondition {
+	for conditionID, condition := range conditions {
+		if conditionID == id || condition.CounterID == id {
+			return condition
+		}
+	}
+	return nil
+}
+
+// UpdateQuestConditionCounters completes the CounterCreator conditions of every started quest whose
+// counter has reached its value, making the quest available to finish once all of its conditions are done
+func UpdateQuestConditionCounters(character *data.Character[map[string]data.PlayerTradersInfo], changes *data.ProfileChanges) {
+	for idx := range character.Quests {
+		quest := &character.Quests[idx]
+		if quest.Status != data.Started {
+			continue
+		}
+
+		query := getQuestQuery(character, quest.QID)
+		if query == nil || query.Conditions.AvailableForFinish == nil {
+			continue
+		}
+
+		updated := false
+		for id, condition := range query.Conditions.AvailableForFinish.CounterCreator {
+			if slices.Contains(quest.CompletedConditions, id) {
+				continue
+			}
+			if getConditionCounterValue(character, id, condition.CounterID) < condition.Value {
+				continue
+			}
+			quest.CompletedConditions = append(quest.CompletedConditions, id)
+			updated = true
+		}
+		if !updated {
+			continue
+		}
+
+		if _, done := isQuestReadyToFinish(character, quest, query.Conditions.AvailableForFinish); done {
+			setQuestStatus(character, quest, data.ForFinish)
+		}
+		changes.QuestsStatus = append(changes.QuestsStatus, *quest)
+	}
+}
+
+// getConditionCounterValue returns the value the raid counters hold for the condition, which is keyed
+// by either the condition or its counter depending on the format the client saved it in
+func getConditionCounterValue(character *data.Character[map[string]data.PlayerTradersInfo], conditionID string, counterID string) float64 {
+	for _, id := range []string{conditionID, counterID} {
+		if counter, ok := character.TaskConditionCounters[id].(map[string]any); ok {
+			if value, ok := counter["value"].(float64); ok {
+				return value
+			}
+		}
+	}
+	return 0
+}
+
+// isQuestReadyToFinish checks that every condition the server tracks for finishing the quest is complete,
+// returning the first that is not
+func isQuestReadyToFinish(character *data.Character[map[string]data.PlayerTradersInfo], quest *data.CharacterQuest, forFinish *data.QuestConditionTypes) (string, bool) {
+	if forFinish == nil {
+		return "", true
+	}
+
+	for id := range forFinish.HandoverItem {
+		if !slices.Contains(quest.CompletedConditions, id) {
+			return id, false
+		}
+	}
+	for id, condition := range forFinish.FindItem {
+		if !slices.Contains(quest.CompletedConditions, id) && !isQuestItemFound(character, quest, forFinish, condition) {
+			return id, false
+		}
+	}
+	for id := range forFinish.CounterCreator {
+		if !slices.Contains(quest.CompletedConditions, id) {
+			return id, false
+		}
+	}
+	return "", true
+}
+
+// isQuestItemFound returns whether the character holds enough of the item a FindItem condition asks for, or has
+// already handed it over for a HandoverItem condition of the quest
+func isQuestItemFound(character *data.Character[map[string]data.PlayerTradersInfo], quest *data.CharacterQuest, forFinish *data.QuestConditionTypes, condition *data.HandoverCondition) bool {
+	for id, handover := range forFinish.HandoverItem {
+		if handover.ItemToHandover == condition.ItemToHandover && slices.Contains(quest.CompletedConditions, id) {
+			return true
+		}
+	}
+
+	var found float64
+	for _, item := range character.Inventory.Items {
+		if item.TPL != condition.ItemToHandover {
+			continue
+		}
+		if condition.FoundInRaid && (item.UPD == nil || !item.UPD.SpawnedInSession) {
+			continue
+		}
+
+		if item.UPD != nil && item.UPD.StackObjectsCount > 1 {
+			found += float64(item.UPD.StackObjectsCount)
+		} else {
+			found++
+		}
+	}
+	return found >= condition.Amount
+}
@@ -1,48 +1,946 @@
 package pkg
 
 import (
+	"cmp"
 	"fmt"
+	"log"
+	"math"
+	"math/rand"
 	"mtgo/data"
+	"mtgo/tools"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alphadose/haxmap"
+	"github.com/goccy/go-json"
+)
+
+type ESortType int8
+
+const (
+	ID ESortType = iota
+	Priority
+	Barter
+	Rating
+	OfferItem
+	Price
+	ExpirationDate
+)
+
+const (
+	anyCurrency int8 = iota
+	roubles
+	dollars
+	euros
+)
+
+const (
+	anyOwner int8 = iota
+	traderOwner
+	playerOwner
 )
 
-func GetFlea(handbookId string) (data.Flea, error) {
-	flea := data.GetFlea().Market
+// GetFlea returns the offers matching the search, sorted and paged as requested, along with how many
+// offers of each item pass its filters
+func GetFlea(search *data.RagfairFind) (data.Flea, error) {
+	flea := data.Flea{
+		Offers:           make([]data.Offer, 0),
+		SelectedCategory: search.HandbookID,
+		Categories:       make(map[string]int16),
+	}
+
+	catalogs := data.GetFleaCatalogs()
+	scope, err := getRagfairSearchScope(search, catalogs)
+	if err != nil {
+		return flea, err
+	}
+	// Linked, needed and build searches only show categories of their own results
+	scopedCategories := len(search.BuildItems) != 0 || search.LinkedSearchID != "" || search.NeededSearchID != ""
+
+	now := int32(tools.GetCurrentTimeInSeconds())
+	for tpl, catalog := range catalogs {
+		_, inScope := scope[tpl]
+		if scope != nil && !inScope && scopedCategories {
+			continue
+		}
+
+		for _, offer := range catalog {
+			if !isOfferMatchingFilters(&offer, search, now) {
+				continue
+			}
+			if search.NeededSearchID != "" && !isOfferNeedingItem(&offer, search.NeededSearchID) {
+				continue
+			}
+
+			flea.Categories[tpl]++
+			if scope == nil || inScope {
+				flea.Offers = append(flea.Offers, offer)
+			}
+		}
+	}
+
+	sortOffers(flea.Offers, ESortType(search.SortType), search.SortDirection == 1)
 
-	if handbookId == "" {
-		return flea, nil
+	flea.OffersCount = int16(len(flea.Offers))
+	if search.Limit > 0 {
+		start := min(int(search.Page)*int(search.Limit), len(flea.Offers))
+		end := min(start+int(search.Limit), len(flea.Offers))
+		flea.Offers = flea.Offers[start:end]
 	}
+	return flea, nil
+}
+
+// getRagfairSearchScope returns the TPLs of items the search is looking for, or nil if it is looking for everything
+func getRagfairSearchScope(search *data.RagfairFind, catalogs map[string][]data.Offer) (map[string]struct{}, error) {
+	scope := make(map[string]struct{})
 
-	if main, err := data.HasGetMainHandbookCategory(handbookId); err == nil {
+	switch {
+	case len(search.BuildItems) != 0:
+		for tpl := range search.BuildItems {
+			scope[tpl] = struct{}{}
+		}
+	case search.LinkedSearchID != "":
+		for _, tpl := range getLinkedSearchItems(search.LinkedSearchID, catalogs) {
+			scope[tpl] = struct{}{}
+		}
+	case search.NeededSearchID != "":
+		return nil, nil
+	case search.HandbookID != "":
+		for _, tpl := range getHandbookCategoryItems(search.HandbookID) {
+			scope[tpl] = struct{}{}
+		}
+		if len(scope) == 0 {
+			return nil, fmt.Errorf("handbookID %s invalid, flea not populated", search.HandbookID)
+		}
+	default:
+		return nil, nil
+	}
+	return scope, nil
+}
+
+// getHandbookCategoryItems returns every item TPL under the handbook category, or the ID itself if it is an item
+func getHandbookCategoryItems(handbookID string) []string {
+	output := make([]string, 0)
+	if main, err := data.HasGetMainHandbookCategory(handbookID); err == nil {
 		for _, mainValue := range main {
 			if sub, err := data.HasGetHandbookSubCategory(mainValue); err == nil {
-				for _, subValue := range sub {
-					catalog, err := data.GetFleaCatalog(subValue)
-					if err != nil {
-						continue
-					}
-					flea.Offers = append(flea.Offers, catalog...)
-				}
+				output = append(output, sub...)
 			}
 		}
-		return flea, nil
+		return output
 	}
 
-	if sub, err := data.HasGetHandbookSubCategory(handbookId); err == nil {
-		for _, value := range sub {
-			catalog, err := data.GetFleaCatalog(value)
-			if err != nil {
-				continue
+	if sub, err := data.HasGetHandbookSubCategory(handbookID); err == nil {
+		return append(output, sub...)
+	}
+
+	if _, err := data.GetItemByID(handbookID); err == nil {
+		output = append(output, handbookID)
+	}
+	return output
+}
+
+// getLinkedSearchItems returns the TPLs of listed items that fit in the item, or that the item fits in
+func getLinkedSearchItems(tpl string, catalogs map[string][]data.Offer) []string {
+	target, err := data.GetItemByID(tpl)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	compatible := target.GetCompatibleItems()
+
+	output := make([]string, 0)
+	for listed := range catalogs {
+		item, err := data.GetItemByID(listed)
+		if err != nil {
+			continue
+		}
+
+		if item.IsChildOf(compatible) || target.IsChildOf(item.GetCompatibleItems()) {
+			output = append(output, listed)
+		}
+	}
+	return output
+}
+
+// isOfferNeedingItem checks if the offer asks for the item as payment, or sells something the item fits in
+func isOfferNeedingItem(offer *data.Offer, tpl string) bool {
+	for _, requirement := range offer.Requirements {
+		if requirement.Tpl == tpl {
+			return true
+		}
+	}
+
+	target, err := data.GetItemByID(tpl)
+	if err != nil {
+		return false
+	}
+
+	item, err := data.GetItemByID(offer.GetRootItem().Tpl)
+	if err != nil {
+		return false
+	}
+	return target.IsChildOf(item.GetCompatibleItems())
+}
+
+// isOfferMatchingFilters checks the offer against the currency, price, quantity, condition, expiration,
+// bartering, owner and functionality filters of the search
+func isOfferMatchingFilters(offer *data.Offer, search *data.RagfairFind, now int32) bool {
+	if search.OneHourExpiration && offer.EndTime-now > 3600 {
+		return false
+	}
+
+	switch search.OfferOwnerType {
+	case traderOwner:
+		if offer.User.MemberType != traderCategory {
+			return false
+		}
+	case playerOwner:
+		if offer.User.MemberType == traderCategory {
+			return false
+		}
+	}
+
+	currency := getSearchCurrency(search.Currency)
+	for _, requirement := range offer.Requirements {
+		if search.RemoveBartering && !data.IsCurrencyByID(requirement.Tpl) {
+			return false
+		}
+		if currency != "" && requirement.Tpl != currency {
+			return false
+		}
+	}
+
+	if search.PriceFrom > 0 || search.PriceTo > 0 {
+		price := getOfferPriceInCurrency(offer, currency)
+		if search.PriceFrom > 0 && price < search.PriceFrom {
+			return false
+		}
+		if search.PriceTo > 0 && price > search.PriceTo {
+			return false
+		}
+	}
+
+	root := offer.GetRootItem()
+	quantity := int32(1)
+	if root.Upd != nil && root.Upd.StackObjectsCount > 1 {
+		quantity = root.Upd.StackObjectsCount
+	}
+	if search.QuantityFrom > 0 && quantity < search.QuantityFrom {
+		return false
+	}
+	if search.QuantityTo > 0 && quantity > search.QuantityTo {
+		return false
+	}
+
+	item, err := data.GetItemByID(root.Tpl)
+	if err != nil {
+		return false
+	}
+
+	if search.ConditionFrom > 0 || (search.ConditionTo > 0 && search.ConditionTo < 100) {
+		condition := getOfferItemCondition(item, root)
+		if condition < float64(search.ConditionFrom) {
+			return false
+		}
+		if search.ConditionTo > 0 && condition > float64(search.ConditionTo) {
+			return false
+		}
+	}
+
+	if search.OnlyFunctional && item.IsWeapon() && len(offer.Items) == 1 {
+		return false
+	}
+	return true
+}
+
+const traderCategory data.MemberCategory = 4
+
+// getSearchCurrency returns the TPL of the currency selected in the search, or an empty string for any currency
+func getSearchCurrency(currency int8) string {
+	switch currency {
+	case roubles:
+		return *data.GetCurrencyByName("RUB")
+	case dollars:
+		return *data.GetCurrencyByName("USD")
+	case euros:
+		return *data.GetCurrencyByName("EUR")
+	default:
+		return ""
+	}
+}
+
+// getOfferPriceInCurrency returns the price of the offer converted to the currency, or roubles if none is given
+func getOfferPriceInCurrency(offer *data.Offer, currency string) int32 {
+	price := getOfferPriceInRoubles(offer)
+	if currency == "" {
+		return price
+	}
+
+	rate, err := data.GetPriceByID(currency)
+	if err != nil || rate <= 0 {
+		return price
+	}
+	return int32(math.Round(float64(price) / float64(rate)))
+}
+
+// getOfferItemCondition returns the durability or remaining resource of the item as a percentage
+func getOfferItemCondition(item *data.DatabaseItem, root *data.AssortItem) float64 {
+	if root.Upd == nil {
+		return 100
+	}
+
+	percentage := func(current float64, property string) float64 {
+		maximum, ok := item.Props[property].(float64)
+		if !ok || maximum <= 0 {
+			return 100
+		}
+		return current / maximum * 100
+	}
+
+	switch {
+	case root.Upd.Repairable != nil:
+		return percentage(float64(root.Upd.Repairable.Durability), "MaxDurability")
+	case root.Upd.MedKit != nil:
+		return percentage(float64(root.Upd.MedKit.HpResource), "MaxHpResource")
+	case root.Upd.FoodDrink != nil:
+		return percentage(float64(root.Upd.FoodDrink.HpPercent), "MaxResource")
+	case root.Upd.Resource != nil:
+		return percentage(float64(root.Upd.Resource.Value), "MaxResource")
+	default:
+		return 100
+	}
+}
+
+// sortOffers sorts the offers by the sort type, falling back to their IntID when they are equal
+func sortOffers(offers []data.Offer, sortType ESortType, descending bool) {
+	var names *haxmap.Map[string, any]
+	if sortType == OfferItem {
+		names, _ = data.GetLocaleGlobalByName("en")
+	}
+
+	compare := func(a *data.Offer, b *data.Offer) int {
+		switch sortType {
+		case Barter:
+			return cmp.Compare(countBarterRequirements(a), countBarterRequirements(b))
+		case Rating:
+			return cmp.Compare(a.User.Rating, b.User.Rating)
+		case OfferItem:
+			return strings.Compare(getOfferItemName(a, names), getOfferItemName(b, names))
+		case Price:
+			return cmp.Compare(getOfferPriceInRoubles(a), getOfferPriceInRoubles(b))
+		case ExpirationDate:
+			return cmp.Compare(a.EndTime, b.EndTime)
+		default:
+			return 0
+		}
+	}
+
+	slices.SortStableFunc(offers, func(a data.Offer, b data.Offer) int {
+		result := compare(&a, &b)
+		if result == 0 {
+			result = cmp.Compare(a.IntID, b.IntID)
+		}
+		if descending {
+			return -result
+		}
+		return result
+	})
+}
+
+func countBarterRequirements(offer *data.Offer) int {
+	count := 0
+	for _, requirement := range offer.Requirements {
+		if !data.IsCurrencyByID(requirement.Tpl) {
+			count++
+		}
+	}
+	return count
+}
+
+func getOfferItemName(offer *data.Offer, names *haxmap.Map[string, any]) string {
+	tpl := offer.GetRootItem().Tpl
+	if names != nil {
+		if name, ok := names.Get(tpl + " Name"); ok {
+			if name, ok := name.(string); ok {
+				return name
 			}
-			flea.Offers = append(flea.Offers, catalog...)
 		}
-		return flea, nil
 	}
 
-	catalog, err := data.GetFleaCatalog(handbookId)
+	if item, err := data.GetItemByID(tpl); err == nil {
+		return item.Name
+	}
+	return tpl
+}
+
+const (
+	ragmanID              string = "5ac3b934156ae10c4430e83c"
+	offerSoldTemplate     string = "5bdabfb886f7743e152e867e 0"
+	offerExpiredTemplate  string = "5bdabfe486f7743e1665df6e 0"
+	ragfairSimulationTick        = time.Minute
+	// baseSaleChance is the percentage chance, per tick, of an offer priced at its handbook price selling
+	baseSaleChance float64 = 20
+	// saleChanceFalloff is how strongly overpricing an offer reduces its chance of selling
+	saleChanceFalloff float64 = 3
+)
+
+type ragfairAddOffer struct {
+	Action         string             `json:"Action"`
+	SellInOnePiece bool               `json:"sellInOnePiece"`
+	Items          []string           `json:"items"`
+	Requirements   []offerRequirement `json:"requirements"`
+}
+
+type offerRequirement struct {
+	Tpl            string  `json:"_tpl"`
+	Count          float32 `json:"count"`
+	Level          int     `json:"level"`
+	Side           int     `json:"side"`
+	OnlyFunctional bool    `json:"onlyFunctional"`
+}
+
+type ragfairRemoveOffer struct {
+	Action  string `json:"Action"`
+	OfferID string `json:"offerId"`
+}
+
+type ragfairRenewOffer struct {
+	Action      string `json:"Action"`
+	OfferID     string `json:"offerId"`
+	RenewalTime int32  `json:"renewalTime"`
+}
+
+type ItemMarketPrice struct {
+	Avg int32 `json:"avg"`
+	Min int32 `json:"min"`
+	Max int32 `json:"max"`
+}
+
+// RagFairAddOffer lists the player's items on the flea after charging the listing fee
+func RagFairAddOffer(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	addOffer := new(ragfairAddOffer)
+	input, _ := json.MarshalNoEscape(action)
+	if err := json.UnmarshalNoEscape(input, &addOffer); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
 	if err != nil {
-		return flea, fmt.Errorf("handbookID %s invalid, flea not populated", handbookId)
+		log.Println(err)
+		return
 	}
-	flea.Offers = append(flea.Offers, catalog...)
-	return flea, nil
 
+	config := data.GetGlobals().Config.RagFair
+	if int(character.Info.Level) < config.MinUserLevel {
+		log.Println("Character level is too low to list offers on the flea")
+		return
+	}
+
+	if len(character.RagfairInfo.Offers) >= data.GetMaxActiveOffers(character.RagfairInfo.Rating) {
+		log.Println("Character has reached the maximum amount of active flea offers")
+		return
+	}
+
+	if len(addOffer.Items) == 0 || len(addOffer.Requirements) == 0 {
+		log.Println("Flea offer requires items and requirements")
+		return
+	}
+
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	var tpl string
+	var quantity int32
+	for _, id := range addOffer.Items {
+		index := invCache.GetIndexOfItemByID(id)
+		if index == nil {
+			return
+		}
+		item := character.Inventory.Items[*index]
+
+		if tpl == "" {
+			tpl = item.TPL
+		} else if item.TPL != tpl {
+			log.Println("All items in a flea offer must be the same")
+			return
+		}
+
+		if config.IsOnlyFoundInRaidAllowed && (item.UPD == nil || !item.UPD.SpawnedInSession) {
+			log.Println("Item", item.ID, "is not found in raid and can not be listed")
+			return
+		}
+
+		if len(addOffer.Items) > 1 && len(data.GetInventoryItemFamilyTreeIDs(character.Inventory.Items, id)) > 1 {
+			log.Println("Items with attachments can only be listed one at a time")
+			return
+		}
+
+		if item.UPD != nil && item.UPD.StackObjectsCount > 1 {
+			quantity += item.UPD.StackObjectsCount
+		} else {
+			quantity++
+		}
+	}
+
+	requirements := make([]*data.Scheme, 0, len(addOffer.Requirements))
+	var requirementsCost float64
+	for _, requirement := range addOffer.Requirements {
+		price, err := data.GetPriceByID(requirement.Tpl)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		requirementsCost += float64(price) * float64(requirement.Count)
+		requirements = append(requirements, &data.Scheme{Tpl: requirement.Tpl, Count: requirement.Count})
+	}
+
+	itemsCost := getItemFamilyPrice(character.Inventory.Items, addOffer.Items[0])
+	fee := calculateOfferFee(itemsCost, requirementsCost, quantity)
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	restore, err := snapshotInventory(character, changes)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	removed, err := RemoveItemsFromInventory(character, addOffer.Items, changes)
+	if err != nil {
+		log.Println(err)
+		restore()
+		return
+	}
+
+	if err := RemoveCurrencyFromInventory(character, *data.GetCurrencyByName("RUB"), fee, changes); err != nil {
+		log.Println(err)
+		restore()
+		return
+	}
+
+	items := convertInventoryItemsToOfferItems(removed, addOffer.Items[0], quantity)
+	// requirements price a single item, so a pack sold in one piece costs them for every item
+	summaryCost := int32(math.Round(requirementsCost))
+	if addOffer.SellInOnePiece {
+		summaryCost *= quantity
+	}
+
+	now := int32(tools.GetCurrentTimeInSeconds())
+	offer := data.Offer{
+		ID:    tools.GenerateMongoID(),
+		IntID: data.GetNextOfferIntID(),
+		User: data.OfferUser{
+			ID:              character.ID,
+			MemberType:      data.MemberCategory(character.Info.MemberCategory),
+			Nickname:        character.Info.Nickname,
+			Rating:          character.RagfairInfo.Rating,
+			IsRatingGrowing: character.RagfairInfo.IsRatingGrowing,
+		},
+		Root:             addOffer.Items[0],
+		Items:            items,
+		ItemsCost:        int32(math.Round(itemsCost)),
+		Requirements:     requirements,
+		RequirementsCost: int32(math.Round(requirementsCost)),
+		SummaryCost:      summaryCost,
+		SellInOnePiece:   addOffer.SellInOnePiece,
+		StartTime:        now,
+		EndTime:          now + int32(config.OfferDurationTimeInHour*3600),
+		LoyaltyLevel:     1,
+	}
+
+	character.RagfairInfo.Offers = append(character.RagfairInfo.Offers, offer)
+	data.AddOfferToCatalog(offer)
+
+	changes.RagfairOffers = append(changes.RagfairOffers, offer)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+// RagFairRemoveOffer takes the offer down; its items are returned through the mail once it expires
+func RagFairRemoveOffer(action map[string]any, sessionID string, _ *data.ProfileChangesEvent) {
+	removeOffer := new(ragfairRemoveOffer)
+	input, _ := json.MarshalNoEscape(action)
+	if err := json.UnmarshalNoEscape(input, &removeOffer); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	offer := getCharacterOffer(character, removeOffer.OfferID)
+	if offer == nil {
+		log.Printf(offerNotOwned, removeOffer.OfferID)
+		return
+	}
+
+	config := data.GetGlobals().Config.RagFair
+	endTime := int32(tools.GetCurrentTimeInSeconds()) + int32(math.Round(config.OfferDurationTimeInHourAfterRemove*3600))
+	if endTime < offer.EndTime {
+		offer.EndTime = endTime
+	}
+	data.UpdateOfferInCatalog(*offer)
+}
+
+// RagFairRenewOffer extends the duration of the offer for a fee
+func RagFairRenewOffer(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	renewOffer := new(ragfairRenewOffer)
+	input, _ := json.MarshalNoEscape(action)
+	if err := json.UnmarshalNoEscape(input, &renewOffer); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	offer := getCharacterOffer(character, renewOffer.OfferID)
+	if offer == nil {
+		log.Printf(offerNotOwned, renewOffer.OfferID)
+		return
+	}
+
+	config := data.GetGlobals().Config.RagFair
+	hours := min(renewOffer.RenewalTime, int32(config.MaxRenewOfferTimeInHour))
+	if hours <= 0 {
+		return
+	}
+
+	root := offer.GetRootItem()
+	quantity := int32(1)
+	if root.Upd != nil && root.Upd.StackObjectsCount > 1 {
+		quantity = root.Upd.StackObjectsCount
+	}
+
+	fee := calculateOfferFee(float64(offer.ItemsCost), float64(offer.RequirementsCost), quantity)
+	cost := int32(math.Ceil(float64(fee) / float64(config.OfferDurationTimeInHour) * config.RenewPricePerHour * float64(hours)))
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	if err := RemoveCurrencyFromInventory(character, *data.GetCurrencyByName("RUB"), cost, changes); err != nil {
+		log.Println(err)
+		return
+	}
+
+	offer.EndTime += hours * 3600
+	data.UpdateOfferInCatalog(*offer)
+
+	changes.RagfairOffers = append(changes.RagfairOffers, *offer)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+// GetOfferByIntID returns the flea offer with the given IntID
+func GetOfferByIntID(id int16) (*data.Offer, error) {
+	return data.GetOfferByIntID(id)
+}
+
+// GetItemMarketPrice returns the average, lowest and highest rouble price the item is listed for on the flea,
+// falling back to its handbook price if it is not listed
+func GetItemMarketPrice(tpl string) (*ItemMarketPrice, error) {
+	catalog, _ := data.GetFleaCatalog(tpl)
+
+	output := new(ItemMarketPrice)
+	var total int64
+	var count int64
+	for _, offer := range catalog {
+		price := getOfferPriceInRoubles(&offer)
+		if price <= 0 {
+			continue
+		}
+
+		if count == 0 || price < output.Min {
+			output.Min = price
+		}
+		if price > output.Max {
+			output.Max = price
+		}
+		total += int64(price)
+		count++
+	}
+
+	if count != 0 {
+		output.Avg = int32(total / count)
+		return output, nil
+	}
+
+	price, err := data.GetPriceByID(tpl)
+	if err != nil {
+		return nil, err
+	}
+	output.Avg, output.Min, output.Max = price, price, price
+	return output, nil
+}
+
+// StartRagfairSimulation periodically buys player offers and returns expired ones
+func StartRagfairSimulation() {
+	startJob(ragfairSimulationTick, func() {
+		data.GetProfiles().ForEach(func(_ string, profile *data.Profile) bool {
+			profile.Lock()
+			defer profile.Unlock()
+
+			if profile.Character != nil && len(profile.Character.RagfairInfo.Offers) != 0 {
+				simulateOfferSales(profile.Character)
+			}
+			return true
+		})
+	})
+}
+
+func simulateOfferSales(character *data.Character[map[string]data.PlayerTradersInfo]) {
+	config := data.GetGlobals().Config.RagFair
+	now := int32(tools.GetCurrentTimeInSeconds())
+
+	offers := make([]data.Offer, 0, len(character.RagfairInfo.Offers))
+	changed := false
+	for _, offer := range character.RagfairInfo.Offers {
+		if now >= offer.EndTime {
+			returnExpiredOffer(character.ID, &offer, int32(config.YourOfferDidNotSellMaxStorageTimeInHour*3600))
+			changed = true
+			continue
+		}
+
+		if now-offer.StartTime < int32(config.DelaySinceOfferAdd) || !rollOfferSale(&offer) {
+			offers = append(offers, offer)
+			continue
+		}
+
+		changed = true
+		root := offer.GetRootItem()
+		remaining := int32(1)
+		if root.Upd != nil && root.Upd.StackObjectsCount > 1 {
+			remaining = root.Upd.StackObjectsCount
+		}
+
+		sold := remaining
+		if !offer.SellInOnePiece && remaining > 1 {
+			sold = int32(rand.Intn(int(remaining))) + 1
+		}
+
+		sellOffer(character, &offer, sold, int32(config.YouSellOfferMaxStorageTimeInHour*3600))
+
+		if sold == remaining {
+			data.RemoveOfferFromCatalog(offer.ID)
+			continue
+		}
+
+		root.Upd.StackObjectsCount -= sold
+		data.UpdateOfferInCatalog(offer)
+		offers = append(offers, offer)
+	}
+
+	if !changed {
+		return
+	}
+
+	character.RagfairInfo.Offers = offers
+	if err := character.SaveCharacter(); err != nil {
+		log.Println(err)
+	}
+}
+
+// rollOfferSale decides if an offer sells this tick; the further above handbook price it is, the less likely
+func rollOfferSale(offer *data.Offer) bool {
+	if offer.ItemsCost <= 0 || offer.RequirementsCost <= 0 {
+		return false
+	}
+
+	ratio := float64(offer.RequirementsCost) / float64(offer.ItemsCost)
+	chance := baseSaleChance * math.Pow(1/ratio, saleChanceFalloff)
+	return rand.Float64()*100 < min(chance, 100)
+}
+
+// sellOffer pays the seller for sold items of the offer through the mail and notifies them
+func sellOffer(character *data.Character[map[string]data.PlayerTradersInfo], offer *data.Offer, sold int32, storageTime int32) {
+	payment := make([]data.InventoryItem, 0)
+	var saleValue float64
+	for _, requirement := range offer.Requirements {
+		item, err := data.GetItemByID(requirement.Tpl)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		total := int32(math.Round(float64(requirement.Count))) * sold
+		if price, err := data.GetPriceByID(requirement.Tpl); err == nil {
+			saleValue += float64(price) * float64(total)
+		}
+
+		stackMaxSize := item.GetStackMaxSize()
+		if stackMaxSize < 1 {
+			stackMaxSize = 1
+		}
+		for _, stack := range GetCorrectAmountOfItemsPurchased(total, stackMaxSize) {
+			newItem := data.CreateNewItem(requirement.Tpl, "")
+			if upd, err := item.CreateItemUPD(); err == nil && upd != nil {
+				newItem.UPD = upd
+			}
+			if stackMaxSize > 1 {
+				if newItem.UPD == nil {
+					newItem.UPD = new(data.ItemUpdate)
+				}
+				newItem.UPD.StackObjectsCount = stack
+			}
+			payment = append(payment, *newItem)
+		}
+	}
+
+	updateRagfairRating(character, saleValue)
+
+	message := data.CreateMessageWithItems(ragmanID, "Flea", offerSoldTemplate, payment, storageTime)
+	if err := SendMailToPlayer(character.ID, ragmanID, "Trader", message); err != nil {
+		log.Println(err)
+	}
+
+	notification := data.CreateOfferSoldNotification(offer.ID, offer.GetRootItem().Tpl, sold)
+	if err := SendNotificationToPlayer(character.ID, notification); err != nil {
+		log.Println(err)
+	}
+}
+
+// returnExpiredOffer removes the offer from the flea and mails its items back to the seller
+func returnExpiredOffer(sessionID string, offer *data.Offer, storageTime int32) {
+	data.RemoveOfferFromCatalog(offer.ID)
+
+	items := make([]data.InventoryItem, 0, len(offer.Items))
+	for _, item := range offer.Items {
+		items = append(items, data.InventoryItem{
+			ID:       item.ID,
+			TPL:      item.Tpl,
+			ParentID: item.ParentID,
+			SlotID:   item.SlotID,
+			UPD:      item.Upd,
+		})
+	}
+
+	message := data.CreateMessageWithItems(ragmanID, "Flea", offerExpiredTemplate, items, storageTime)
+	if err := SendMailToPlayer(sessionID, ragmanID, "Trader", message); err != nil {
+		log.Println(err)
+	}
+}
+
+// updateRagfairRating raises the seller's flea rating based on how much they sold for
+func updateRagfairRating(character *data.Character[map[string]data.PlayerTradersInfo], saleValue float64) {
+	config := data.GetGlobals().Config.RagFair
+	if config.RatingSumForIncrease <= 0 {
+		return
+	}
+
+	saleValue = min(saleValue, float64(config.MaxSumForIncreaseRatingPerOneSale))
+	increase := saleValue / float64(config.RatingSumForIncrease) * config.RatingIncreaseCount
+	character.RagfairInfo.Rating += float32(increase)
+	character.RagfairInfo.IsRatingGrowing = true
+}
+
+// calculateOfferFee returns the listing fee in roubles of an offer of quantity items, each worth itemsCost and
+// priced at requirementsCost, following the community tax set in globals
+func calculateOfferFee(itemsCost float64, requirementsCost float64, quantity int32) int32 {
+	config := data.GetGlobals().Config.RagFair
+	if itemsCost <= 0 || requirementsCost <= 0 {
+		return 0
+	}
+
+	itemTax := float64(config.CommunityItemTax) / 100
+	requirementTax := float64(config.CommunityRequirementTax) / 100
+
+	itemPower := math.Log10(itemsCost / requirementsCost)
+	requirementPower := math.Log10(requirementsCost / itemsCost)
+	if requirementsCost < itemsCost {
+		itemPower = math.Pow(itemPower, 1.08)
+	} else {
+		requirementPower = math.Pow(requirementPower, 1.08)
+	}
+
+	fee := itemsCost*itemTax*math.Pow(4, itemPower)*float64(quantity) +
+		requirementsCost*requirementTax*math.Pow(4, requirementPower)*float64(quantity)
+	return int32(math.Ceil(fee))
+}
+
+// getItemFamilyPrice returns the handbook price of an item and everything attached to it
+func getItemFamilyPrice(items []data.InventoryItem, id string) float64 {
+	family := data.GetInventoryItemFamilyTreeIDs(items, id)
+
+	var total float64
+	for _, item := range items {
+		if !slices.Contains(family, item.ID) {
+			continue
+		}
+		if price, err := data.GetPriceByID(item.TPL); err == nil {
+			total += float64(price)
+		}
+	}
+	return total
+}
+
+// getOfferPriceInRoubles returns what a single item of the offer costs in roubles
+func getOfferPriceInRoubles(offer *data.Offer) int32 {
+	var total float64
+	for _, requirement := range offer.Requirements {
+		price, err := data.GetPriceByID(requirement.Tpl)
+		if err != nil {
+			continue
+		}
+		total += float64(price) * float64(requirement.Count)
+	}
+	return int32(math.Round(total))
 }
+
+// convertInventoryItemsToOfferItems converts the items to AssortItem, making root the parent of the offer
+// and setting its stack to quantity
+func convertInventoryItemsToOfferItems(items []data.InventoryItem, root string, quantity int32) []data.AssortItem {
+	family := data.GetInventoryItemFamilyTreeIDs(items, root)
+	output := make([]data.AssortItem, 0, len(family))
+	for _, item := range items {
+		if !slices.Contains(family, item.ID) {
+			continue
+		}
+
+		offerItem := data.AssortItem{
+			ID:       item.ID,
+			Tpl:      item.TPL,
+			ParentID: item.ParentID,
+			SlotID:   item.SlotID,
+			Upd:      item.UPD,
+		}
+
+		if item.ID == root {
+			offerItem.ParentID = "hideout"
+			offerItem.SlotID = "hideout"
+			if offerItem.Upd == nil {
+				offerItem.Upd = new(data.ItemUpdate)
+			}
+			offerItem.Upd.StackObjectsCount = quantity
+		}
+		output = append(output, offerItem)
+	}
+	return output
+}
+
+func getCharacterOffer(character *data.Character[map[string]data.PlayerTradersInfo], id string) *data.Offer {
+	for idx, offer := range character.RagfairInfo.Offers {
+		if offer.ID == id {
+			return &character.RagfairInfo.Offers[idx]
+		}
+	}
+	return nil
+}
+
+const offerNotOwned string = "Offer %s does not belong to the character\n"
@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"log"
+	"mtgo/data"
+	"mtgo/tools"
+	"slices"
+
+	"github.com/goccy/go-json"
+)
+
+type RaidProfileSave struct {
+	Exit                  string         `json:"exit"`
+	Profile               map[string]any `json:"profile"`
+	IsPlayerScav          bool           `json:"isPlayerScav"`
+	Health                RaidHealth     `json:"health"`
+	DisableProgressionNow bool           `json:"disableProgressionNow"`
+}
+
+type RaidHealth struct {
+	IsAlive     bool
+	Health      map[string]RaidHealthPart
+	Hydration   float64
+	Energy      float64
+	Temperature float64
+}
+
+type RaidHealthPart struct {
+	Maximum float64
+	Current float64
+	Effects map[string]any
+}
+
+const (
+	securedContainerSlot string = "SecuredContainer"
+	pocketsSlot          string = "Pockets"
+)
+
+// SaveRaidProfile merges the profile returned at the end of a raid into the stored character, adding
+// the quest progress it made to the profile changes of event
+func SaveRaidProfile(sessionID string, save *RaidProfileSave, event *data.ProfileChangesEvent) error {
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		return err
+	}
+
+	if save.IsPlayerScav {
+		log.Println("Player Scav raid progression is not saved yet")
+		return nil
+	}
+
+	raid := new(data.Character[[]any])
+	input, err := json.MarshalNoEscape(save.Profile)
+	if err != nil {
+		return err
+	}
+	if err := json.UnmarshalNoEscape(input, raid); err != nil {
+		return err
+	}
+
+	survived := save.Exit == "survived" || save.Exit == "runner"
+	if survived {
+		character.Inventory.Items = mergeRaidInventory(&character.Inventory, raid.Inventory.Items, nil)
+	} else {
+		kept := getItemsKeptOnDeath(character, raid.Inventory.Items)
+		storeInsuranceReturns(character, raid.Inventory.Items, kept)
+		character.Inventory.Items = mergeRaidInventory(&character.Inventory, raid.Inventory.Items, kept)
+	}
+
+	applyRaidHealth(&character.Health, &save.Health, survived)
+
+	if !save.DisableProgressionNow {
+		character.Info.Experience = raid.Info.Experience
+		if level := data.GetLevelByExperience(character.Info.Experience); level > character.Info.Level {
+			character.Info.Level = level
+		}
+		character.Skills = raid.Skills
+		character.Stats = raid.Stats
+		mergeTaskConditionCounters(character, raid.TaskConditionCounters, raid.ConditionCounters.Counters)
+	}
+
+	if changes, ok := event.ProfileChanges.Get(character.ID); ok {
+		if !save.DisableProgressionNow {
+			UpdateQuestConditionCounters(character, changes)
+			// the client does not apply the changes of the raid save, so they go out with the next event
+			if cache, err := data.GetQuestCacheByID(character.ID); err == nil {
+				cache.Pending = append(cache.Pending, changes.QuestsStatus...)
+			}
+		}
+		changes.Experience = character.Info.Experience
+		changes.Skills = character.Skills
+		changes.Health = character.Health
+		event.ProfileChanges.Set(character.ID, changes)
+	}
+
+	if character.Encyclopedia == nil {
+		character.Encyclopedia = make(map[string]bool)
+	}
+	for tpl, examined := range raid.Encyclopedia {
+		if examined || !character.Encyclopedia[tpl] {
+			character.Encyclopedia[tpl] = examined
+		}
+	}
+
+	if err := character.SaveCharacter(); err != nil {
+		return err
+	}
+
+	cache, err := data.GetCacheByID(character.ID)
+	if err != nil {
+		return err
+	}
+	cache.Inventory = data.SetInventoryContainer(&character.Inventory)
+	return nil
+}
+
+// mergeRaidInventory replaces everything the character brought into raid with the items returned from it;
+// if kept is not nil, only raid items with their IDs in kept are brought back
+func mergeRaidInventory(inventory *data.Inventory, raidItems []data.InventoryItem, kept map[string]struct{}) []data.InventoryItem {
+	removed := make(map[string]struct{})
+	for _, id := range data.GetInventoryItemFamilyTreeIDs(inventory.Items, inventory.Equipment) {
+		removed[id] = struct{}{}
+	}
+	if inventory.QuestRaidItems != "" {
+		for _, id := range data.GetInventoryItemFamilyTreeIDs(inventory.Items, inventory.QuestRaidItems) {
+			removed[id] = struct{}{}
+		}
+	}
+	delete(removed, inventory.Equipment)
+	delete(removed, inventory.QuestRaidItems)
+
+	output := make([]data.InventoryItem, 0, len(inventory.Items)+len(raidItems))
+	for _, item := range inventory.Items {
+		if _, ok := removed[item.ID]; ok {
+			continue
+		}
+		output = append(output, item)
+	}
+
+	returned := make(map[string]struct{})
+	for _, id := range data.GetInventoryItemFamilyTreeIDs(raidItems, inventory.Equipment) {
+		returned[id] = struct{}{}
+	}
+	if inventory.QuestRaidItems != "" {
+		for _, id := range data.GetInventoryItemFamilyTreeIDs(raidItems, inventory.QuestRaidItems) {
+			returned[id] = struct{}{}
+		}
+	}
+
+	for _, item := range raidItems {
+		if item.ID == inventory.Equipment || item.ID == inventory.QuestRaidItems {
+			continue
+		}
+		if _, ok := returned[item.ID]; !ok {
+			continue
+		}
+		if kept != nil {
+			if _, ok := kept[item.ID]; !ok {
+				continue
+			}
+		}
+		output = append(output, item)
+	}
+	return output
+}
+
+// getItemsKeptOnDeath returns the IDs of raid items that survive a death: the secured container and
+// its contents, the pockets and any insured item still attached to something that is kept
+func getItemsKeptOnDeath(character *data.Character[map[string]data.PlayerTradersInfo], raidItems []data.InventoryItem) map[string]struct{} {
+	kept := make(map[string]struct{})
+	equipment := character.Inventory.Equipment
+
+	for _, item := range raidItems {
+		if item.ParentID != equipment {
+			continue
+		}
+		switch item.SlotID {
+		case securedContainerSlot:
+			for _, id := range data.GetInventoryItemFamilyTreeIDs(raidItems, item.ID) {
+				kept[id] = struct{}{}
+			}
+		case pocketsSlot:
+			kept[item.ID] = struct{}{}
+		}
+	}
+
+	insured := make([]string, 0, len(character.InsuredItems))
+	for _, insuredItem := range character.InsuredItems {
+		insured = append(insured, insuredItem.ItemID)
+	}
+
+	for added := true; added; {
+		added = false
+		for _, item := range raidItems {
+			if _, ok := kept[item.ID]; ok || !slices.Contains(insured, item.ID) {
+				continue
+			}
+			if _, ok := kept[item.ParentID]; ok || item.ParentID == equipment {
+				kept[item.ID] = struct{}{}
+				added = true
+			}
+		}
+	}
+	return kept
+}
+
+// applyRaidHealth sets the character's health to what they left the raid with; dead characters
+// come back with their body parts restored
+func applyRaidHealth(health *data.HealthInfo, raid *RaidHealth, survived bool) {
+	bodyParts := map[string]*data.HealthOf{
+		"Head":     &health.BodyParts.Head,
+		"Chest":    &health.BodyParts.Chest,
+		"Stomach":  &health.BodyParts.Stomach,
+		"LeftArm":  &health.BodyParts.LeftArm,
+		"RightArm": &health.BodyParts.RightArm,
+		"LeftLeg":  &health.BodyParts.LeftLeg,
+		"RightLeg": &health.BodyParts.RightLeg,
+	}
+
+	for name, part := range raid.Health {
+		bodyPart, ok := bodyParts[name]
+		if !ok {
+			log.Printf("Body part %s does not exist, skipping\n", name)
+			continue
+		}
+
+		if part.Maximum != 0 {
+			bodyPart.Health.Maximum = float32(part.Maximum)
+		}
+		if !survived {
+			bodyPart.Health.Current = bodyPart.Health.Maximum
+			bodyPart.Effects = nil
+			continue
+		}
+		bodyPart.Health.Current = float32(part.Current)
+		bodyPart.Effects = part.Effects
+	}
+
+	health.Hydration.Current = float32(raid.Hydration)
+	health.Energy.Current = float32(raid.Energy)
+	if raid.Temperature != 0 {
+		health.Temperature.Current = float32(raid.Temperature)
+	}
+	health.UpdateTime = int32(tools.GetCurrentTimeInSeconds())
+}
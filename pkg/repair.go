@@ -0,0 +1,407 @@
+package pkg
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"mtgo/data"
+	"slices"
+	"strconv"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	weaponTreatmentSkill string = "WeaponTreatment"
+	lightVestsSkill      string = "LightVests"
+	heavyVestsSkill      string = "HeavyVests"
+
+	commonBuffMinValue float64 = 1
+	commonBuffMaxValue float64 = 3
+	rareBuffMinValue   float64 = 4
+	rareBuffMaxValue   float64 = 6
+)
+
+const (
+	itemNotRepairable     string = "item %s can not be repaired"
+	traderNoRepairs       string = "Trader %s does not repair %s\n"
+	repairKitInsufficient string = "Repair kit %s does not have enough resource\n"
+	armorMaterialUnknown  string = "armor material %s does not exist"
+)
+
+type repairPoints struct {
+	ID    string  `json:"_id"`
+	Count float64 `json:"count"`
+}
+
+type traderRepair struct {
+	Action      string
+	TID         string         `json:"tid"`
+	RepairItems []repairPoints `json:"repairItems"`
+}
+
+// TraderRepair restores the durability of the items for the trader's price, in their currency
+func TraderRepair(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	repair := new(traderRepair)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &repair); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	trader, err := data.GetTraderByUID(repair.TID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	settings := trader.Base.Repair
+
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	var priceCoef int16
+	if level := int(character.TradersInfo[repair.TID].LoyaltyLevel) - 1; level >= 0 && level < len(trader.Base.LoyaltyLevels) {
+		priceCoef = trader.Base.LoyaltyLevels[level].RepairPriceCoef
+	}
+	enhancements := data.GetGlobals().Config.RepairSettings.ItemEnhancementSettings
+
+	type pricedRepair struct {
+		item   *data.InventoryItem
+		dbItem *data.DatabaseItem
+		points float64
+	}
+	repairs := make([]pricedRepair, 0, len(repair.RepairItems))
+	var cost float64
+	for _, repairItem := range repair.RepairItems {
+		index := invCache.GetIndexOfItemByID(repairItem.ID)
+		if index == nil {
+			return
+		}
+		item := &character.Inventory.Items[*index]
+
+		dbItem, err := data.GetItemByID(item.TPL)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if !settings.Availability || slices.Contains(settings.ExcludedIdList, item.TPL) || dbItem.IsChildOf(settings.ExcludedCategory) {
+			log.Printf(traderNoRepairs, repair.TID, repairItem.ID)
+			return
+		}
+		if err := checkItemRepairable(item, dbItem); err != nil {
+			log.Println(err)
+			return
+		}
+
+		repairCost, _ := dbItem.Props["RepairCost"].(float64)
+		itemCost := repairCost * repairItem.Count * float64(settings.Quality) *
+			(1 + float64(priceCoef)/100) * (1 + float64(settings.PriceRate)/100)
+		if settings.CurrencyCoefficient > 0 {
+			itemCost *= float64(settings.CurrencyCoefficient)
+		}
+		if item.UPD.Buff != nil {
+			switch item.UPD.Buff.BuffType {
+			case "DamageReduction":
+				itemCost *= enhancements.DamageReduction.PriceModifier
+			case "MalfunctionProtections":
+				itemCost *= enhancements.MalfunctionProtections.PriceModifier
+			case "WeaponSpread":
+				itemCost *= enhancements.WeaponSpread.PriceModifier
+			}
+		}
+
+		cost += itemCost
+		repairs = append(repairs, pricedRepair{item: item, dbItem: dbItem, points: repairItem.Count})
+	}
+
+	currency := settings.Currency
+	if currency == "" {
+		currency = roublesTPL
+	}
+	if err := RemoveCurrencyFromInventory(character, currency, int32(math.Round(cost)), changes); err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, priced := range repairs {
+		if err := repairItemDurability(priced.item, priced.dbItem, priced.points, false); err != nil {
+			log.Println(err)
+			continue
+		}
+		changes.Items.Change = append(changes.Items.Change, *priced.item)
+	}
+
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+type repairKitRepair struct {
+	Action         string
+	Target         string         `json:"target"`
+	RepairKitsInfo []repairPoints `json:"repairKitsInfo"`
+}
+
+// Repair restores the durability of the target with the resource of the repair kits, possibly giving it a buff
+func Repair(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	repair := new(repairKitRepair)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &repair); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	index := invCache.GetIndexOfItemByID(repair.Target)
+	if index == nil {
+		return
+	}
+	item := &character.Inventory.Items[*index]
+
+	dbItem, err := data.GetItemByID(item.TPL)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if err := checkItemRepairable(item, dbItem); err != nil {
+		log.Println(err)
+		return
+	}
+
+	divisor, err := getRepairKitPointCost(dbItem)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	kits := make([]*data.InventoryItem, 0, len(repair.RepairKitsInfo))
+	var resource float64
+	for _, kitInfo := range repair.RepairKitsInfo {
+		kitIndex := invCache.GetIndexOfItemByID(kitInfo.ID)
+		if kitIndex == nil {
+			return
+		}
+
+		kit := &character.Inventory.Items[*kitIndex]
+		if kit.UPD == nil || kit.UPD.RepairKit == nil || float64(kit.UPD.RepairKit.Resource) < kitInfo.Count {
+			log.Printf(repairKitInsufficient, kitInfo.ID)
+			return
+		}
+		kits = append(kits, kit)
+		resource += kitInfo.Count
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	points := resource / divisor
+	if err := repairItemDurability(item, dbItem, points, true); err != nil {
+		log.Println(err)
+		return
+	}
+
+	for idx, kit := range kits {
+		kit.UPD.RepairKit.Resource -= int16(math.Round(repair.RepairKitsInfo[idx].Count))
+		changes.Items.Change = append(changes.Items.Change, *kit)
+	}
+	applyRepairBuff(character, item, dbItem, points)
+
+	if !isArmor(dbItem) {
+		skillPoints := data.GetGlobals().Config.SkillsSettings.WeaponTreatment.SkillPointsPerRepair
+		addSkillPoints(character, weaponTreatmentSkill, skillPoints)
+		changes.Skills = character.Skills
+	}
+
+	changes.Items.Change = append(changes.Items.Change, *item)
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+func isArmor(item *data.DatabaseItem) bool {
+	material, _ := item.Props["ArmorMaterial"].(string)
+	return material != ""
+}
+
+// getRepairKitPointCost returns how much repair kit resource it takes to restore one point of durability
+func getRepairKitPointCost(item *data.DatabaseItem) (float64, error) {
+	globals := data.GetGlobals().Config
+	if !isArmor(item) {
+		return globals.RepairSettings.DurabilityPointCostGuns, nil
+	}
+
+	materialName, _ := item.Props["ArmorMaterial"].(string)
+	material, ok := globals.ArmorMaterials[materialName]
+	if !ok {
+		return 0, fmt.Errorf(armorMaterialUnknown, materialName)
+	}
+
+	armorClass, ok := item.Props["armorClass"].(float64)
+	if class, isString := item.Props["armorClass"].(string); !ok && isString {
+		armorClass, _ = strconv.ParseFloat(class, 64)
+	}
+	classMultiplier := 1.0
+	if globals.RepairSettings.ArmorClassDivisor > 0 {
+		classMultiplier += armorClass / float64(globals.RepairSettings.ArmorClassDivisor)
+	}
+	return globals.RepairSettings.DurabilityPointCostArmor * (1 + material.Destructibility) * classMultiplier, nil
+}
+
+// checkItemRepairable returns an error if the item has no durability or is armor of an unknown material
+func checkItemRepairable(item *data.InventoryItem, dbItem *data.DatabaseItem) error {
+	if item.UPD == nil || item.UPD.Repairable == nil {
+		return fmt.Errorf(itemNotRepairable, item.ID)
+	}
+	if !isArmor(dbItem) {
+		return nil
+	}
+
+	materialName, _ := dbItem.Props["ArmorMaterial"].(string)
+	if _, ok := data.GetGlobals().Config.ArmorMaterials[materialName]; !ok {
+		return fmt.Errorf(armorMaterialUnknown, materialName)
+	}
+	return nil
+}
+
+// repairItemDurability restores points of durability to the item, then lowers its max durability by a random
+// share of the degradation range of its armor material, or of the weapon itself
+func repairItemDurability(item *data.InventoryItem, dbItem *data.DatabaseItem, points float64, repairKit bool) error {
+	if item.UPD == nil || item.UPD.Repairable == nil {
+		return fmt.Errorf(itemNotRepairable, item.ID)
+	}
+
+	var minDegradation, maxDegradation float64
+	if isArmor(dbItem) {
+		materialName, _ := dbItem.Props["ArmorMaterial"].(string)
+		material, ok := data.GetGlobals().Config.ArmorMaterials[materialName]
+		if !ok {
+			return fmt.Errorf(armorMaterialUnknown, materialName)
+		}
+
+		minDegradation, maxDegradation = material.MinRepairDegradation, material.MaxRepairDegradation
+		if repairKit {
+			minDegradation, maxDegradation = material.MinRepairKitDegradation, material.MaxRepairKitDegradation
+		}
+	} else {
+		minKey, maxKey := "MinRepairDegradation", "MaxRepairDegradation"
+		if repairKit {
+			minKey, maxKey = "MinRepairKitDegradation", "MaxRepairKitDegradation"
+		}
+		minDegradation, _ = dbItem.Props[minKey].(float64)
+		maxDegradation, _ = dbItem.Props[maxKey].(float64)
+	}
+
+	repairable := item.UPD.Repairable
+	templateMax, _ := dbItem.Props["MaxDurability"].(float64)
+	if templateMax == 0 {
+		templateMax = repairable.MaxDurability
+	}
+
+	maxDurability := min(repairable.MaxDurability+points, templateMax)
+	durability := min(repairable.Durability+points, maxDurability)
+
+	degradation := minDegradation + rand.Float64()*(maxDegradation-minDegradation)
+	wear := math.Round(degradation*maxDurability*100) / 100
+	repairable.MaxDurability = max(maxDurability-wear, 0)
+	repairable.Durability = min(durability, repairable.MaxDurability)
+	return nil
+}
+
+type repairBuffSettings struct {
+	CommonBuffChanceLevelBonus        float64 `json:"CommonBuffChanceLevelBonus"`
+	CommonBuffMinChanceValue          float64 `json:"CommonBuffMinChanceValue"`
+	CurrentDurabilityLossToRemoveBuff float64 `json:"CurrentDurabilityLossToRemoveBuff"`
+	MaxDurabilityLossToRemoveBuff     float64 `json:"MaxDurabilityLossToRemoveBuff"`
+	RareBuffChanceCoff                float64 `json:"RareBuffChanceCoff"`
+	ReceivedDurabilityMaxPercent      int     `json:"ReceivedDurabilityMaxPercent"`
+}
+
+// applyRepairBuff rolls the chance of the repair skill giving the item one of the buffs of its repair strategy,
+// the chance growing with the skill level and the share of durability restored
+func applyRepairBuff(character *data.Character[map[string]data.PlayerTradersInfo], item *data.InventoryItem, dbItem *data.DatabaseItem, points float64) {
+	globals := data.GetGlobals().Config
+	if int(character.Info.Level) < globals.RepairSettings.MinimumLevelToApplyBuff {
+		return
+	}
+
+	strategies := globals.RepairSettings.RepairStrategies
+	strategy := strategies.Firearms
+	skill := weaponTreatmentSkill
+	settings := repairBuffSettings(globals.SkillsSettings.WeaponTreatment.BuffSettings)
+	if isArmor(dbItem) {
+		strategy = strategies.Armor
+		skill = lightVestsSkill
+		settings = repairBuffSettings(globals.SkillsSettings.LightVests.BuffSettings)
+		if armorType, _ := dbItem.Props["ArmorType"].(string); armorType == "Heavy" {
+			skill = heavyVestsSkill
+			settings = repairBuffSettings(globals.SkillsSettings.HeavyVests.BuffSettings)
+		}
+	}
+	if len(strategy.BuffTypes) == 0 || !dbItem.IsChildOf(strategy.Filter) {
+		return
+	}
+
+	templateMax, _ := dbItem.Props["MaxDurability"].(float64)
+	if templateMax <= 0 {
+		return
+	}
+	durabilityMultiplier := 1.0
+	if settings.ReceivedDurabilityMaxPercent > 0 {
+		restored := points / templateMax * 100
+		durabilityMultiplier = min(max(restored/float64(settings.ReceivedDurabilityMaxPercent), 0.01), 1)
+	}
+
+	level := float64(getCharacterSkillLevel(character, skill))
+	chance := settings.CommonBuffMinChanceValue + settings.CommonBuffChanceLevelBonus*level*durabilityMultiplier
+	if rand.Float64() >= chance {
+		return
+	}
+
+	buff := &data.RepairBuff{
+		Rarity:   "Common",
+		BuffType: strategy.BuffTypes[rand.Intn(len(strategy.BuffTypes))],
+		Value:    commonBuffMinValue + rand.Float64()*(commonBuffMaxValue-commonBuffMinValue),
+	}
+	if rand.Float64() < settings.RareBuffChanceCoff {
+		buff.Rarity = "Rare"
+		buff.Value = rareBuffMinValue + rand.Float64()*(rareBuffMaxValue-rareBuffMinValue)
+	}
+	buff.Value = math.Round(buff.Value*100) / 100
+	buff.ThresholdDurability = math.Round(item.UPD.Repairable.Durability*(1-settings.MaxDurabilityLossToRemoveBuff)*100) / 100
+	item.UPD.Buff = buff
+}
@@ -0,0 +1,539 @@
+package pkg
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"mtgo/data"
+	"mtgo/tools"
+	"slices"
+
+	"github.com/goccy/go-json"
+)
+
+const (
+	eliminationQuest string = "Elimination"
+	completionQuest  string = "Completion"
+	explorationQuest string = "Exploration"
+
+	roublesTPL      string = "5449016a4bdc2d6f028b456f"
+	roublesMaxStack int    = 500000
+)
+
+const (
+	repeatableQuestNotFound string = "Repeatable quest %s does not exist\n"
+	noRepeatableTrader      string = "no trader gives %s repeatable quests"
+	noRepeatableElimination string = "no elimination parameters for level %d"
+	noRepeatableLocation    string = "no locations for %s repeatable quests"
+	noRepeatableItems       string = "no items to complete repeatable quests with"
+)
+
+// repeatableQuestTemplates are the quests whose locales the client uses for the text of each type
+var repeatableQuestTemplates = map[string]string{
+	eliminationQuest: "616052ea3054fc0e2c24ce6e",
+	completionQuest:  "61604635c725987e815b1a46",
+	explorationQuest: "616041eb031af660100c9967",
+}
+
+// GetRepeatableQuests regenerates every set of repeatable quests that has ended, returning the character's sets
+func GetRepeatableQuests(character *data.Character[map[string]data.PlayerTradersInfo]) []data.RepeatableQuestSet {
+	now := int(tools.GetCurrentTimeInSeconds())
+	for _, config := range data.GetRepeatableQuestConfigs() {
+		if character.Info.Level < config.MinPlayerLevel || len(config.Types) == 0 {
+			continue
+		}
+
+		set := getRepeatableQuestSet(character, config.Name)
+		if set.EndTime > now {
+			continue
+		}
+
+		ids := make([]string, 0, len(set.ActiveQuests))
+		for _, quest := range set.ActiveQuests {
+			ids = append(ids, quest.ID)
+		}
+		removeCharacterQuests(character, ids)
+
+		set.InactiveQuests = set.ActiveQuests
+		set.ActiveQuests = make([]data.RepeatableQuest, 0, config.NumQuests)
+		set.ChangeRequirement = make(map[string]data.RepeatableChangeRequirement)
+		set.FreeChanges = config.FreeChanges
+		set.FreeChangesAvailable = config.FreeChanges
+		set.EndTime = now + config.ResetTime
+
+		for range config.NumQuests {
+			quest, err := generateRepeatableQuest(character, config, config.Types[rand.Intn(len(config.Types))])
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			set.ActiveQuests = append(set.ActiveQuests, *quest)
+			set.ChangeRequirement[quest.ID] = data.RepeatableChangeRequirement{
+				ChangeCost:         quest.ChangeCost,
+				ChangeStandingCost: quest.ChangeStandingCost,
+			}
+		}
+	}
+
+	return character.RepeatableQuests
+}
+
+type repeatableQuestChange struct {
+	Action string
+	QID    string `json:"qid"`
+}
+
+// RepeatableQuestChange swaps the repeatable quest for a newly generated one, paying for it with a free
+// change if there is one left or with the roubles and standing the quest requires
+func RepeatableQuestChange(action map[string]any, sessionID string, event *data.ProfileChangesEvent) {
+	change := new(repeatableQuestChange)
+	input, err := json.MarshalNoEscape(action)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := json.UnmarshalNoEscape(input, &change); err != nil {
+		log.Println(err)
+		return
+	}
+
+	character, err := data.GetCharacterByID(sessionID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	set, index := getRepeatableQuestByID(character, change.QID)
+	if set == nil {
+		log.Printf(repeatableQuestNotFound, change.QID)
+		return
+	}
+
+	var config *data.RepeatableQuestConfig
+	for _, c := range data.GetRepeatableQuestConfigs() {
+		if c.Name == set.Name {
+			config = c
+			break
+		}
+	}
+	if config == nil {
+		log.Printf(repeatableQuestNotFound, change.QID)
+		return
+	}
+
+	changes, ok := event.ProfileChanges.Get(character.ID)
+	if !ok {
+		log.Println("Profile changes event does not exist")
+		return
+	}
+
+	replaced := set.ActiveQuests[index]
+	quest, err := generateRepeatableQuest(character, config, config.Types[rand.Intn(len(config.Types))])
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if set.FreeChangesAvailable > 0 {
+		set.FreeChangesAvailable--
+	} else {
+		restore, err := snapshotInventory(character, changes)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		requirement := set.ChangeRequirement[replaced.ID]
+		for _, cost := range requirement.ChangeCost {
+			if err := RemoveCurrencyFromInventory(character, cost.TemplateID, cost.Count, changes); err != nil {
+				log.Println(err)
+				restore()
+				return
+			}
+		}
+		if requirement.ChangeStandingCost > 0 {
+			addTraderStanding(character, replaced.TraderID, -requirement.ChangeStandingCost, changes)
+		}
+	}
+
+	removeCharacterQuests(character, []string{replaced.ID})
+	if set.ChangeRequirement == nil {
+		set.ChangeRequirement = make(map[string]data.RepeatableChangeRequirement)
+	}
+	delete(set.ChangeRequirement, replaced.ID)
+
+	set.ActiveQuests[index] = *quest
+	set.ChangeRequirement[quest.ID] = data.RepeatableChangeRequirement{
+		ChangeCost:         quest.ChangeCost,
+		ChangeStandingCost: quest.ChangeStandingCost,
+	}
+
+	sets := make([]any, 0, len(character.RepeatableQuests))
+	for _, s := range character.RepeatableQuests {
+		sets = append(sets, s)
+	}
+	changes.RepeatableQuests = &sets
+	event.ProfileChanges.Set(character.ID, changes)
+}
+
+// getQuestQuery returns the Query of the quest, looking through the character's repeatable quests if it
+// is not one from the database
+func getQuestQuery(character *data.Character[map[string]data.PlayerTradersInfo], qid string) *data.Query {
+	if query := data.GetQuestFromQueryByID(qid); query != nil {
+		return query
+	}
+
+	set, index := getRepeatableQuestByID(character, qid)
+	if set == nil {
+		return nil
+	}
+	return set.ActiveQuests[index].GetQuery()
+}
+
+func getRepeatableQuestByID(character *data.Character[map[string]data.PlayerTradersInfo], qid string) (*data.RepeatableQuestSet, int) {
+	for idx := range character.RepeatableQuests {
+		set := &character.RepeatableQuests[idx]
+		for index, quest := range set.ActiveQuests {
+			if quest.ID == qid {
+				return set, index
+			}
+		}
+	}
+	return nil, -1
+}
+
+// getRepeatableQuestSet returns the character's set of repeatable quests by name, creating it if it does not exist
+func getRepeatableQuestSet(character *data.Character[map[string]data.PlayerTradersInfo], name string) *data.RepeatableQuestSet {
+	for idx := range character.RepeatableQuests {
+		if character.RepeatableQuests[idx].Name == name {
+			return &character.RepeatableQuests[idx]
+		}
+	}
+
+	character.RepeatableQuests = append(character.RepeatableQuests, data.RepeatableQuestSet{
+		ID:                tools.GenerateMongoID(),
+		Name:              name,
+		ActiveQuests:      make([]data.RepeatableQuest, 0),
+		InactiveQuests:    make([]data.RepeatableQuest, 0),
+		ChangeRequirement: make(map[string]data.RepeatableChangeRequirement),
+	})
+	return &character.RepeatableQuests[len(character.RepeatableQuests)-1]
+}
+
+// removeCharacterQuests removes the quests from the character, reindexing their quest cache
+func removeCharacterQuests(character *data.Character[map[string]data.PlayerTradersInfo], qids []string) {
+	cachedQuests, err := data.GetQuestCacheByID(character.ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	character.Quests = slices.DeleteFunc(character.Quests, func(quest data.CharacterQuest) bool {
+		return slices.Contains(qids, quest.QID)
+	})
+
+	clear(cachedQuests.Index)
+	for index, quest := range character.Quests {
+		cachedQuests.Index[quest.QID] = int8(index)
+	}
+}
+
+// generateRepeatableQuest creates a repeatable quest of questType from a trader that gives them, with
+// rewards scaled to the character's level and how hard the quest is
+func generateRepeatableQuest(character *data.Character[map[string]data.PlayerTradersInfo], config *data.RepeatableQuestConfig, questType string) (*data.RepeatableQuest, error) {
+	traders := make([]string, 0, len(config.Traders))
+	for tid, types := range config.Traders {
+		if info, ok := character.TradersInfo[tid]; ok && info.Unlocked && slices.Contains(types, questType) {
+			traders = append(traders, tid)
+		}
+	}
+	if len(traders) == 0 {
+		return nil, fmt.Errorf(noRepeatableTrader, questType)
+	}
+	slices.Sort(traders)
+	traderID := traders[rand.Intn(len(traders))]
+
+	locations := make([]string, 0, len(config.Locations))
+	for name := range config.Locations {
+		locations = append(locations, name)
+	}
+	slices.Sort(locations)
+
+	quest := &data.RepeatableQuest{
+		ID:                         tools.GenerateMongoID(),
+		TraderID:                   traderID,
+		Location:                   "any",
+		Type:                       questType,
+		CanShowNotificationsInGame: true,
+		Side:                       "Pmc",
+		Conditions: map[string][]any{
+			data.ForStart:  {},
+			data.ForFinish: {},
+			data.Fail:      {},
+		},
+	}
+
+	var difficulty, itemsValue float64
+	switch questType {
+	case eliminationQuest:
+		elimination := config.GetEliminationConfig(character.Info.Level)
+		if elimination == nil {
+			return nil, fmt.Errorf(noRepeatableElimination, character.Info.Level)
+		}
+
+		kills := tools.GetRandomInt(elimination.MinKills, elimination.MaxKills)
+		counterConditions := []any{createKillsCondition(pickWeighted(elimination.Targets))}
+		if len(locations) != 0 && rand.Intn(100) < elimination.LocationChance {
+			name := locations[rand.Intn(len(locations))]
+			quest.Location = config.Locations[name]
+			counterConditions = append(counterConditions, createLocationCondition(name))
+		}
+
+		quest.Conditions[data.ForFinish] = []any{createCounterCreatorCondition(eliminationQuest, counterConditions, kills)}
+		difficulty = float64(kills) / float64(elimination.MaxKills)
+	case completionQuest:
+		completion := config.Completion
+		pool := slices.DeleteFunc(data.GetItemsByFilter(completion.ItemCategories, nil), func(tpl string) bool {
+			return !data.IsObtainableItem(tpl)
+		})
+		if len(pool) == 0 {
+			return nil, fmt.Errorf(noRepeatableItems)
+		}
+
+		amount := tools.GetRandomInt(completion.MinItems, completion.MaxItems)
+		handovers := make([]any, 0, amount)
+		var totalCount int
+		for range amount {
+			tpl := pool[rand.Intn(len(pool))]
+			count := tools.GetRandomInt(completion.MinCount, completion.MaxCount)
+			totalCount += count
+			if price, err := data.GetPriceByID(tpl); err == nil {
+				itemsValue += float64(price) * float64(count)
+			}
+			handovers = append(handovers, createHandoverCondition(tpl, count, completion.FoundInRaid))
+		}
+
+		quest.Conditions[data.ForFinish] = handovers
+		difficulty = float64(totalCount) / float64(completion.MaxItems*completion.MaxCount)
+	case explorationQuest:
+		if len(locations) == 0 {
+			return nil, fmt.Errorf(noRepeatableLocation, explorationQuest)
+		}
+
+		name := locations[rand.Intn(len(locations))]
+		quest.Location = config.Locations[name]
+		extracts := tools.GetRandomInt(config.Exploration.MinExtracts, config.Exploration.MaxExtracts)
+		counterConditions := []any{createLocationCondition(name), createExitStatusCondition()}
+
+		quest.Conditions[data.ForFinish] = []any{createCounterCreatorCondition(explorationQuest, counterConditions, extracts)}
+		difficulty = float64(extracts) / float64(config.Exploration.MaxExtracts)
+	}
+
+	quest.Rewards = generateRepeatableRewards(character, config, traderID, difficulty, itemsValue)
+
+	index := config.GetRewardScalingIndex(character.Info.Level)
+	quest.ChangeCost = []data.RepeatableChangeCost{{TemplateID: roublesTPL, Count: config.RewardScaling.RerollCost[index]}}
+	quest.ChangeStandingCost = config.RewardScaling.RerollStandingCost[index]
+
+	template := repeatableQuestTemplates[questType]
+	quest.TemplateID = template
+	quest.Name = fmt.Sprintf("%s name", template)
+	quest.Note = fmt.Sprintf("%s note", template)
+	quest.Description = fmt.Sprintf("%s description %s 0", template, traderID)
+	quest.SuccessMessageText = fmt.Sprintf("%s successMessageText %s 0", template, traderID)
+	quest.FailMessageText = fmt.Sprintf("%s failMessageText %s 0", template, traderID)
+	quest.StartedMessageText = fmt.Sprintf("%s startedMessageText %s 0", template, traderID)
+	quest.ChangeQuestMessageText = fmt.Sprintf("%s changeQuestMessageText %s 0", template, traderID)
+	quest.AcceptPlayerMessage = fmt.Sprintf("%s acceptPlayerMessage", template)
+	quest.DeclinePlayerMessage = fmt.Sprintf("%s declinePlayerMessage", template)
+	quest.CompletePlayerMessage = fmt.Sprintf("%s completePlayerMessage", template)
+
+	return quest, nil
+}
+
+// generateRepeatableRewards gives experience as a share of what the character needs for their next level,
+// then spends the roubles of their level bracket, plus the handbook value of anything handed over, on reward items
+func generateRepeatableRewards(character *data.Character[map[string]data.PlayerTradersInfo], config *data.RepeatableQuestConfig, traderID string, difficulty float64, itemsValue float64) map[string][]any {
+	scaling := config.RewardScaling
+	index := config.GetRewardScalingIndex(character.Info.Level)
+	difficulty = min(max(difficulty, 0.2), 1)
+
+	rewards := make([]any, 0)
+
+	expTable := data.GetGlobals().Config.Exp.Level.ExpTable
+	if len(expTable) != 0 {
+		nextLevel := expTable[min(int(character.Info.Level), len(expTable)-1)].Exp
+		experience := math.Round(float64(nextLevel) * scaling.Experience[index] * difficulty)
+		rewards = append(rewards, map[string]any{
+			"id":    tools.GenerateMongoID(),
+			"type":  "Experience",
+			"value": experience,
+			"index": float64(len(rewards)),
+		})
+	}
+
+	roubles := math.Round(scaling.Roubles[index]*difficulty + itemsValue)
+	pool := slices.DeleteFunc(data.GetItemsByFilter(config.RewardItemCategories, nil), func(tpl string) bool {
+		return !data.IsObtainableItem(tpl)
+	})
+	if len(pool) != 0 {
+		itemBudget := roubles / float64(scaling.Items[index]+1)
+		for range scaling.Items[index] {
+			tpl := pool[rand.Intn(len(pool))]
+			price, err := data.GetPriceByID(tpl)
+			if err != nil || float64(price) > itemBudget {
+				continue
+			}
+			roubles -= float64(price)
+			rewards = append(rewards, createItemReward(tpl, 1, len(rewards)))
+		}
+	}
+
+	stackSize := roublesMaxStack
+	if item, err := data.GetItemByID(roublesTPL); err == nil {
+		stackSize = int(item.GetStackMaxSize())
+	}
+	for remaining := int(roubles); remaining > 0; remaining -= stackSize {
+		rewards = append(rewards, createItemReward(roublesTPL, min(remaining, stackSize), len(rewards)))
+	}
+
+	rewards = append(rewards, map[string]any{
+		"id":     tools.GenerateMongoID(),
+		"type":   "TraderStanding",
+		"target": traderID,
+		"value":  scaling.Reputation[index],
+		"index":  float64(len(rewards)),
+	})
+
+	return map[string][]any{
+		data.Started: {},
+		data.Success: rewards,
+		data.Fail:    {},
+	}
+}
+
+func createItemReward(tpl string, count int, index int) map[string]any {
+	id := tools.GenerateMongoID()
+	return map[string]any{
+		"id":         tools.GenerateMongoID(),
+		"type":       "Item",
+		"target":     id,
+		"value":      float64(count),
+		"findInRaid": true,
+		"index":      float64(index),
+		"items": []any{
+			map[string]any{
+				"_id":  id,
+				"_tpl": tpl,
+				"upd":  map[string]any{"StackObjectsCount": float64(count)},
+			},
+		},
+	}
+}
+
+func createCounterCreatorCondition(questType string, counterConditions []any, value int) map[string]any {
+	return map[string]any{
+		"id":                           tools.GenerateMongoID(),
+		"conditionType":                "CounterCreator",
+		"type":                         questType,
+		"value":                        float64(value),
+		"completeInSeconds":            float64(0),
+		"doNotResetIfCounterCompleted": false,
+		"dynamicLocale":                true,
+		"globalQuestCounterId":         "",
+		"index":                        float64(0),
+		"oneSessionOnly":               false,
+		"parentId":                     "",
+		"visibilityConditions":         []any{},
+		"counter": map[string]any{
+			"id":         tools.GenerateMongoID(),
+			"conditions": counterConditions,
+		},
+	}
+}
+
+func createKillsCondition(target string) map[string]any {
+	return map[string]any{
+		"id":                      tools.GenerateMongoID(),
+		"conditionType":           "Kills",
+		"target":                  target,
+		"value":                   float64(1),
+		"compareMethod":           ">=",
+		"dynamicLocale":           true,
+		"resetOnSessionEnd":       false,
+		"bodyPart":                []any{},
+		"daytime":                 map[string]any{"from": float64(0), "to": float64(0)},
+		"distance":                map[string]any{"compareMethod": ">=", "value": float64(0)},
+		"enemyEquipmentExclusive": []any{},
+		"enemyEquipmentInclusive": []any{},
+		"enemyHealthEffects":      []any{},
+		"savageRole":              []any{},
+		"weapon":                  []any{},
+		"weaponCaliber":           []any{},
+		"weaponModsExclusive":     []any{},
+		"weaponModsInclusive":     []any{},
+	}
+}
+
+func createLocationCondition(location string) map[string]any {
+	return map[string]any{
+		"id":            tools.GenerateMongoID(),
+		"conditionType": "Location",
+		"target":        []any{location},
+		"dynamicLocale": true,
+	}
+}
+
+func createExitStatusCondition() map[string]any {
+	return map[string]any{
+		"id":            tools.GenerateMongoID(),
+		"conditionType": "ExitStatus",
+		"status":        []any{"Survived"},
+		"dynamicLocale": true,
+	}
+}
+
+func createHandoverCondition(tpl string, count int, foundInRaid bool) map[string]any {
+	return map[string]any{
+		"id":                   tools.GenerateMongoID(),
+		"conditionType":        "HandoverItem",
+		"target":               []any{tpl},
+		"value":                float64(count),
+		"onlyFoundInRaid":      foundInRaid,
+		"dynamicLocale":        true,
+		"index":                float64(0),
+		"parentId":             "",
+		"isEncoded":            false,
+		"minDurability":        float64(0),
+		"maxDurability":        float64(100),
+		"dogtagLevel":          float64(0),
+		"visibilityConditions": []any{},
+	}
+}
+
+// pickWeighted returns a random key of weights, each being picked in proportion to its weight
+func pickWeighted(weights map[string]int) string {
+	keys := make([]string, 0, len(weights))
+	total := 0
+	for key, weight := range weights {
+		keys = append(keys, key)
+		total += weight
+	}
+	slices.Sort(keys)
+	if len(keys) == 0 {
+		return "Any"
+	}
+	if total <= 0 {
+		return keys[rand.Intn(len(keys))]
+	}
+
+	roll := rand.Intn(total)
+	for _, key := range keys {
+		roll -= weights[key]
+		if roll < 0 {
+			return key
+		}
+	}
+	return keys[len(keys)-1]
+}
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"errors"
 	"io"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
 )
 
 type ResponseBody struct {
@@ -26,20 +28,43 @@ type CRCResponseBody struct {
 }
 
 const (
-	cookieHeader string = "Cookie"
+	phpSessionID string = "PHPSESSID"
 	prod         string = "https://prod.escapefromtarkov.com"
 	imagesPath   string = "assets/images/"
 )
 
-// GetSessionID returns current sessionID from the header, if available
+type sessionKey struct{}
+
+// GetSessionID returns the ID of the profile the request's session token was issued for, as validated by the
+// server's middleware
 func GetSessionID(r *http.Request) (string, error) {
-	output := r.Header.Get(cookieHeader)[10:]
+	output, _ := r.Context().Value(sessionKey{}).(string)
 	if output == "" {
-		return "", errors.New("cookie header is empty")
+		return "", errors.New("request has no valid session")
 	}
 	return output, nil
 }
 
+const sessionTokenParam string = "token"
+
+// GetSessionToken returns the session token from the request's cookie, or from the token query parameter of
+// websocket upgrades, which may come without the cookie
+func GetSessionToken(r *http.Request) string {
+	cookie, err := r.Cookie(phpSessionID)
+	if err == nil {
+		return cookie.Value
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		return r.URL.Query().Get(sessionTokenParam)
+	}
+	return ""
+}
+
+// WithSessionID returns the request carrying the ID of the profile its session was issued for
+func WithSessionID(r *http.Request, sessionID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionKey{}, sessionID))
+}
+
 // ApplyCRCResponseBody appends data to CRCResponseBody and returns it
 func ApplyCRCResponseBody(data any, crc *uint32) *CRCResponseBody {
 	return &CRCResponseBody{
@@ -59,6 +84,14 @@ func ApplyResponseBody(data any) *ResponseBody {
 	}
 }
 
+// ApplyErrorBody returns a ResponseBody carrying the error instead of data
+func ApplyErrorBody(err error) *ResponseBody {
+	body := ApplyResponseBody(nil)
+	body.Err = 1
+	body.Errmsg = err.Error()
+	return body
+}
+
 var mime = map[string]string{
 	".png": "image/png",
 	".jpg": "image/jpeg",
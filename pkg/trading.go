@@ -2,9 +2,14 @@ package pkg
 
 import (
 	"fmt"
+	"maps"
+	"math"
 	"mtgo/data"
 	"net/http"
+	"slices"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -32,6 +37,56 @@ func GetCorrectAmountOfItemsPurchased(amountPurchased int32, itemStackSize int32
 	return stackSlice
 }
 
+// RemoveCurrencyFromInventory takes amount of currency from the character's stash, splitting stacks where
+// required; nothing is removed if the character cannot afford it
+func RemoveCurrencyFromInventory(character *data.Character[map[string]data.PlayerTradersInfo], currency string, amount int32, changes *data.ProfileChanges) error {
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		return err
+	}
+
+	var total int32
+	for _, item := range character.Inventory.Items {
+		if item.TPL == currency && item.UPD != nil {
+			total += item.UPD.StackObjectsCount
+		}
+	}
+	if total < amount {
+		return fmt.Errorf(insufficientFunds, amount, currency, total)
+	}
+
+	remaining := amount
+	toDelete := make([]int16, 0)
+	for idx, item := range character.Inventory.Items {
+		if remaining == 0 {
+			break
+		}
+		if item.TPL != currency || item.UPD == nil {
+			continue
+		}
+
+		if item.UPD.StackObjectsCount > remaining {
+			character.Inventory.Items[idx].UPD.StackObjectsCount -= remaining
+			remaining = 0
+			changes.Items.Change = append(changes.Items.Change, character.Inventory.Items[idx])
+			break
+		}
+
+		remaining -= item.UPD.StackObjectsCount
+		toDelete = append(toDelete, int16(idx))
+		invCache.ClearItemFromContainer(item.ID)
+		changes.Items.Del = append(changes.Items.Del, data.InventoryItem{ID: item.ID})
+	}
+
+	if len(toDelete) != 0 {
+		character.Inventory.RemoveItemsFromInventoryByIndices(toDelete)
+		invCache.SetInventoryIndex(&character.Inventory)
+	}
+	return nil
+}
+
+const insufficientFunds string = "Insufficient funds, %d of %s required but only %d available"
+
 func GetSuitesStorage(sessionID string) (map[string]any, error) {
 	storage, err := data.GetStorageByID(sessionID)
 	if err != nil {
@@ -72,6 +127,17 @@ func GetTraderSuits(id string) ([]data.TraderSuits, error) {
 	return nil, fmt.Errorf("trader %s suits does not exist", id)
 }
 
+const traderRestockTick = 10 * time.Second
+
+// StartTraderRestock periodically restocks the traders whose resupply time has passed, notifying every player
+func StartTraderRestock() {
+	startJob(traderRestockTick, func() {
+		if data.RestockTraders() {
+			BroadcastNotification(data.CreateTraderSupplyNotification(data.GetNextResupply()))
+		}
+	})
+}
+
 func GetTraderAssort(r *http.Request) (*data.Assort, error) {
 	trader, err := data.GetTraderByUID(chi.URLParam(r, "id"))
 	if err != nil {
@@ -95,3 +161,401 @@ func GetTraderAssort(r *http.Request) (*data.Assort, error) {
 
 	return assort, nil
 }
+
+// RemoveItemsFromInventory removes the items of the given IDs, along with everything attached to them, from the
+// character's inventory and returns what was removed
+func RemoveItemsFromInventory(character *data.Character[map[string]data.PlayerTradersInfo], ids []string, changes *data.ProfileChanges) ([]data.InventoryItem, error) {
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	family := make(map[string]struct{})
+	for _, id := range ids {
+		if invCache.GetIndexOfItemByID(id) == nil {
+			return nil, fmt.Errorf(itemNotInInventory, id)
+		}
+		for _, member := range data.GetInventoryItemFamilyTreeIDs(character.Inventory.Items, id) {
+			family[member] = struct{}{}
+		}
+	}
+
+	removed := make([]data.InventoryItem, 0, len(family))
+	indices := make([]int16, 0, len(family))
+	for idx, item := range character.Inventory.Items {
+		if _, ok := family[item.ID]; !ok {
+			continue
+		}
+		removed = append(removed, item)
+		indices = append(indices, int16(idx))
+		invCache.ClearItemFromContainer(item.ID)
+	}
+
+	for _, id := range ids {
+		changes.Items.Del = append(changes.Items.Del, data.InventoryItem{ID: id})
+	}
+
+	character.Inventory.RemoveItemsFromInventoryByIndices(indices)
+	invCache.SetInventoryIndex(&character.Inventory)
+	return removed, nil
+}
+
+const itemNotInInventory string = "Item %s does not exist in inventory"
+
+// RemoveItemCountsFromInventory takes the count of each given item from the character's inventory, removing
+// the item along with everything attached to it once its stack runs out
+func RemoveItemCountsFromInventory(character *data.Character[map[string]data.PlayerTradersInfo], items []tradingScheme, changes *data.ProfileChanges) error {
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, scheme := range items {
+		index := invCache.GetIndexOfItemByID(scheme.ID)
+		if index == nil {
+			return fmt.Errorf(itemNotInInventory, scheme.ID)
+		}
+
+		stack := int32(1)
+		if upd := character.Inventory.Items[*index].UPD; upd != nil && upd.StackObjectsCount > 1 {
+			stack = upd.StackObjectsCount
+		}
+		if scheme.Count > stack {
+			return fmt.Errorf(insufficientItemCount, scheme.Count, scheme.ID, stack)
+		}
+	}
+
+	toRemove := make([]string, 0)
+	for _, scheme := range items {
+		index := *invCache.GetIndexOfItemByID(scheme.ID)
+		item := &character.Inventory.Items[index]
+
+		if item.UPD != nil && item.UPD.StackObjectsCount > scheme.Count {
+			item.UPD.StackObjectsCount -= scheme.Count
+			changes.Items.Change = append(changes.Items.Change, *item)
+			continue
+		}
+		toRemove = append(toRemove, scheme.ID)
+	}
+
+	if len(toRemove) == 0 {
+		return nil
+	}
+	_, err = RemoveItemsFromInventory(character, toRemove, changes)
+	return err
+}
+
+const insufficientItemCount string = "%d of %s required but only %d available"
+
+// AddItemsToInventory places every root item of items, along with everything attached to it, in a free spot of
+// the character's stash; nothing is added if any of them does not fit
+func AddItemsToInventory(character *data.Character[map[string]data.PlayerTradersInfo], items []data.InventoryItem, changes *data.ProfileChanges) error {
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		ids[item.ID] = struct{}{}
+	}
+
+	copyOfMap := slices.Clone(invCache.Stash.Container.Map)
+	copyOfFlatMap := maps.Clone(invCache.Stash.Container.FlatMap)
+	restore := func() {
+		invCache.Stash.Container.Map = copyOfMap
+		invCache.Stash.Container.FlatMap = copyOfFlatMap
+	}
+
+	toAdd := make([]data.InventoryItem, 0, len(items))
+	for _, root := range items {
+		if _, ok := ids[root.ParentID]; ok {
+			continue
+		}
+
+		root.ParentID = character.Inventory.Stash
+		root.SlotID = "hideout"
+
+		family := make([]data.InventoryItem, 0)
+		members := data.GetInventoryItemFamilyTreeIDs(items, root.ID)
+		for _, item := range items {
+			if item.ID != root.ID && slices.Contains(members, item.ID) {
+				family = append(family, item)
+			}
+		}
+		family = append(family, root)
+
+		height, width := data.MeasurePurchaseForInventoryMapping(family)
+		if height == -1 {
+			restore()
+			return fmt.Errorf(itemNotPlaced, root.ID)
+		}
+
+		validLocation := invCache.GetValidLocationForItem(height, width)
+		if validLocation == nil {
+			restore()
+			return fmt.Errorf(itemNotPlaced, root.ID)
+		}
+
+		family[len(family)-1].Location = &data.InventoryItemLocation{
+			IsSearched: true,
+			R:          float64(0),
+			X:          float64(validLocation.X),
+			Y:          float64(validLocation.Y),
+		}
+
+		itemFlatMap := invCache.CreateFlatMapLookup(height, width, &family[len(family)-1])
+		itemFlatMap.Coordinates = validLocation.MapInfo
+		invCache.AddItemToContainer(root.ID, itemFlatMap)
+
+		toAdd = append(toAdd, family...)
+	}
+
+	character.Inventory.Items = append(character.Inventory.Items, toAdd...)
+	changes.Items.New = append(changes.Items.New, toAdd...)
+	invCache.SetInventoryIndex(&character.Inventory)
+	return nil
+}
+
+const itemNotPlaced string = "Item %s could not be placed because there is no room in the stash"
+
+// barterPayment is what a barter takes from the character's inventory: amounts of currency, taken from any of
+// their stacks, and counts of the items they offered
+type barterPayment struct {
+	currencies map[string]int32
+	items      []tradingScheme
+	counts     map[string]int32
+}
+
+// getBarterPayment matches the items the character offered against the barter scheme bought count times;
+// nothing is taken from the inventory
+func getBarterPayment(character *data.Character[map[string]data.PlayerTradersInfo], scheme []*data.Scheme, offered []tradingScheme, count int32) (*barterPayment, error) {
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &barterPayment{
+		currencies: make(map[string]int32),
+		items:      make([]tradingScheme, 0, len(offered)),
+		counts:     make(map[string]int32),
+	}
+
+	requirements := make([]*data.Scheme, 0, len(scheme))
+	remaining := make([]int32, 0, len(scheme))
+	for _, requirement := range scheme {
+		amount := int32(math.Ceil(float64(requirement.Count) * float64(count)))
+		if data.IsCurrencyByID(requirement.Tpl) {
+			payment.currencies[requirement.Tpl] += amount
+			continue
+		}
+		requirements = append(requirements, requirement)
+		remaining = append(remaining, amount)
+	}
+
+	taken := make(map[string]int32, len(offered))
+	for _, offer := range offered {
+		index := invCache.GetIndexOfItemByID(offer.ID)
+		if index == nil {
+			return nil, fmt.Errorf(itemNotInInventory, offer.ID)
+		}
+
+		item := character.Inventory.Items[*index]
+		if data.IsCurrencyByID(item.TPL) {
+			if _, ok := payment.currencies[item.TPL]; ok {
+				continue
+			}
+		}
+
+		stack := int32(1)
+		if item.UPD != nil && item.UPD.StackObjectsCount > 1 {
+			stack = item.UPD.StackObjectsCount
+		}
+
+		// the offer goes to every requirement of its template it meets, in order, until it runs out
+		var required bool
+		var rejected error
+		left := offer.Count
+		for idx, requirement := range requirements {
+			if requirement.Tpl != item.TPL {
+				continue
+			}
+			required = true
+			if remaining[idx] == 0 || left <= 0 {
+				continue
+			}
+			if err := checkBarterRequirement(character.Inventory.Items, &item, requirement); err != nil {
+				rejected = err
+				continue
+			}
+
+			amount := min(left, remaining[idx])
+			if taken[offer.ID]+amount > stack {
+				return nil, fmt.Errorf(insufficientItemCount, taken[offer.ID]+amount, offer.ID, stack)
+			}
+
+			left -= amount
+			taken[offer.ID] += amount
+			remaining[idx] -= amount
+			payment.counts[item.TPL] += amount
+			payment.items = append(payment.items, tradingScheme{ID: offer.ID, Count: amount})
+		}
+
+		if !required {
+			return nil, fmt.Errorf(itemNotInBarter, offer.ID)
+		}
+		if rejected != nil && left == offer.Count {
+			return nil, rejected
+		}
+	}
+
+	for idx, amount := range remaining {
+		if amount > 0 {
+			return nil, fmt.Errorf(barterItemsMissing, amount, requirements[idx].Tpl)
+		}
+	}
+	return payment, nil
+}
+
+const (
+	itemNotInBarter      string = "Item %s is not required by the barter"
+	barterItemsMissing   string = "%d more of %s are required by the barter"
+	barterNotFoundInRaid string = "Item %s is not found in raid as the barter requires"
+	barterDogtag         string = "Dogtag %s does not meet the barter's level %d and side %s"
+	barterNotFunctional  string = "Item %s is missing parts the barter requires"
+)
+
+// checkBarterRequirement returns an error if the item is not found in raid, not a dogtag of the level and side, or
+// not functional, where the barter requirement demands it
+func checkBarterRequirement(inventory []data.InventoryItem, item *data.InventoryItem, requirement *data.Scheme) error {
+	if requirement.FoundInRaid && (item.UPD == nil || !item.UPD.SpawnedInSession) {
+		return fmt.Errorf(barterNotFoundInRaid, item.ID)
+	}
+
+	if requirement.Level != 0 || (requirement.Side != "" && requirement.Side != "Any") {
+		if item.UPD == nil || item.UPD.Dogtag == nil {
+			return fmt.Errorf(barterDogtag, item.ID, requirement.Level, requirement.Side)
+		}
+
+		dogtag := item.UPD.Dogtag
+		if dogtag.Level < requirement.Level {
+			return fmt.Errorf(barterDogtag, item.ID, requirement.Level, requirement.Side)
+		}
+		if requirement.Side != "" && requirement.Side != "Any" && !strings.EqualFold(dogtag.Side, requirement.Side) {
+			return fmt.Errorf(barterDogtag, item.ID, requirement.Level, requirement.Side)
+		}
+	}
+
+	if requirement.OnlyFunctional && !isItemFunctional(inventory, item) {
+		return fmt.Errorf(barterNotFunctional, item.ID)
+	}
+	return nil
+}
+
+// isItemFunctional returns whether every required slot of the item is filled
+func isItemFunctional(inventory []data.InventoryItem, item *data.InventoryItem) bool {
+	dbItem, err := data.GetItemByID(item.TPL)
+	if err != nil {
+		return false
+	}
+	if slots, _ := dbItem.Props["Slots"].([]any); len(slots) == 0 {
+		return true
+	}
+
+	filled := make(map[string]struct{})
+	for _, child := range inventory {
+		if child.ParentID == item.ID {
+			filled[child.SlotID] = struct{}{}
+		}
+	}
+
+	for name, slot := range dbItem.GetItemSlots() {
+		if _, ok := filled[name]; slot.Required && !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// getValue returns what the payment is worth in the trader's currency
+func (p *barterPayment) getValue(trader *data.Trader) (float32, error) {
+	currency := *data.GetCurrencyByName(trader.Base.Currency)
+
+	var value float64
+	var roubles int32
+	for tpl, amount := range p.currencies {
+		if tpl == currency {
+			value += float64(amount)
+			continue
+		}
+		converted, err := data.ConvertToRouble(amount, tpl)
+		if err != nil {
+			return 0, err
+		}
+		roubles += int32(converted)
+	}
+	for tpl, amount := range p.counts {
+		price, err := data.GetPriceByID(tpl)
+		if err != nil {
+			return 0, err
+		}
+		roubles += price * amount
+	}
+
+	if roubles == 0 {
+		return float32(value), nil
+	}
+	if currency == *data.GetCurrencyByName("RUB") {
+		return float32(value + float64(roubles)), nil
+	}
+
+	conversion, err := data.ConvertFromRouble(roubles, currency)
+	if err != nil {
+		return 0, err
+	}
+	return float32(value + conversion), nil
+}
+
+// take removes the payment from the character's inventory
+func (p *barterPayment) take(character *data.Character[map[string]data.PlayerTradersInfo], changes *data.ProfileChanges) error {
+	for currency, amount := range p.currencies {
+		if err := RemoveCurrencyFromInventory(character, currency, amount, changes); err != nil {
+			return err
+		}
+	}
+	if len(p.items) == 0 {
+		return nil
+	}
+	return RemoveItemCountsFromInventory(character, p.items, changes)
+}
+
+// snapshotInventory saves the character's inventory, stash container and profile changes, returning a function
+// that restores them
+func snapshotInventory(character *data.Character[map[string]data.PlayerTradersInfo], changes *data.ProfileChanges) (func(), error) {
+	invCache, err := data.GetInventoryCacheByID(character.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := slices.Clone(character.Inventory.Items)
+	stacks := make(map[*data.ItemUpdate]int32, len(items))
+	for _, item := range items {
+		if item.UPD != nil {
+			stacks[item.UPD] = item.UPD.StackObjectsCount
+		}
+	}
+	containerMap := slices.Clone(invCache.Stash.Container.Map)
+	flatMap := maps.Clone(invCache.Stash.Container.FlatMap)
+	savedChanges := *changes
+
+	return func() {
+		for upd, count := range stacks {
+			upd.StackObjectsCount = count
+		}
+		character.Inventory.Items = items
+		invCache.Stash.Container.Map = containerMap
+		invCache.Stash.Container.FlatMap = flatMap
+		invCache.SetInventoryIndex(&character.Inventory)
+		*changes = savedChanges
+	}, nil
+}
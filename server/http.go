@@ -1,18 +1,29 @@
 package server
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"mtgo/data"
 	"mtgo/pkg"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
+const shutdownTimeout = 10 * time.Second
+
+// servers are the running HTTP servers, stopped by Shutdown
+var servers = struct {
+	sync.Mutex
+	list []*http.Server
+}{}
+
 type muxt struct {
 	address    string
 	serverName string
@@ -48,6 +59,11 @@ func Start() {
 	pkg.SetDownloadLocal(serverConfig.DownloadImageFiles)
 	pkg.SetChannelTemplate()
 	pkg.SetGameConfig()
+	pkg.StartRagfairSimulation()
+	pkg.StartInsuranceReturns()
+	pkg.StartMailExpiry()
+	pkg.StartTraderRestock()
+	pkg.StartHideoutNotifications()
 
 	if serverConfig.Secure {
 		cert := GetCertificate(serverConfig.IP)
@@ -77,21 +93,24 @@ func startInsecure(serverReady chan<- struct{}, mux *muxt) {
 
 	r.Use(middleware.URLFormat)
 	r.Use(logRoute)
+	r.Use(authenticate)
+	r.Use(lockProfile)
 	r.Use(handleWebSocketUpgrade)
 	r.Use(decompress)
 	mux.initRoutes(r)
 
-	server := http.Server{
+	server := &http.Server{
 		Addr:              mux.address,
 		ReadTimeout:       time.Second * 5,
 		ReadHeaderTimeout: time.Second * 5,
 		Handler:           r,
 	}
+	addServer(server)
 
 	fmt.Println("Started " + mux.serverName + " HTTP server on " + mux.address)
 	serverReady <- struct{}{}
 
-	if err := server.ListenAndServe(); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalln(err)
 	}
 }
@@ -101,6 +120,8 @@ func startSecure(serverReady chan<- struct{}, certs *Certificate, mux *muxt) {
 
 	r.Use(middleware.URLFormat)
 	r.Use(logRoute)
+	r.Use(authenticate)
+	r.Use(lockProfile)
 	r.Use(handleWebSocketUpgrade)
 	r.Use(decompress)
 
@@ -118,12 +139,58 @@ func startSecure(serverReady chan<- struct{}, certs *Certificate, mux *muxt) {
 		ReadHeaderTimeout: time.Second * 5,
 		Handler:           r,
 	}
+	addServer(httpsServer)
 
 	fmt.Println("Started " + mux.serverName + " HTTPS server on " + mux.address)
 	serverReady <- struct{}{}
 
 	err := httpsServer.ListenAndServeTLS(certs.CertFile, certs.KeyFile)
-	if err != nil {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalln(err)
 	}
 }
+
+func addServer(server *http.Server) {
+	servers.Lock()
+	defer servers.Unlock()
+	servers.list = append(servers.list, server)
+}
+
+// Shutdown stops accepting requests, waits up to shutdownTimeout for the ones in flight, closes the websockets and
+// stops the background jobs, then saves every profile and the response cache
+func Shutdown() {
+	fmt.Println("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	servers.Lock()
+	var wg sync.WaitGroup
+	for _, server := range servers.list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+	servers.list = nil
+	servers.Unlock()
+
+	data.CloseConnections()
+	wg.Wait()
+
+	pkg.StopBackgroundJobs()
+
+	data.GetProfiles().ForEach(func(_ string, profile *data.Profile) bool {
+		if profile.Character != nil {
+			pkg.UpdateHideoutProductions(profile.Character)
+		}
+		return true
+	})
+	data.SaveProfiles()
+	data.GetCachedResponses().SaveIfRequired()
+
+	fmt.Println("Shutdown complete")
+}
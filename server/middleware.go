@@ -1,13 +1,14 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"log"
 	"mtgo/data"
 	"mtgo/pkg"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/gorilla/websocket"
@@ -19,38 +20,91 @@ var upgrader = websocket.Upgrader{
 }
 
 func upgradeToWebsocket(w http.ResponseWriter, r *http.Request) {
+	if sessionID, err := pkg.GetSessionID(r); err != nil || sessionID != getChannelID(r) {
+		http.Error(w, "websocket channel does not belong to the session", http.StatusForbidden)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer conn.Close()
 
-	sessionID := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
-	data.SetConnection(sessionID, conn)
+	sessionID := getChannelID(r)
+	connection := data.SetConnection(sessionID, conn)
 
+	go connection.WritePump()
 	go func() {
-		defer data.DeleteConnection(sessionID)
-		defer conn.Close()
+		defer data.DeleteConnection(sessionID, connection)
+		connection.ReadPump()
+	}()
+
+	if err := pkg.SendQueuedMessagesToPlayer(sessionID); err != nil {
+		log.Println(err)
+	}
+}
 
-		err = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+// getChannelID returns the ID of the profile whose notifier channel is requested
+func getChannelID(r *http.Request) string {
+	return r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+}
+
+// publicRoutes are served without a session
+var publicRoutes = []string{
+	"/files/",
+	"/launcher/",
+}
+
+// authenticate rejects requests without a valid session token, and passes the ID of the profile the token was
+// issued for on to the handlers
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range publicRoutes {
+			if strings.HasPrefix(r.URL.Path, route) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		sessionID, err := data.GetSessionProfileID(pkg.GetSessionToken(r))
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
-		for {
-			messageType, p, err := conn.ReadMessage()
-			if err != nil {
-				log.Println(err)
-				return
-			}
 
-			err = conn.WriteMessage(messageType, p)
-			if err != nil {
-				log.Println(err)
-				return
-			}
+		next.ServeHTTP(w, pkg.WithSessionID(r, sessionID))
+	})
+}
+
+// longPollRoute waits for notifications, so it is served without holding the profile
+const longPollRoute string = "/push/notifier/get/"
+
+// lockProfile holds the session's profile for the whole request, so the handlers never run alongside a background
+// job or another request changing the same profile
+func lockProfile(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, longPollRoute) {
+			next.ServeHTTP(w, r)
+			return
 		}
-	}()
+
+		sessionID, err := pkg.GetSessionID(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		profile, err := data.GetProfileByUID(sessionID)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		profile.Lock()
+		defer profile.Unlock()
+		next.ServeHTTP(w, r)
+	})
 }
 
 const incomingRoute string = "[%s] %s on %s\n"
@@ -73,6 +127,9 @@ func handleWebSocketUpgrade(next http.Handler) http.Handler {
 	})
 }
 
+// launcherRoute is also served plain JSON bodies, as third-party launchers do not compress them
+const launcherRoute string = "/launcher/"
+
 func decompress(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Content-Length") == "" {
@@ -80,9 +137,20 @@ func decompress(next http.Handler) http.Handler {
 			return
 		}
 
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+
 		buffer, err := pkg.ZlibInflate(r)
+		if err != nil && strings.HasPrefix(r.URL.Path, launcherRoute) && json.Valid(raw) {
+			buffer, err = raw, nil
+		}
 		if err != nil {
 			log.Println(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		} else if buffer == nil || len(buffer) == 0 {
 			next.ServeHTTP(w, r)
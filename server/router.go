@@ -74,6 +74,13 @@ var mainRouteHandlers = map[string]http.HandlerFunc{
 	"/client/achievement/list":      handlers.GetAchievements,
 	"/client/builds/list":           handlers.MainBuildsList,
 	//"/client/handbook/builds/my/list" check if still used
+
+	"/launcher/server/connect":          handlers.LauncherConnect,
+	"/launcher/profile/register":        handlers.LauncherRegister,
+	"/launcher/profile/login":           handlers.LauncherLogin,
+	"/launcher/profile/change/password": handlers.LauncherChangePassword,
+	"/launcher/profile/change/edition":  handlers.LauncherChangeEdition,
+	"/launcher/profile/wipe":            handlers.LauncherWipe,
 }
 
 func AddMainRoute(route string, handler http.HandlerFunc) {
@@ -137,9 +144,9 @@ func loadTradingRoutes(mux *chi.Mux) {
 }
 
 var ragfairRouteHandlers = map[string]http.HandlerFunc{
-	//"/client/ragfair/offer/findbyid"
-	//"/client/ragfair/itemMarketPrice"
-	"/client/ragfair/find": handlers.RagfairFind,
+	"/client/ragfair/offer/findbyid":  handlers.RagfairOfferFindByID,
+	"/client/ragfair/itemMarketPrice": handlers.RagfairItemMarketPrice,
+	"/client/ragfair/find":            handlers.RagfairFind,
 }
 
 func loadRagfairRoutes(mux *chi.Mux) {
@@ -159,48 +166,38 @@ func OverrideRagfairRoute(route string, handler http.HandlerFunc) {
 }
 
 var messagingRouteHandlers = map[string]http.HandlerFunc{
-	"/client/friend/list":                handlers.MessagingFriendList,
-	"/client/mail/dialog/list":           handlers.MessagingDialogList,
-	"/client/friend/request/list/inbox":  handlers.MessagingFriendRequestInbox,
-	"/client/friend/request/list/outbox": handlers.MessagingFriendRequestOutbox,
-	"/client/mail/dialog/info":           handlers.MessagingMailDialogInfo,
-	"/client/mail/dialog/view":           handlers.MessagingMailDialogView,
-	"/client/mail/dialog/pin":            handlers.MessagingMailDialogPin,
-	"/client/mail/dialog/unpin":          handlers.MessagingMailDialogUnpin,
-	"/client/mail/dialog/remove":         handlers.MessagingMailDialogRemove,
-	"/client/mail/dialog/clear":          handlers.MessagingMailDialogClear,
+	"/client/friend/list":                    handlers.MessagingFriendList,
+	"/client/mail/dialog/list":               handlers.MessagingDialogList,
+	"/client/friend/request/list/inbox":      handlers.MessagingFriendRequestInbox,
+	"/client/friend/request/list/outbox":     handlers.MessagingFriendRequestOutbox,
+	"/client/mail/dialog/info":               handlers.MessagingMailDialogInfo,
+	"/client/mail/dialog/view":               handlers.MessagingMailDialogView,
+	"/client/mail/dialog/pin":                handlers.MessagingMailDialogPin,
+	"/client/mail/dialog/unpin":              handlers.MessagingMailDialogUnpin,
+	"/client/mail/dialog/remove":             handlers.MessagingMailDialogRemove,
+	"/client/mail/dialog/clear":              handlers.MessagingMailDialogClear,
+	"/client/mail/dialog/read":               handlers.MessagingMailDialogRead,
+	"/client/mail/dialog/getAllAttachments":  handlers.MessagingMailDialogGetAllAttachments,
+	"/client/friend/request/send":            handlers.MessagingFriendRequestSend,
+	"/client/friend/request/accept":          handlers.MessagingFriendRequestAccept,
+	"/client/friend/request/accept-all":      handlers.MessagingFriendRequestAcceptAll,
+	"/client/friend/request/decline":         handlers.MessagingFriendRequestDecline,
+	"/client/friend/request/cancel":          handlers.MessagingFriendRequestCancel,
+	"/client/friend/delete":                  handlers.MessagingFriendDelete,
+	"/client/friend/ignore/set":              handlers.MessagingFriendIgnoreSet,
+	"/client/friend/ignore/remove":           handlers.MessagingFriendIgnoreRemove,
+	"/client/mail/msg/send":                  handlers.MessagingMailMessageSend,
+	"/client/mail/dialog/group/create":       handlers.MessagingMailGroupCreate,
+	"/client/mail/dialog/group/leave":        handlers.MessagingMailGroupLeave,
+	"/client/mail/dialog/group/owner/change": handlers.MessagingMailGroupOwnerChange,
+	"/client/mail/dialog/group/users/add":    handlers.MessagingMailGroupUsersAdd,
+	"/client/mail/dialog/group/users/remove": handlers.MessagingMailGroupUsersRemove,
 }
 
 func loadMessagingRoutes(mux *chi.Mux) {
 	for route, handler := range messagingRouteHandlers {
 		mux.HandleFunc(route, handler)
 	}
-
-	// "/client/friend/delete"
-
-	// "/client/friend/request/accept-all"
-	// "/client/friend/request/decline"
-	// "/client/friend/request/accept"
-	// "/client/friend/request/cancel"
-	// "/client/friend/request/send"
-	// "/client/friend/request/decline"
-
-	// "/client/friend/ignore/remove"
-
-	// "/client/mail/dialog/getAllAttachments"
-	// "/client/mail/dialog/clear"
-	// "/client/mail/dialog/read"
-
-	// "/client/mail/dialog/group/create"
-	// "/client/mail/dialog/group/leave"
-
-	// "/client/mail/dialog/group/owner/change"
-
-	// "/client/mail/dialog/group/users/add"
-	// "/client/mail/dialog/group/users/remove"
-
-	// "/client/mail/msg/send"
-
 }
 
 func OverrideMessagingRoute(route string, handler http.HandlerFunc) {
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
@@ -18,30 +19,69 @@ const (
 	SsFormat            string = "%s: %s"
 )
 
-// WriteToFile writes the given string of data to the specified file path
+// WriteToFile writes the given data as indented JSON to the specified file path, replacing the file atomically
 func WriteToFile(filePath string, data any) error {
+	buffer := new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)  // don't escape Unicode
+	encoder.SetIndent("", "    ") //4 space indentation
+
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+
+	return WriteBytesToFile(filePath, buffer.Bytes())
+}
+
+// WriteBytesToFile writes the bytes to a temporary file next to the specified file path, syncs it and renames it
+// over the file, so a crash mid-write leaves either the old or the new file behind
+func WriteBytesToFile(filePath string, data []byte) error {
 	path := GetAbsolutePathFrom(filePath)
-	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 		return err
 	}
 
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetEscapeHTML(false)  // don't escape Unicode
-	encoder.SetIndent("", "    ") //4 space indentation
+	tempPath := file.Name()
+	defer os.Remove(tempPath)
 
-	if err := encoder.Encode(data); err != nil {
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
 		return err
 	}
 
+	if err := os.Rename(tempPath, path); err != nil {
+		return err
+	}
+	syncDirectory(dir)
 	return nil
 }
 
+// syncDirectory flushes the directory entry of a renamed file, which not every platform supports
+func syncDirectory(dir string) {
+	directory, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer directory.Close()
+	_ = directory.Sync()
+}
+
 // GetAbsolutePathFrom returns the absolute path from a relative path
 func GetAbsolutePathFrom(path string) string {
 	if filepath.IsAbs(path) {